@@ -45,6 +45,8 @@ export enum ProcessStatus {
   COMPLETED,
   ERRORED,
   LIVESTREAM,
+  PAUSED,
+  SCHEDULED,
 }
 
 type DownloadProgress = {
@@ -68,6 +68,10 @@ export function mapProcessStatus(status: ProcessStatus) {
       return 'Error'
     case ProcessStatus.LIVESTREAM:
       return 'Livestream'
+    case ProcessStatus.PAUSED:
+      return 'Paused'
+    case ProcessStatus.SCHEDULED:
+      return 'Scheduled'
     default:
       return 'Pending'
   }
@@ -17,6 +17,7 @@ import (
 var (
 	host              string
 	port              int
+	grpcPort          int
 	queueSize         int
 	configFile        string
 	downloadPath      string
@@ -46,6 +47,7 @@ var (
 func init() {
 	flag.StringVar(&host, "host", "0.0.0.0", "Host where server will listen at")
 	flag.IntVar(&port, "port", 3033, "Port where server will listen at")
+	flag.IntVar(&grpcPort, "grpc", 0, "Port where the gRPC server will listen at, 0 disables it")
 	flag.IntVar(&queueSize, "qs", 2, "Queue size (concurrent downloads)")
 
 	flag.StringVar(&configFile, "conf", "./config.yml", "Config file path")
@@ -82,6 +84,7 @@ func main() {
 		// TODO: find an alternative way to populate the config struct from flags or config file
 		c.Host = host
 		c.Port = port
+		c.GRPCPort = grpcPort
 
 		c.QueueSize = queueSize
 
@@ -96,6 +99,10 @@ func main() {
 		c.RequireAuth = requireAuth
 		c.Username = username
 		c.Password = password
+
+		c.Retry = config.DefaultRetryPolicy()
+		c.LoginThrottle = config.DefaultLoginThrottle()
+		c.Subscriptions = config.DefaultSubscriptions()
 	}
 
 	// limit concurrent downloads for systems with 2 or less logical cores
@@ -5,6 +5,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/domain"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/metrics"
 )
 
 // alias type
@@ -13,6 +14,21 @@ type Service = domain.Service
 type Entity = domain.ArchiveEntry
 
 func ApplyRouter(db *sql.DB) func(chi.Router) {
-	handler, _ := Container(db)
+	handler, _, _ := Container(db)
 	return handler.ApplyRouter()
 }
+
+// Registers a gauge with the number of entries in the archive.
+func RegisterMetrics(db *sql.DB) {
+	metrics.NewGaugeFunc(
+		metrics.Namespace+"archive_entries",
+		"Number of entries in the download archive.",
+		func() []metrics.Sample {
+			var count int
+			if err := db.QueryRow("SELECT COUNT(*) FROM archive").Scan(&count); err != nil {
+				return nil
+			}
+			return []metrics.Sample{{Value: float64(count)}}
+		},
+	)
+}
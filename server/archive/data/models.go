@@ -3,14 +3,16 @@ package data
 import "time" // Ensure time is imported
 
 type ArchiveEntry struct {
-	RowId     int64     `json:"-"` // Internal cursor ID, not for client JSON
-	Id        string    `json:"id"`
-	Title     string    `json:"title"`
-	Path      string    `json:"path"`
-	Thumbnail string    `json:"thumbnail"`
-	Source    string    `json:"source"`
-	Metadata  string    `json:"metadata"`  // JSON string of full metadata
-	CreatedAt time.Time `json:"created_at"`
-	Duration  int64     `json:"duration,omitempty"` // New, in seconds
-	Format    string    `json:"format,omitempty"`   // New, e.g., "mp4", "webm"
+	RowId        int64     `json:"-"` // Internal cursor ID, not for client JSON
+	Id           string    `json:"id"`
+	Owner        string    `json:"owner,omitempty"`
+	Subscription string    `json:"subscription_id,omitempty"` // set for the downloads of a subscription
+	Title        string    `json:"title"`
+	Path         string    `json:"path"`
+	Thumbnail    string    `json:"thumbnail"`
+	Source       string    `json:"source"`
+	Metadata     string    `json:"metadata"` // JSON string of full metadata
+	CreatedAt    time.Time `json:"created_at"`
+	Duration     int64     `json:"duration,omitempty"` // New, in seconds
+	Format       string    `json:"format,omitempty"`   // New, e.g., "mp4", "webm"
 }
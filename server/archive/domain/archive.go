@@ -10,15 +10,17 @@ import (
 )
 
 type ArchiveEntry struct {
-	Id        string    `json:"id"`
-	Title     string    `json:"title"`
-	Path      string    `json:"path"`
-	Thumbnail string    `json:"thumbnail"`
-	Source    string    `json:"source"`
-	Metadata  string    `json:"metadata"`
-	CreatedAt time.Time `json:"created_at"`
-	Duration  int64     `json:"duration,omitempty"` // New
-	Format    string    `json:"format,omitempty"`   // New
+	Id           string    `json:"id"`
+	Owner        string    `json:"owner,omitempty"`
+	Subscription string    `json:"subscription_id,omitempty"` // set for the downloads of a subscription
+	Title        string    `json:"title"`
+	Path         string    `json:"path"`
+	Thumbnail    string    `json:"thumbnail"`
+	Source       string    `json:"source"`
+	Metadata     string    `json:"metadata"`
+	CreatedAt    time.Time `json:"created_at"`
+	Duration     int64     `json:"duration,omitempty"` // New
+	Format       string    `json:"format,omitempty"`   // New
 }
 
 type PaginatedResponse[T any] struct {
@@ -33,7 +35,9 @@ type Repository interface {
 	HardDelete(ctx context.Context, id string) (*data.ArchiveEntry, error)
 	List(ctx context.Context, startRowId int, limit int, sortBy string, filters map[string]string, searchQuery string) (*[]data.ArchiveEntry, error) // Signature updated
 	GetCursor(ctx context.Context, id string) (int64, error)
-	IsSourceDownloaded(ctx context.Context, sourceURL string) (bool, error) 
+	Owner(ctx context.Context, id string) (string, error)
+	IsSourceDownloaded(ctx context.Context, sourceURL string) (bool, error)
+	ListBySubscription(ctx context.Context, subscriptionID string) (*[]data.ArchiveEntry, error) // from the newest
 }
 
 type Service interface {
@@ -29,7 +29,7 @@ func provideRepository(db *sql.DB) domain.Repository {
 
 func provideService(r domain.Repository) domain.Service {
 	svcOnce.Do(func() {
-		svc = service.New(r)
+		svc = service.NewService(r)
 	})
 	return svc
 }
@@ -32,8 +32,10 @@ func (r *Repository) Archive(ctx context.Context, entry *data.ArchiveEntry) erro
 
 	_, err = conn.ExecContext(
 		ctx,
-		"INSERT INTO archive (id, title, path, thumbnail, source, metadata, created_at, duration, format) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		"INSERT INTO archive (id, owner, subscription_id, title, path, thumbnail, source, metadata, created_at, duration, format) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		uuid.NewString(), 
+		entry.Owner,
+		entry.Subscription,
 		entry.Title,
 		entry.Path,
 		entry.Thumbnail,
@@ -60,9 +62,10 @@ func (r *Repository) SoftDelete(ctx context.Context, id string) (*data.ArchiveEn
 	defer tx.Rollback()
 
 	var model data.ArchiveEntry
-	row := tx.QueryRowContext(ctx, "SELECT id, title, path, thumbnail, source, metadata, created_at, duration, format FROM archive WHERE id = ?", id)
+	row := tx.QueryRowContext(ctx, "SELECT id, owner, title, path, thumbnail, source, metadata, created_at, duration, format FROM archive WHERE id = ?", id)
 	if err := row.Scan(
 		&model.Id,
+		&model.Owner,
 		&model.Title,
 		&model.Path,
 		&model.Thumbnail,
@@ -126,7 +129,7 @@ func (r *Repository) List(ctx context.Context, startRowId int, limit int, sortBy
 		ftsSubQuery := "SELECT rowid FROM archive_fts WHERE archive_fts MATCH ?" // General FTS match
 		args = append(args, ftsQueryToken)
 
-		finalQuerySb.WriteString("SELECT r.rowid, r.id, r.title, r.path, r.thumbnail, r.source, r.metadata, r.created_at, r.duration, r.format ")
+		finalQuerySb.WriteString("SELECT r.rowid, r.id, r.owner, r.title, r.path, r.thumbnail, r.source, r.metadata, r.created_at, r.duration, r.format ")
 		finalQuerySb.WriteString("FROM archive r JOIN (")
 		finalQuerySb.WriteString(ftsSubQuery)
 		finalQuerySb.WriteString(") fts_matches ON r.rowid = fts_matches.rowid ")
@@ -135,6 +138,9 @@ func (r *Repository) List(ctx context.Context, startRowId int, limit int, sortBy
 		for key, value := range filters {
 			if value == "" { continue }
 			switch key {
+			case "owner":
+				conditions = append(conditions, "r.owner = ?")
+				args = append(args, value)
 			case "uploader":
 				conditions = append(conditions, "LOWER(r.source) LIKE ?") // Alias 'r' for archive table
 				args = append(args, "%"+strings.ToLower(value)+"%")
@@ -176,11 +182,14 @@ func (r *Repository) List(ctx context.Context, startRowId int, limit int, sortBy
 
 	} else {
 		// Non-FTS Path (existing logic)
-		finalQuerySb.WriteString("SELECT rowid, id, title, path, thumbnail, source, metadata, created_at, duration, format FROM archive ")
+		finalQuerySb.WriteString("SELECT rowid, id, owner, title, path, thumbnail, source, metadata, created_at, duration, format FROM archive ")
 		
 		for key, value := range filters { 
 			if value == "" { continue }
 			switch key {
+			case "owner":
+				conditions = append(conditions, "owner = ?")
+				args = append(args, value)
 			case "uploader":
 				conditions = append(conditions, "LOWER(source) LIKE ?")
 				args = append(args, "%"+strings.ToLower(value)+"%")
@@ -257,6 +266,7 @@ func (r *Repository) List(ctx context.Context, startRowId int, limit int, sortBy
 		if err := rows.Scan(
 			&entry.RowId, 
 			&entry.Id,
+			&entry.Owner,
 			&entry.Title,
 			&entry.Path,
 			&entry.Thumbnail,
@@ -293,6 +303,20 @@ func (r *Repository) GetCursor(ctx context.Context, id string) (int64, error) {
 	return rowId, nil
 }
 
+func (r *Repository) Owner(ctx context.Context, id string) (string, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	var owner string
+	if err := conn.QueryRowContext(ctx, "SELECT owner FROM archive WHERE id = ?", id).Scan(&owner); err != nil {
+		return "", err
+	}
+	return owner, nil
+}
+
 func (r *Repository) IsSourceDownloaded(ctx context.Context, sourceURL string) (bool, error) {
 	conn, err := r.db.Conn(ctx)
 	if err != nil {
@@ -308,3 +332,41 @@ func (r *Repository) IsSourceDownloaded(ctx context.Context, sourceURL string) (
 	}
 	return exists, nil
 }
+
+func (r *Repository) ListBySubscription(ctx context.Context, subscriptionID string) (*[]data.ArchiveEntry, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(
+		ctx,
+		`SELECT rowid, id, owner, subscription_id, title, path, source, created_at
+		FROM archive WHERE subscription_id = ? ORDER BY created_at DESC, rowid DESC`,
+		subscriptionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []data.ArchiveEntry
+	for rows.Next() {
+		var entry data.ArchiveEntry
+		if err := rows.Scan(
+			&entry.RowId,
+			&entry.Id,
+			&entry.Owner,
+			&entry.Subscription,
+			&entry.Title,
+			&entry.Path,
+			&entry.Source,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return &entries, rows.Err()
+}
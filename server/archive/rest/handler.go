@@ -8,9 +8,6 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/domain"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/openid"
-
 	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
 )
 
@@ -96,18 +93,21 @@ func (h *Handler) List() http.HandlerFunc { // Using Handler
 // ApplyRouter, Archive, SoftDelete, HardDelete, GetCursor methods remain here...
 func (h *Handler) ApplyRouter() func(chi.Router) { // Using Handler
 	return func(r chi.Router) {
-		if config.Instance().RequireAuth {
+		if middlewares.AuthEnabled() {
 			r.Use(middlewares.Authenticated)
 		}
-		if config.Instance().UseOpenId {
-			r.Use(openid.Middleware)
-		}
 
-		r.Get("/", h.List())
-		r.Get("/cursor/{id}", h.GetCursor())
-		r.Post("/", h.Archive())
-		r.Delete("/soft/{id}", h.SoftDelete())
-		r.Delete("/hard/{id}", h.HardDelete())
+		var (
+			read    = middlewares.RequireScope(middlewares.ScopeRead)
+			enqueue = middlewares.RequireScope(middlewares.ScopeEnqueue)
+			remove  = middlewares.RequireScope(middlewares.ScopeDelete)
+		)
+
+		r.With(read).Get("/", h.List())
+		r.With(read).Get("/cursor/{id}", h.GetCursor())
+		r.With(enqueue).Post("/", h.Archive())
+		r.With(remove).Delete("/soft/{id}", h.SoftDelete())
+		r.With(remove).Delete("/hard/{id}", h.HardDelete())
 	}
 }
 
@@ -2,9 +2,13 @@ package service
 
 import (
 	"context"
+	"database/sql"
+
 	// Ensure time is imported if used by domain.ArchiveEntry mapping (it's used by CreatedAt)
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/data" // For data.ArchiveEntry
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/domain"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
 )
 
 type service struct { // Renamed from Service to service to match convention
@@ -19,6 +23,10 @@ func NewService(repo domain.Repository) domain.Service { // Renamed from New to
 
 // List implements domain.Service.
 func (s *service) List(ctx context.Context, startRowId int, limit int, sortBy string, filters map[string]string, searchQuery string) (*domain.PaginatedResponse[[]domain.ArchiveEntry], error) { // Signature updated
+	if owner, ok := middlewares.RestrictedTo(ctx); ok {
+		filters["owner"] = owner
+	}
+
 	archiveEntries, err := s.repository.List(ctx, startRowId, limit, sortBy, filters, searchQuery) // searchQuery passed
 	if err != nil {
 		return nil, err
@@ -39,6 +47,7 @@ func (s *service) List(ctx context.Context, startRowId int, limit int, sortBy st
 		for i, entry := range *archiveEntries { // entry is data.ArchiveEntry
 			respEntries[i] = domain.ArchiveEntry{
 				Id:        entry.Id,
+				Owner:     entry.Owner,
 				Title:     entry.Title,
 				Path:      entry.Path,
 				Thumbnail: entry.Thumbnail,
@@ -63,9 +72,17 @@ func (s *service) List(ctx context.Context, startRowId int, limit int, sortBy st
 
 // Archive implements domain.Service.
 func (s *service) Archive(ctx context.Context, entity *domain.ArchiveEntry) error {
+	// entries archived through the api belong to the caller, the ones of the
+	// completed downloads to the owner of the download
+	if owner := middlewares.Owner(ctx); owner != "" {
+		entity.Owner = owner
+	}
+
 	// Map domain.ArchiveEntry to data.ArchiveEntry
 	dataEntry := &data.ArchiveEntry{
 		Id:        entity.Id, 
+		Owner:     entity.Owner,
+		Subscription: entity.Subscription,
 		Title:     entity.Title,
 		Path:      entity.Path,
 		Thumbnail: entity.Thumbnail,
@@ -80,6 +97,10 @@ func (s *service) Archive(ctx context.Context, entity *domain.ArchiveEntry) erro
 
 // SoftDelete implements domain.Service.
 func (s *service) SoftDelete(ctx context.Context, id string) (*domain.ArchiveEntry, error) {
+	if err := s.authorize(ctx, id); err != nil {
+		return nil, err
+	}
+
 	deletedEntry, err := s.repository.SoftDelete(ctx, id)
 	if err != nil {
 		return nil, err
@@ -87,8 +108,12 @@ func (s *service) SoftDelete(ctx context.Context, id string) (*domain.ArchiveEnt
 	if deletedEntry == nil {
 		return nil, nil // Not found
 	}
+
+	audit.Record(ctx, audit.ActionArchiveSoftDelete, deletedEntry.Path)
+
 	return &domain.ArchiveEntry{ // Map data to domain
 		Id:        deletedEntry.Id,
+		Owner:     deletedEntry.Owner,
 		Title:     deletedEntry.Title,
 		Path:      deletedEntry.Path,
 		Thumbnail: deletedEntry.Thumbnail,
@@ -102,15 +127,25 @@ func (s *service) SoftDelete(ctx context.Context, id string) (*domain.ArchiveEnt
 
 // HardDelete implements domain.Service.
 func (s *service) HardDelete(ctx context.Context, id string) (*domain.ArchiveEntry, error) {
+	if err := s.authorize(ctx, id); err != nil {
+		return nil, err
+	}
+
 	deletedEntry, err := s.repository.HardDelete(ctx, id)
+	// the entry is gone even if its file couldn't be removed
+	if deletedEntry != nil {
+		audit.Record(ctx, audit.ActionArchiveDelete, deletedEntry.Path)
+	}
 	if err != nil {
 		return nil, err
 	}
 	if deletedEntry == nil {
 		return nil, nil // Not found
 	}
+
 	return &domain.ArchiveEntry{ // Map data to domain
 		Id:        deletedEntry.Id,
+		Owner:     deletedEntry.Owner,
 		Title:     deletedEntry.Title,
 		Path:      deletedEntry.Path,
 		Thumbnail: deletedEntry.Thumbnail,
@@ -125,9 +160,29 @@ func (s *service) HardDelete(ctx context.Context, id string) (*domain.ArchiveEnt
 
 // GetCursor implements domain.Service.
 func (s *service) GetCursor(ctx context.Context, id string) (int64, error) {
+	if err := s.authorize(ctx, id); err != nil {
+		return -1, err
+	}
 	return s.repository.GetCursor(ctx, id)
 }
 
+// Users who aren't admins can only access their own entries
+func (s *service) authorize(ctx context.Context, id string) error {
+	restrictedTo, ok := middlewares.RestrictedTo(ctx)
+	if !ok {
+		return nil
+	}
+
+	owner, err := s.repository.Owner(ctx, id)
+	if err != nil {
+		return err
+	}
+	if owner != restrictedTo {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // IsSourceDownloaded is not part of domain.Service, it's on domain.Repository
 // func (s *service) IsSourceDownloaded(ctx context.Context, sourceURL string) (bool, error) {
 // 	return s.repository.IsSourceDownloaded(ctx, sourceURL)
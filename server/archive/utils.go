@@ -6,14 +6,13 @@ import (
 	"context"
 	"os"
 	"os/exec"
-	"path/filepath"
 
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 )
 
-// Perform a search on the archive.txt file an determines if a download
-// has already be done.
-func DownloadExists(ctx context.Context, url string) (bool, error) {
+// Perform a search on the archive file of the owner an determines if a
+// download has already be done.
+func DownloadExists(ctx context.Context, owner, url string) (bool, error) {
 	cmd := exec.CommandContext(
 		ctx,
 		config.Instance().DownloaderPath,
@@ -28,7 +27,7 @@ func DownloadExists(ctx context.Context, url string) (bool, error) {
 
 	extractorAndURL := bytes.Trim(stdout, "\n")
 
-	fd, err := os.Open(filepath.Join(config.Instance().Dir(), "archive.txt"))
+	fd, err := os.Open(config.Instance().UserArchivePath(owner))
 	if err != nil {
 		return false, err
 	}
@@ -20,7 +20,7 @@ var (
 type Message = archive.Entity
 
 func Register(db *sql.DB) {
-	_, s := archive.Container(db)
+	_, s, _ := archive.Container(db)
 	archiveService = s
 }
 
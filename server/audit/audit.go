@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit/domain"
+)
+
+// Recorded actions, the target is described next to each one
+const (
+	ActionLogin         = "auth.login"          // username
+	ActionLoginFailed   = "auth.login_failed"   // username, the subject with openid
+	ActionSessionRevoke = "auth.session_revoke" // openid session id
+
+	ActionQueue          = "download.queue"    // url
+	ActionQueuePlaylist  = "download.playlist" // url
+	ActionKill           = "download.kill"     // process id
+	ActionKillAll        = "download.kill_all"
+	ActionClear          = "download.clear" // process id
+	ActionClearCompleted = "download.clear_completed"
+	ActionPause          = "download.pause"    // process id
+	ActionResume         = "download.resume"   // process id
+	ActionPriority       = "download.priority" // process id
+
+	ActionLivestreamAdd     = "livestream.add"  // url
+	ActionLivestreamKill    = "livestream.kill" // url
+	ActionLivestreamKillAll = "livestream.kill_all"
+
+	ActionFileDownload = "file.download" // path
+	ActionFileDelete   = "file.delete"   // path
+	ActionBulkDownload = "file.bulk_download"
+
+	ActionArchiveDelete     = "archive.delete"      // path, the file is removed too
+	ActionArchiveSoftDelete = "archive.soft_delete" // path
+
+	ActionCookiesRead   = "cookies.read"
+	ActionCookiesUpdate = "cookies.update"
+	ActionCookiesDelete = "cookies.delete"
+
+	ActionTemplateCreate = "template.create" // name
+	ActionTemplateUpdate = "template.update" // template id
+	ActionTemplateDelete = "template.delete" // template id
+
+	ActionBandwidth   = "settings.bandwidth" // limit
+	ActionUpdateYtdlp = "settings.update_ytdlp"
+	ActionUserCreate  = "user.create"  // username
+	ActionUserUpdate  = "user.update"  // user id
+	ActionUserDelete  = "user.delete"  // user id
+	ActionTokenCreate = "token.create" // token name
+	ActionTokenRevoke = "token.revoke" // token id
+)
+
+var recorder domain.Service
+
+// Sets where the actions are recorded, until then they're discarded.
+func Register(db *sql.DB) {
+	_, s := Container(db)
+	recorder = s
+}
+
+// Appends an action of the caller to the audit log, the actor and the client
+// address are taken from the context.
+func Record(ctx context.Context, action, target string) {
+	if recorder != nil {
+		recorder.Record(ctx, action, target)
+	}
+}
@@ -0,0 +1,12 @@
+package data
+
+import "time"
+
+type Entry struct {
+	Id        int64
+	Actor     string
+	Action    string
+	Target    string
+	ClientIP  string
+	CreatedAt time.Time
+}
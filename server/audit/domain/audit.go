@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit/data"
+)
+
+// An action performed by a user
+type Entry struct {
+	Id        int64     `json:"id"`
+	Actor     string    `json:"actor"` // empty without authentication
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	ClientIP  string    `json:"client_ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Restricts the listed entries, empty fields match everything.
+// Entries are listed from the newest, starting before the cursor.
+type Filter struct {
+	Actor    string
+	Action   string // an action ending with a dot matches the actions it prefixes
+	Target   string // matches the targets containing it
+	ClientIP string
+	From     *time.Time
+	To       *time.Time
+	Cursor   int64
+	Limit    int
+}
+
+type PaginatedResponse[T any] struct {
+	First int64 `json:"first"`
+	Next  int64 `json:"next"` // 0 when there are no more entries
+	Data  T     `json:"data"`
+}
+
+type Repository interface {
+	Append(ctx context.Context, entry *data.Entry) error
+	List(ctx context.Context, filter *Filter) (*[]data.Entry, error)
+}
+
+type Service interface {
+	Record(ctx context.Context, action, target string)
+	List(ctx context.Context, filter *Filter) (*PaginatedResponse[[]Entry], error)
+}
+
+type RestHandler interface {
+	List() http.HandlerFunc
+	ApplyRouter() func(chi.Router)
+}
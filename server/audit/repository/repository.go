@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit/domain"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) domain.Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// Append implements domain.Repository.
+func (r *Repository) Append(ctx context.Context, entry *data.Entry) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(
+		ctx,
+		"INSERT INTO audit_log (actor, action, target, client_ip, created_at) VALUES (?, ?, ?, ?, ?)",
+		entry.Actor,
+		entry.Action,
+		entry.Target,
+		entry.ClientIP,
+		entry.CreatedAt,
+	)
+
+	return err
+}
+
+// List implements domain.Repository.
+func (r *Repository) List(ctx context.Context, filter *domain.Filter) (*[]data.Entry, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if strings.HasSuffix(filter.Action, ".") {
+		conditions = append(conditions, "substr(action, 1, ?) = ?")
+		args = append(args, len(filter.Action), filter.Action)
+	} else if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.Target != "" {
+		conditions = append(conditions, "instr(target, ?) > 0")
+		args = append(args, filter.Target)
+	}
+	if filter.ClientIP != "" {
+		conditions = append(conditions, "client_ip = ?")
+		args = append(args, filter.ClientIP)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+	if filter.Cursor > 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, filter.Cursor)
+	}
+
+	query := "SELECT id, actor, action, target, client_ip, created_at FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	elements := []data.Entry{}
+
+	for rows.Next() {
+		var element data.Entry
+
+		if err := rows.Scan(
+			&element.Id,
+			&element.Actor,
+			&element.Action,
+			&element.Target,
+			&element.ClientIP,
+			&element.CreatedAt,
+		); err != nil {
+			return &elements, err
+		}
+
+		elements = append(elements, element)
+	}
+
+	return &elements, rows.Err()
+}
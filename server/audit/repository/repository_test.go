@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit/domain"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/dbutil"
+	_ "modernc.org/sqlite"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	// the migration leaves a lock file in the working directory
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "local.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := dbutil.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Appended in order, an hour apart from each other
+var entries = []data.Entry{
+	{Actor: "alice", Action: "auth.login", Target: "alice", ClientIP: "10.0.0.1"},
+	{Actor: "alice", Action: "download.queue", Target: "https://example.com/a", ClientIP: "10.0.0.1"},
+	{Actor: "bob", Action: "download.kill", Target: "process-1", ClientIP: "10.0.0.2"},
+	{Actor: "bob", Action: "downloader.update", Target: "", ClientIP: "10.0.0.2"},
+	{Actor: "alice", Action: "file.delete", Target: "/downloads/alice/a.mp4", ClientIP: "10.0.0.3"},
+}
+
+func appendEntries(t *testing.T, r domain.Repository) {
+	t.Helper()
+
+	for i, e := range entries {
+		e.CreatedAt = start.Add(time.Hour * time.Duration(i))
+		if err := r.Append(context.Background(), &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAppendOnly(t *testing.T) {
+	var (
+		ctx = context.Background()
+		db  = newTestDB(t)
+		r   = New(db)
+	)
+	appendEntries(t, r)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "update", query: "UPDATE audit_log SET actor = 'mallory' WHERE actor = 'alice'"},
+		{name: "update everything", query: "UPDATE audit_log SET created_at = created_at"},
+		{name: "delete", query: "DELETE FROM audit_log WHERE id = 1"},
+		{name: "delete everything", query: "DELETE FROM audit_log"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := db.ExecContext(ctx, tc.query)
+			if err == nil || !strings.Contains(err.Error(), "append-only") {
+				t.Fatalf("%s: error = %v, want the append-only error", tc.query, err)
+			}
+
+			got, err := r.List(ctx, &domain.Filter{Limit: len(entries) + 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(*got) != len(entries) || (*got)[len(entries)-1].Actor != "alice" {
+				t.Errorf("entries changed to %+v", *got)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		r     = New(newTestDB(t))
+		from  = start.Add(time.Hour)
+		to    = start.Add(time.Hour * 3)
+		limit = len(entries)
+	)
+	appendEntries(t, r)
+
+	// ids of the entries, from 1 in the order they were appended
+	tests := []struct {
+		name   string
+		filter domain.Filter
+		want   []int64
+	}{
+		{name: "everything, newest first", filter: domain.Filter{}, want: []int64{5, 4, 3, 2, 1}},
+		{name: "actor", filter: domain.Filter{Actor: "bob"}, want: []int64{4, 3}},
+		{name: "action", filter: domain.Filter{Action: "download.kill"}, want: []int64{3}},
+		// doesn't match downloader.update
+		{name: "action prefix", filter: domain.Filter{Action: "download."}, want: []int64{3, 2}},
+		{name: "target", filter: domain.Filter{Target: "example.com"}, want: []int64{2}},
+		{name: "client address", filter: domain.Filter{ClientIP: "10.0.0.1"}, want: []int64{2, 1}},
+		{name: "time range", filter: domain.Filter{From: &from, To: &to}, want: []int64{3, 2}},
+		{name: "cursor", filter: domain.Filter{Cursor: 3}, want: []int64{2, 1}},
+		{name: "limit", filter: domain.Filter{Limit: 2}, want: []int64{5, 4}},
+		{name: "combined", filter: domain.Filter{Actor: "alice", Action: "download.", ClientIP: "10.0.0.1"}, want: []int64{2}},
+		{name: "no match", filter: domain.Filter{Actor: "mallory"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.filter.Limit == 0 {
+				tc.filter.Limit = limit
+			}
+
+			got, err := r.List(ctx, &tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []int64
+			for _, e := range *got {
+				ids = append(ids, e.Id)
+			}
+			if !slices.Equal(ids, tc.want) {
+				t.Errorf("listed %v, want %v", ids, tc.want)
+			}
+		})
+	}
+}
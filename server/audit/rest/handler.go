@@ -0,0 +1,99 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit/domain"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+)
+
+type RestHandler struct {
+	svc domain.Service
+}
+
+func New(svc domain.Service) domain.RestHandler {
+	return &RestHandler{
+		svc: svc,
+	}
+}
+
+// ApplyRouter implements domain.RestHandler.
+func (h *RestHandler) ApplyRouter() func(chi.Router) {
+	return func(r chi.Router) {
+		if middlewares.AuthEnabled() {
+			r.Use(middlewares.Authenticated)
+		}
+		r.Use(middlewares.RequireScope(middlewares.ScopeAdmin))
+
+		r.Get("/", h.List())
+	}
+}
+
+// List implements domain.RestHandler.
+// Filters are given as query parameters: actor, action, target, ip, from and
+// to (RFC 3339), the page with cursor and limit.
+func (h *RestHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+
+		filter := domain.Filter{
+			Actor:    query.Get("actor"),
+			Action:   query.Get("action"),
+			Target:   query.Get("target"),
+			ClientIP: query.Get("ip"),
+		}
+
+		var err error
+
+		if filter.From, err = parseTime(query.Get("from")); err != nil {
+			http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filter.To, err = parseTime(query.Get("to")); err != nil {
+			http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if c := query.Get("cursor"); c != "" {
+			if filter.Cursor, err = strconv.ParseInt(c, 10, 64); err != nil {
+				http.Error(w, "invalid cursor", http.StatusBadRequest)
+				return
+			}
+		}
+		if l := query.Get("limit"); l != "" {
+			if filter.Limit, err = strconv.Atoi(l); err != nil {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		res, err := h.svc.List(r.Context(), &filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
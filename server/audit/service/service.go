@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit/domain"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+type Service struct {
+	r domain.Repository
+}
+
+func New(r domain.Repository) domain.Service {
+	return &Service{
+		r: r,
+	}
+}
+
+// Record implements domain.Service.
+// The actor and the client address are taken from the context, a failure is
+// only logged so it doesn't fail the action itself.
+func (s *Service) Record(ctx context.Context, action, target string) {
+	entry := &data.Entry{
+		Actor:     middlewares.Owner(ctx),
+		Action:    action,
+		Target:    target,
+		ClientIP:  middlewares.ClientIPFrom(ctx),
+		CreatedAt: time.Now().UTC(), // stored as text, compared as such by the filters
+	}
+
+	// the action is recorded even if the client has gone away meanwhile
+	if err := s.r.Append(context.WithoutCancel(ctx), entry); err != nil {
+		slog.Error(
+			"failed to write the audit log",
+			slog.String("action", action),
+			slog.String("target", target),
+			slog.String("err", err.Error()),
+		)
+	}
+}
+
+// List implements domain.Service.
+func (s *Service) List(ctx context.Context, filter *domain.Filter) (*domain.PaginatedResponse[[]domain.Entry], error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultLimit
+	}
+	filter.Limit = min(filter.Limit, maxLimit)
+
+	entries, err := s.r.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &domain.PaginatedResponse[[]domain.Entry]{
+		Data: make([]domain.Entry, len(*entries)),
+	}
+
+	for i, e := range *entries {
+		res.Data[i] = domain.Entry{
+			Id:        e.Id,
+			Actor:     e.Actor,
+			Action:    e.Action,
+			Target:    e.Target,
+			ClientIP:  e.ClientIP,
+			CreatedAt: e.CreatedAt,
+		}
+	}
+
+	if n := len(res.Data); n > 0 {
+		res.First = res.Data[0].Id
+		if n == filter.Limit {
+			res.Next = res.Data[n-1].Id
+		}
+	}
+
+	return res, nil
+}
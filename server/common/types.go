@@ -7,6 +7,7 @@ type DownloadInfo struct {
 	URL            string    `json:"webpage_url"` // Often webpage_url is the primary URL field from -J
 	OriginalURL    string    `json:"original_url,omitempty"` // The URL as initially requested
 	Title          string    `json:"title"`
+	Extractor      string    `json:"extractor,omitempty"` // yt-dlp extractor name, e.g. "youtube"
 	Thumbnail      string    `json:"thumbnail"`
 	Resolution     string    `json:"resolution,omitempty"`
 	Vcodec         string    `json:"vcodec,omitempty"`
@@ -3,34 +3,148 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	LogPath              string   `yaml:"log_path"`
-	EnableFileLogging    bool     `yaml:"enable_file_logging"`
-	BaseURL              string   `yaml:"base_url"`
-	Host                 string   `yaml:"host"`
-	Port                 int      `yaml:"port"`
-	DownloadPath         string   `yaml:"downloadPath"`
-	DownloaderPath       string   `yaml:"downloaderPath"`
-	RequireAuth          bool     `yaml:"require_auth"`
-	Username             string   `yaml:"username"`
-	Password             string   `yaml:"password"`
-	QueueSize            int      `yaml:"queue_size"`
-	LocalDatabasePath    string   `yaml:"local_database_path"`
-	SessionFilePath      string   `yaml:"session_file_path"`
-	path                 string   // private
-	UseOpenId            bool     `yaml:"use_openid"`
-	OpenIdProviderURL    string   `yaml:"openid_provider_url"`
-	OpenIdClientId       string   `yaml:"openid_client_id"`
-	OpenIdClientSecret   string   `yaml:"openid_client_secret"`
-	OpenIdRedirectURL    string   `yaml:"openid_redirect_url"`
-	OpenIdEmailWhitelist []string `yaml:"openid_email_whitelist"`
-	FrontendPath         string   `yaml:"frontend_path"`
-	AutoArchive          bool     `yaml:"auto_archive"`
+	LogPath              string        `yaml:"log_path"`
+	EnableFileLogging    bool          `yaml:"enable_file_logging"`
+	BaseURL              string        `yaml:"base_url"`
+	Host                 string        `yaml:"host"`
+	Port                 int           `yaml:"port"`
+	GRPCPort             int           `yaml:"grpc_port"` // 0 disables the gRPC server
+	DownloadPath         string        `yaml:"downloadPath"`
+	DownloaderPath       string        `yaml:"downloaderPath"`
+	RequireAuth          bool          `yaml:"require_auth"`
+	Username             string        `yaml:"username"`
+	Password             string        `yaml:"password"`
+	QueueSize            int           `yaml:"queue_size"`
+	LocalDatabasePath    string        `yaml:"local_database_path"`
+	SessionFilePath      string        `yaml:"session_file_path"`
+	path                 string        // private
+	UseOpenId            bool          `yaml:"use_openid"`
+	OpenIdProviderURL    string        `yaml:"openid_provider_url"`
+	OpenIdClientId       string        `yaml:"openid_client_id"`
+	OpenIdClientSecret   string        `yaml:"openid_client_secret"`
+	OpenIdRedirectURL    string        `yaml:"openid_redirect_url"`
+	OpenIdEmailWhitelist []string      `yaml:"openid_email_whitelist"`
+	OpenIdScopes         []string      `yaml:"openid_scopes"` // requested along with openid, profile and email
+	OpenIdRoles          []OpenIdRole  `yaml:"openid_roles"`
+	OpenIdDefaultRole    string        `yaml:"openid_default_role"`
+	FrontendPath         string        `yaml:"frontend_path"`
+	AutoArchive          bool          `yaml:"auto_archive"`
+	Retry                RetryPolicy   `yaml:"retry"`
+	SiteLimits           []SiteLimit   `yaml:"site_limits"`
+	Bandwidth            Bandwidth     `yaml:"bandwidth"`
+	Quotas               Quotas        `yaml:"quotas"`
+	TrustedProxies       []string      `yaml:"trusted_proxies"` // addresses or CIDRs whose X-Forwarded-For is honoured
+	LoginThrottle        LoginThrottle `yaml:"login_throttle"`
+	RateLimit            RateLimit     `yaml:"rate_limit"`
+	Subscriptions        Subscriptions `yaml:"subscriptions"`
+}
+
+// Defines how failed downloads are retried
+type RetryPolicy struct {
+	MaxAttempts int           `yaml:"max_attempts"` // including the first one, 1 disables retries
+	BackoffBase time.Duration `yaml:"backoff_base"` // delay before the first retry, doubled at each attempt
+	BackoffMax  time.Duration `yaml:"backoff_max"`
+	Jitter      float64       `yaml:"jitter"`   // fraction of the delay randomly added or subtracted
+	RetryOn     []string      `yaml:"retry_on"` // retryable error classes
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BackoffBase: time.Second * 30,
+		BackoffMax:  time.Minute * 30,
+		Jitter:      0.2,
+		RetryOn:     []string{"rate_limited", "server_error", "network"},
+	}
+}
+
+// Politeness rules for the downloads of a set of sites, on top of the global
+// queue_size limit.
+type SiteLimit struct {
+	Domains       []string      `yaml:"domains"`        // also matches subdomains
+	Extractors    []string      `yaml:"extractors"`     // yt-dlp extractor names, known once metadata is retrieved
+	MaxConcurrent int           `yaml:"max_concurrent"` // 0 means no limit
+	MinGap        time.Duration `yaml:"min_gap"`        // minimum time between two starts
+	MaxPerHour    int           `yaml:"max_per_hour"`   // 0 means no limit
+}
+
+// Global download bandwidth budget, split among the active downloads.
+// Rates are expressed like yt-dlp --limit-rate, e.g. "500K" or "2M".
+type Bandwidth struct {
+	Limit     string              `yaml:"limit"` // empty or "0" means unlimited
+	Schedules []BandwidthSchedule `yaml:"schedules"`
+}
+
+// Overrides the bandwidth limit during a time of the day.
+type BandwidthSchedule struct {
+	From  string   `yaml:"from"` // "15:04" local time
+	To    string   `yaml:"to"`   // may be earlier than from to span midnight
+	Days  []string `yaml:"days"` // "mon", "tue", ... empty means every day
+	Limit string   `yaml:"limit"`
+}
+
+// Limits applied to each user when authentication is enabled, 0 or empty
+// means no limit.
+type Quotas struct {
+	MaxConcurrent     int    `yaml:"max_concurrent"`      // downloads running at the same time
+	MaxStorage        string `yaml:"max_storage"`         // size of the user directory, e.g. "50G"
+	MaxDailyDownloads int    `yaml:"max_daily_downloads"` // downloads queued since midnight
+}
+
+// Limits the failed logins, the callers exceeding them are locked out.
+// A max of 0 disables the respective limit.
+type LoginThrottle struct {
+	Window         time.Duration `yaml:"window"`           // in which the failures are counted
+	MaxPerIP       int           `yaml:"max_per_ip"`       // failures from an address
+	MaxPerUsername int           `yaml:"max_per_username"` // failures for an account
+	Lockout        time.Duration `yaml:"lockout"`          // the first one, doubled at each further one
+	MaxLockout     time.Duration `yaml:"max_lockout"`
+}
+
+func DefaultLoginThrottle() LoginThrottle {
+	return LoginThrottle{
+		Window:         time.Minute * 15,
+		MaxPerIP:       20,
+		MaxPerUsername: 5,
+		Lockout:        time.Minute,
+		MaxLockout:     time.Hour,
+	}
+}
+
+// Requests allowed to each api token or user, or address without
+// authentication, on the api and rpc routes. A rate of 0 means no limit.
+type RateLimit struct {
+	Requests float64 `yaml:"requests"` // per second
+	Burst    int     `yaml:"burst"`
+}
+
+// How far the subscriptions look for the videos uploaded since their last run
+type Subscriptions struct {
+	MaxPerRun int `yaml:"max_per_run"` // videos queued by a run, 0 means no limit
+	ScanDepth int `yaml:"scan_depth"`  // entries of the channel looked through
+}
+
+func DefaultSubscriptions() Subscriptions {
+	return Subscriptions{
+		MaxPerRun: 10,
+		ScanDepth: 50,
+	}
+}
+
+// Grants a role to the openid users whose claim contains the value, the claim
+// of a nested object is given as a dotted path, e.g. "realm_access.roles".
+type OpenIdRole struct {
+	Claim string `yaml:"claim"`
+	Value string `yaml:"value"`
+	Role  string `yaml:"role"`
 }
 
 var (
@@ -66,5 +180,33 @@ func (c *Config) LoadFile(filename string) error {
 // Path of the directory containing the config file
 func (c *Config) Dir() string { return filepath.Dir(c.path) }
 
+// Download directory of a user, a subdirectory of the download root named
+// after them. Without an owner, that is without authentication, it's the
+// download root itself.
+func (c *Config) UserDownloadPath(owner string) string {
+	if owner == "" {
+		return c.DownloadPath
+	}
+
+	dir := strings.NewReplacer("/", "_", "\\", "_").Replace(owner)
+	if dir == "." || dir == ".." {
+		dir = "_"
+	}
+
+	return filepath.Join(c.DownloadPath, dir)
+}
+
+// The yt-dlp download archive of a user, which the downloads of each user
+// are recorded in apart so that they don't skip each other's. Without an
+// owner it's the archive.txt shared by everyone.
+func (c *Config) UserArchivePath(owner string) string {
+	if owner == "" {
+		return filepath.Join(c.Dir(), "archive.txt")
+	}
+
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(owner)
+	return filepath.Join(c.Dir(), "archive-"+name+".txt")
+}
+
 // Absolute path of the config file
 func (c *Config) Path() string { return c.path }
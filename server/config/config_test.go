@@ -0,0 +1,33 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestUserPaths(t *testing.T) {
+	c := &Config{DownloadPath: "/downloads", path: "/config/config.yml"}
+
+	tests := []struct {
+		owner        string
+		wantDownload string
+		wantArchive  string
+	}{
+		{owner: "", wantDownload: "/downloads", wantArchive: "/config/archive.txt"},
+		{owner: "alice", wantDownload: "/downloads/alice", wantArchive: "/config/archive-alice.txt"},
+		{owner: "oidc:1234", wantDownload: "/downloads/oidc:1234", wantArchive: "/config/archive-oidc:1234.txt"},
+		{owner: "../bob", wantDownload: "/downloads/.._bob", wantArchive: "/config/archive-.._bob.txt"},
+		{owner: "..", wantDownload: "/downloads/_", wantArchive: "/config/archive-...txt"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.owner, func(t *testing.T) {
+			if got := c.UserDownloadPath(tc.owner); got != filepath.FromSlash(tc.wantDownload) {
+				t.Errorf("UserDownloadPath() = %s, want %s", got, tc.wantDownload)
+			}
+			if got := c.UserArchivePath(tc.owner); got != filepath.FromSlash(tc.wantArchive) {
+				t.Errorf("UserArchivePath() = %s, want %s", got, tc.wantArchive)
+			}
+		})
+	}
+}
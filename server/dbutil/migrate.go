@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 )
@@ -51,16 +52,210 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 
 	if _, err := db.ExecContext(
 		ctx,
-		`CREATE TABLE IF NOT EXISTS subscriptions (
+		"CREATE TABLE IF NOT EXISTS subscriptions "+subscriptionsTable,
+	); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS jobs (
+			id CHAR(36) PRIMARY KEY,
+			url VARCHAR(2048) NOT NULL,
+			status INTEGER NOT NULL,
+			priority INTEGER NOT NULL DEFAULT 0,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_retry_at DATETIME,
+			last_error TEXT,
+			error_class VARCHAR(32),
+			auto_remove BOOLEAN NOT NULL DEFAULT 0,
+			rate_limit INTEGER NOT NULL DEFAULT 0,
+			scheduled_at DATETIME,
+			params TEXT,
+			info TEXT,
+			output TEXT,
+			progress TEXT,
+			preferred_formats TEXT,
+			preferred_qualities TEXT,
+			updated_at DATETIME
+		)`,
+	); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id CHAR(36) PRIMARY KEY,
+			url VARCHAR(2048) NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT NOT NULL DEFAULT '',
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			created_at DATETIME
+		)`,
+	); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+			id CHAR(36) PRIMARY KEY,
+			webhook_id CHAR(36) NOT NULL,
+			event VARCHAR(32) NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER NOT NULL,
+			last_error TEXT,
+			created_at DATETIME
+		)`,
+	); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS users (
+			id CHAR(36) PRIMARY KEY,
+			username VARCHAR(255) UNIQUE NOT NULL,
+			password_hash TEXT NOT NULL,
+			role VARCHAR(16) NOT NULL,
+			created_at DATETIME
+		)`,
+	); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			id CHAR(36) PRIMARY KEY,
+			user_id CHAR(36) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			token_hash CHAR(64) UNIQUE NOT NULL,
+			scopes TEXT NOT NULL,
+			last_used_at DATETIME,
+			created_at DATETIME
+		)`,
+	); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS daily_downloads (
+			owner VARCHAR(255) NOT NULL,
+			day CHAR(10) NOT NULL,
+			count INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (owner, day)
+		)`,
+	); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS openid_sessions (
 			id CHAR(36) PRIMARY KEY,
-			url VARCHAR(2048) UNIQUE NOT NULL,
-			params TEXT NOT NULL,
-			cron TEXT
+			token_hash CHAR(64) UNIQUE NOT NULL,
+			username VARCHAR(255) NOT NULL,
+			role VARCHAR(16) NOT NULL,
+			refresh_token TEXT NOT NULL,
+			expires_at DATETIME NOT NULL,
+			created_at DATETIME NOT NULL
 		)`,
 	); err != nil {
 		return err
 	}
 
+	if _, err := db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor VARCHAR(255) NOT NULL,
+			action VARCHAR(64) NOT NULL,
+			target TEXT NOT NULL,
+			client_ip VARCHAR(64) NOT NULL,
+			created_at DATETIME NOT NULL
+		)`,
+	); err != nil {
+		return err
+	}
+
+	// the audit log is append-only, entries can't be altered or removed
+	for _, statement := range []string{"UPDATE", "DELETE"} {
+		if _, err := db.ExecContext(
+			ctx,
+			`CREATE TRIGGER IF NOT EXISTS audit_log_no_`+strings.ToLower(statement)+`
+			BEFORE `+statement+` ON audit_log
+			BEGIN
+				SELECT RAISE(ABORT, 'audit_log is append-only');
+			END`,
+		); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.ExecContext(
+		ctx,
+		"CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor, id)",
+	); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS subscription_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			subscription_id CHAR(36) NOT NULL,
+			started_at DATETIME NOT NULL,
+			ended_at DATETIME NOT NULL,
+			outcome VARCHAR(16) NOT NULL,
+			found INTEGER NOT NULL DEFAULT 0,
+			enqueued INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT ''
+		)`,
+	); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(
+		ctx,
+		"CREATE INDEX IF NOT EXISTS subscription_runs_subscription_idx ON subscription_runs (subscription_id, id)",
+	); err != nil {
+		return err
+	}
+
+	// columns added after the tables were first created
+	columns := []struct{ table, column, definition string }{
+		{"archive", "duration", "INTEGER NOT NULL DEFAULT 0"},
+		{"archive", "format", "VARCHAR(32) NOT NULL DEFAULT ''"},
+		{"archive", "owner", "VARCHAR(255) NOT NULL DEFAULT ''"},
+		{"archive", "subscription_id", "CHAR(36) NOT NULL DEFAULT ''"},
+		{"jobs", "owner", "VARCHAR(255) NOT NULL DEFAULT ''"},
+		{"jobs", "subscription_id", "CHAR(36) NOT NULL DEFAULT ''"},
+		{"subscriptions", "owner", "VARCHAR(255) NOT NULL DEFAULT ''"},
+		{"subscriptions", "filters", "TEXT NOT NULL DEFAULT ''"},
+		{"subscriptions", "backfill", "INTEGER NOT NULL DEFAULT 0"},
+		{"subscriptions", "last_video_id", "TEXT NOT NULL DEFAULT ''"},
+		{"subscriptions", "last_upload_date", "CHAR(8) NOT NULL DEFAULT ''"},
+		{"subscriptions", "last_run_at", "DATETIME"},
+		{"subscriptions", "last_error", "TEXT NOT NULL DEFAULT ''"},
+		{"subscriptions", "consecutive_failures", "INTEGER NOT NULL DEFAULT 0"},
+		{"subscriptions", "output", "TEXT NOT NULL DEFAULT ''"},
+		{"subscriptions", "retention", "TEXT NOT NULL DEFAULT ''"},
+		{"templates", "owner", "VARCHAR(255) NOT NULL DEFAULT ''"},
+	}
+
+	for _, c := range columns {
+		if err := addColumn(ctx, db, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	if err := migrateSubscriptionsUnique(ctx, db); err != nil {
+		return err
+	}
+
 	if lockFileExists() {
 		return nil
 	}
@@ -77,6 +272,92 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 	return nil
 }
 
+// Every user can subscribe to a channel once, no matter who else follows it
+const subscriptionsTable = `(
+	id CHAR(36) PRIMARY KEY,
+	url VARCHAR(2048) NOT NULL,
+	params TEXT NOT NULL,
+	cron TEXT,
+	owner VARCHAR(255) NOT NULL DEFAULT '',
+	filters TEXT NOT NULL DEFAULT '',
+	backfill INTEGER NOT NULL DEFAULT 0,
+	last_video_id TEXT NOT NULL DEFAULT '',
+	last_upload_date CHAR(8) NOT NULL DEFAULT '',
+	last_run_at DATETIME,
+	last_error TEXT NOT NULL DEFAULT '',
+	consecutive_failures INTEGER NOT NULL DEFAULT 0,
+	output TEXT NOT NULL DEFAULT '',
+	retention TEXT NOT NULL DEFAULT '',
+	UNIQUE (owner, url)
+)`
+
+// The subscriptions used to have a unique url across all users. sqlite can't
+// drop a constraint so the table is rebuilt, keeping the rowids the cursors
+// are made of.
+func migrateSubscriptionsUnique(ctx context.Context, db *sql.DB) error {
+	var legacy bool
+
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM pragma_index_list('subscriptions') AS l
+			WHERE l."unique" AND l.origin = 'u'
+			AND (SELECT group_concat(name) FROM pragma_index_info(l.name)) = 'url'
+		)`,
+	).Scan(&legacy)
+	if err != nil || !legacy {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const columns = `id, url, params, cron, owner, filters, backfill, last_video_id, last_upload_date,
+		last_run_at, last_error, consecutive_failures, output, retention`
+
+	for _, statement := range []string{
+		"CREATE TABLE subscriptions_owner_url " + subscriptionsTable,
+		"INSERT INTO subscriptions_owner_url (rowid, " + columns + ") SELECT rowid, " + columns + " FROM subscriptions",
+		"DROP TABLE subscriptions",
+		"ALTER TABLE subscriptions_owner_url RENAME TO subscriptions",
+	} {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Adds a column to an existing table unless it's already there, sqlite has
+// no ADD COLUMN IF NOT EXISTS.
+func addColumn(ctx context.Context, db *sql.DB, table, column, definition string) error {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, "ALTER TABLE "+table+" ADD COLUMN "+column+" "+definition)
+	return err
+}
+
 func createLockFile() { os.Create(lockFilePath) }
 
 func lockFileExists() bool {
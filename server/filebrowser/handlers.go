@@ -13,12 +13,15 @@ import (
 	"regexp"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
 )
 
 /*
@@ -82,13 +85,22 @@ func walkDir(root string) (*[]DirectoryEntry, error) {
 	return &files, err
 }
 
+// Directory the caller can browse, users who aren't admins only get their own
+// download directory.
+func rootOf(r *http.Request) string {
+	if owner, ok := middlewares.RestrictedTo(r.Context()); ok {
+		return config.Instance().UserDownloadPath(owner)
+	}
+	return config.Instance().DownloadPath
+}
+
 type ListRequest struct {
 	SubDir  string `json:"subdir"`
 	OrderBy string `json:"orderBy"`
 }
 
 func ListDownloaded(w http.ResponseWriter, r *http.Request) {
-	root := config.Instance().DownloadPath
+	root := rootOf(r)
 	req := new(ListRequest)
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -96,7 +108,19 @@ func ListDownloaded(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	files, err := walkDir(filepath.Join(root, req.SubDir))
+	dir := filepath.Join(root, req.SubDir)
+	if !internal.IsWithin(root, dir) {
+		http.Error(w, "the path is outside of the download directory", http.StatusBadRequest)
+		return
+	}
+
+	// the directory of a user is created by their first download
+	if _, err := os.Stat(dir); os.IsNotExist(err) && dir == root {
+		json.NewEncoder(w).Encode([]DirectoryEntry{})
+		return
+	}
+
+	files, err := walkDir(dir)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -125,11 +149,18 @@ func DeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !internal.IsWithin(rootOf(r), req.Path) {
+		http.Error(w, "the path is outside of the download directory", http.StatusForbidden)
+		return
+	}
+
 	if err := os.Remove(req.Path); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	audit.Record(r.Context(), audit.ActionFileDelete, req.Path)
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode("ok")
 }
@@ -156,9 +187,11 @@ func SendFile(w http.ResponseWriter, r *http.Request) {
 
 	filename := string(decoded)
 
-	root := config.Instance().DownloadPath
-
-	if strings.Contains(filepath.Dir(filepath.Clean(filename)), filepath.Clean(root)) {
+	if internal.IsWithin(rootOf(r), filename) {
+		// players fetch a file in several ranges, only the first one is recorded
+		if rng := r.Header.Get("Range"); rng == "" || strings.HasPrefix(rng, "bytes=0-") {
+			audit.Record(r.Context(), audit.ActionFileDownload, filename)
+		}
 		http.ServeFile(w, r, filename)
 		return
 	}
@@ -188,9 +221,7 @@ func DownloadFile(w http.ResponseWriter, r *http.Request) {
 
 	filename := string(decoded)
 
-	root := config.Instance().DownloadPath
-
-	if strings.Contains(filepath.Dir(filepath.Clean(filename)), filepath.Clean(root)) {
+	if internal.IsWithin(rootOf(r), filename) {
 		w.Header().Add("Content-Disposition", "inline; filename=\""+filepath.Base(filename)+"\"")
 		w.Header().Set("Content-Type", "application/octet-stream")
 
@@ -200,6 +231,8 @@ func DownloadFile(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		audit.Record(r.Context(), audit.ActionFileDownload, filename)
+
 		io.Copy(w, fd)
 		return
 	}
@@ -209,7 +242,12 @@ func DownloadFile(w http.ResponseWriter, r *http.Request) {
 
 func BulkDownload(mdb *internal.MemoryDB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ps := slices.DeleteFunc(*mdb.All(), func(e internal.ProcessResponse) bool {
+		all := mdb.All()
+		if owner, ok := middlewares.RestrictedTo(r.Context()); ok {
+			all = mdb.AllOf(owner)
+		}
+
+		ps := slices.DeleteFunc(*all, func(e internal.ProcessResponse) bool {
 			return e.Progress.Status != internal.StatusCompleted
 		})
 
@@ -217,6 +255,8 @@ func BulkDownload(mdb *internal.MemoryDB) http.HandlerFunc {
 			return
 		}
 
+		audit.Record(r.Context(), audit.ActionBulkDownload, strconv.Itoa(len(ps))+" files")
+
 		zipWriter := zip.NewWriter(w)
 
 		w.Header().Add(
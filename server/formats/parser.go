@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
 )
 
 func ParseURL(url string) (*Metadata, error) {
@@ -14,6 +15,7 @@ func ParseURL(url string) (*Metadata, error) {
 
 	stdout, err := cmd.Output()
 	if err != nil {
+		err = internal.ClassifyExecError(err)
 		slog.Error("failed to retrieve metadata", slog.String("err", err.Error()))
 		return nil, err
 	}
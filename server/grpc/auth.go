@@ -0,0 +1,89 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/grpc/pb"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Scope needed to call a method, the ones not listed need the enqueue scope.
+var methodScopes = map[string]middlewares.Scope{
+	pb.Ytdlp_Progress_FullMethodName:      middlewares.ScopeRead,
+	pb.Ytdlp_Running_FullMethodName:       middlewares.ScopeRead,
+	pb.Ytdlp_WatchProgress_FullMethodName: middlewares.ScopeRead,
+	pb.Ytdlp_Kill_FullMethodName:          middlewares.ScopeDelete,
+	pb.Ytdlp_KillAll_FullMethodName:       middlewares.ScopeDelete,
+}
+
+// Same checks of the HTTP middlewares, the tokens are sent as metadata:
+// authorization (Bearer) or x-authentication for the login and api tokens,
+// oid-session for openid.
+// Returns the context carrying the identity and the address of the caller.
+func authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if p, ok := peer.FromContext(ctx); ok {
+		ctx = middlewares.WithClientIP(ctx, middlewares.RemoteIP(p.Addr.String()))
+	}
+
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	if middlewares.AuthEnabled() {
+		token := middlewares.BearerToken(first("authorization"))
+		if token == "" {
+			token = first("x-authentication")
+		}
+
+		identity, err := middlewares.Authenticate(ctx, token, first(middlewares.SessionCookie))
+		if err != nil {
+			return ctx, status.Error(codes.Unauthenticated, err.Error())
+		}
+		ctx = middlewares.WithIdentity(ctx, identity)
+	}
+
+	scope, ok := methodScopes[method]
+	if !ok {
+		scope = middlewares.ScopeEnqueue
+	}
+
+	if err := middlewares.Authorize(ctx, scope); err != nil {
+		return ctx, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return ctx, nil
+}
+
+func unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func streamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// Server stream carrying the identity of the caller
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context { return s.ctx }
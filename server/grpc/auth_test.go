@@ -0,0 +1,126 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/grpc/pb"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Resolves the api tokens from a map, the login tokens are never valid
+type fakeStore map[string]*middlewares.Identity
+
+func (s fakeStore) Resolve(ctx context.Context, username string) (*middlewares.Identity, error) {
+	return nil, errors.New("unknown user")
+}
+
+func (s fakeStore) ResolveToken(ctx context.Context, token string) (*middlewares.Identity, error) {
+	if identity, ok := s[token]; ok {
+		return identity, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func TestAuthenticate(t *testing.T) {
+	conf := config.Instance()
+	defer func(requireAuth, useOpenId bool) {
+		conf.RequireAuth, conf.UseOpenId = requireAuth, useOpenId
+	}(conf.RequireAuth, conf.UseOpenId)
+
+	middlewares.RegisterStore(fakeStore{
+		"ytdlp_viewer":   {Username: "viewer", Role: middlewares.RoleViewer},
+		"ytdlp_user":     {Username: "user", Role: middlewares.RoleUser},
+		"ytdlp_readonly": {Username: "user", Role: middlewares.RoleUser, Scopes: []middlewares.Scope{middlewares.ScopeRead}},
+	})
+	defer middlewares.RegisterStore(nil)
+
+	tests := []struct {
+		name         string
+		requireAuth  bool
+		md           metadata.MD
+		method       string
+		want         codes.Code
+		wantUsername string
+	}{
+		{
+			name:   "authentication disabled",
+			method: pb.Ytdlp_Exec_FullMethodName,
+			want:   codes.OK,
+		},
+		{
+			name:        "missing token",
+			requireAuth: true,
+			method:      pb.Ytdlp_Progress_FullMethodName,
+			want:        codes.Unauthenticated,
+		},
+		{
+			name:        "invalid token",
+			requireAuth: true,
+			md:          metadata.Pairs("authorization", "Bearer ytdlp_unknown"),
+			method:      pb.Ytdlp_Progress_FullMethodName,
+			want:        codes.Unauthenticated,
+		},
+		{
+			name:         "bearer token",
+			requireAuth:  true,
+			md:           metadata.Pairs("authorization", "Bearer ytdlp_user"),
+			method:       pb.Ytdlp_Exec_FullMethodName,
+			want:         codes.OK,
+			wantUsername: "user",
+		},
+		{
+			name:         "x-authentication",
+			requireAuth:  true,
+			md:           metadata.Pairs("x-authentication", "ytdlp_user"),
+			method:       pb.Ytdlp_Kill_FullMethodName,
+			want:         codes.OK,
+			wantUsername: "user",
+		},
+		{
+			name:         "read method",
+			requireAuth:  true,
+			md:           metadata.Pairs("authorization", "Bearer ytdlp_viewer"),
+			method:       pb.Ytdlp_WatchProgress_FullMethodName,
+			want:         codes.OK,
+			wantUsername: "viewer",
+		},
+		{
+			// the methods not listed need the enqueue scope
+			name:        "role without the scope",
+			requireAuth: true,
+			md:          metadata.Pairs("authorization", "Bearer ytdlp_viewer"),
+			method:      pb.Ytdlp_ExecPlaylist_FullMethodName,
+			want:        codes.PermissionDenied,
+		},
+		{
+			name:        "token without the scope",
+			requireAuth: true,
+			md:          metadata.Pairs("authorization", "Bearer ytdlp_readonly"),
+			method:      pb.Ytdlp_KillAll_FullMethodName,
+			want:        codes.PermissionDenied,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conf.RequireAuth, conf.UseOpenId = tc.requireAuth, false
+
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+
+			ctx, err := authenticate(ctx, tc.method)
+			if got := status.Code(err); got != tc.want {
+				t.Fatalf("authenticate() = %s (%v), want %s", got, err, tc.want)
+			}
+
+			if got := middlewares.Owner(ctx); err == nil && got != tc.wantUsername {
+				t.Errorf("caller = %q, want %q", got, tc.wantUsername)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/grpc/pb"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	ytdlpRPC "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC version of the JSON-RPC interface, defined in proto/yt-dlp.proto.
+// Calls are forwarded to the JSON-RPC service so both behave the same.
+type Server struct {
+	pb.UnimplementedYtdlpServer
+
+	svc *ytdlpRPC.Service
+	mdb *internal.MemoryDB
+}
+
+func NewServer(svc *ytdlpRPC.Service, mdb *internal.MemoryDB) *grpc.Server {
+	// the interceptors also record the address of the caller for the audit log,
+	// the checks pass when authentication is disabled
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryAuth),
+		grpc.StreamInterceptor(streamAuth),
+	)
+	pb.RegisterYtdlpServer(s, &Server{svc: svc, mdb: mdb})
+
+	return s
+}
+
+func (s *Server) Exec(ctx context.Context, req *pb.DownloadRequest) (*pb.ExecResponse, error) {
+	args, err := toDownloadRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var id string
+	if err := s.svc.Exec(ctx, args, &id); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.ExecResponse{Id: id}, nil
+}
+
+func (s *Server) ExecPlaylist(ctx context.Context, req *pb.DownloadRequest) (*pb.ExecResponse, error) {
+	args, err := toDownloadRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var id string
+	if err := s.svc.ExecPlaylist(ctx, args, &id); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.ExecResponse{Id: id}, nil
+}
+
+func (s *Server) Progress(ctx context.Context, req *pb.BaseRequest) (*pb.DownloadProgress, error) {
+	var progress internal.DownloadProgress
+	if err := s.svc.Progess(ctx, internal.DownloadRequest{Id: req.GetId()}, &progress); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return toProgress(progress), nil
+}
+
+func (s *Server) Running(_ *pb.Empty, stream grpc.ServerStreamingServer[pb.ProcessResponse]) error {
+	var running ytdlpRPC.Running
+	if err := s.svc.Running(stream.Context(), ytdlpRPC.NoArgs{}, &running); err != nil {
+		return toStatus(err)
+	}
+
+	for _, p := range running {
+		if err := stream.Send(toProcessResponse(&p)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) Kill(ctx context.Context, req *pb.BaseRequest) (*pb.ExecResponse, error) {
+	var id string
+	if err := s.svc.Kill(ctx, req.GetId(), &id); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.ExecResponse{Id: id}, nil
+}
+
+func (s *Server) KillAll(_ *pb.Empty, stream grpc.ServerStreamingServer[pb.ExecResponse]) error {
+	var killed string
+	if err := s.svc.KillAll(stream.Context(), ytdlpRPC.NoArgs{}, &killed); err != nil {
+		return toStatus(err)
+	}
+
+	for _, id := range strings.Split(killed, ", ") {
+		if id == "" {
+			continue
+		}
+		if err := stream.Send(&pb.ExecResponse{Id: id}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Streams the process events until the client goes away. If the client falls
+// behind the stream ends, it can be resumed from the last received sequence
+// number.
+func (s *Server) WatchProgress(req *pb.WatchRequest, stream grpc.ServerStreamingServer[pb.ProgressEvent]) error {
+	filter := internal.EventFilter{Ids: req.GetIds()}
+	if owner, ok := middlewares.RestrictedTo(stream.Context()); ok {
+		filter.Owner = owner
+	}
+	for _, st := range req.GetStatuses() {
+		filter.Statuses = append(filter.Statuses, int(st))
+	}
+
+	backlog, events := s.mdb.Events(stream.Context(), filter, req.GetSince())
+
+	for _, e := range backlog {
+		if err := stream.Send(toProgressEvent(&e)); err != nil {
+			return err
+		}
+	}
+
+	for e := range events {
+		if err := stream.Send(toProgressEvent(&e)); err != nil {
+			return err
+		}
+	}
+
+	if err := stream.Context().Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+
+	return status.Error(codes.ResourceExhausted, "client fell behind, resume from the last sequence number")
+}
+
+func toDownloadRequest(req *pb.DownloadRequest) (internal.DownloadRequest, error) {
+	args := internal.DownloadRequest{
+		Id:                 req.GetId(),
+		URL:                req.GetUrl(),
+		Params:             req.GetParams(),
+		Path:               req.GetPath(),
+		Rename:             req.GetRename(),
+		ChannelFolder:      req.GetChannelFolder(),
+		PreferredFormats:   req.GetPreferredFormats(),
+		PreferredQualities: req.GetPreferredQualities(),
+	}
+
+	var err error
+	if args.StartAt, err = parseTime(req.GetStartAt()); err != nil {
+		return args, err
+	}
+	if args.NotBefore, err = parseTime(req.GetNotBefore()); err != nil {
+		return args, err
+	}
+
+	return args, nil
+}
+
+func parseTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func toProgress(p internal.DownloadProgress) *pb.DownloadProgress {
+	return &pb.DownloadProgress{
+		Status:     int32(p.Status),
+		Percentage: p.Percentage,
+		Speed:      float32(p.Speed),
+		Eta:        float32(p.ETA),
+	}
+}
+
+func toProcessResponse(p *internal.ProcessResponse) *pb.ProcessResponse {
+	return &pb.ProcessResponse{
+		Id:       p.Id,
+		Progress: toProgress(p.Progress),
+		Info: &pb.DownloadInfo{
+			Url:         p.Info.URL,
+			Title:       p.Info.Title,
+			Thumbnail:   p.Info.Thumbnail,
+			Resolution:  p.Info.Resolution,
+			Size:        int32(p.Info.FilesizeApprox),
+			Vcodec:      p.Info.Vcodec,
+			Acodec:      p.Info.Acodec,
+			Extension:   p.Info.Ext,
+			OriginalURL: p.Info.OriginalURL,
+			CreatedAt:   p.Info.CreatedAt.Format(time.RFC3339),
+		},
+		Output: &pb.DownloadOutput{
+			Path:          p.Output.Path,
+			Filename:      p.Output.Filename,
+			SavedFilePath: p.Output.SavedFilePath,
+		},
+		Params: p.Params,
+	}
+}
+
+func toProgressEvent(e *internal.Event) *pb.ProgressEvent {
+	event := &pb.ProgressEvent{
+		Seq:    e.Seq,
+		Type:   string(e.Type),
+		Id:     e.Id,
+		Status: int32(e.Status),
+	}
+	if e.Progress != nil {
+		event.Progress = toProgress(*e.Progress)
+	}
+	if e.Process != nil {
+		event.Process = toProcessResponse(e.Process)
+	}
+	return event
+}
+
+func toStatus(err error) error {
+	var ytdlpErr *internal.YtDlpError
+	if errors.As(err, &ytdlpErr) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,283 @@
+package internal
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+var rateRe = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*([kmgt]?)(?:i?b)?(?:/s)?$`)
+
+// Parses a rate such as "500K" or "2M" into bytes per second, units are
+// powers of 1024 like in yt-dlp. An empty rate or "0" means no limit.
+func ParseRate(rate string) (int64, error) {
+	rate = strings.TrimSpace(rate)
+	if rate == "" {
+		return 0, nil
+	}
+
+	m := rateRe.FindStringSubmatch(rate)
+	if m == nil {
+		return 0, fmt.Errorf("invalid rate %q", rate)
+	}
+
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	exp := 0
+	if m[2] != "" {
+		exp = strings.Index("kmgt", strings.ToLower(m[2])) + 1
+	}
+
+	return int64(value * math.Pow(1024, float64(exp))), nil
+}
+
+// Current global bandwidth limit
+type BandwidthStatus struct {
+	Limit  int64  `json:"limit"`  // bytes per second, 0 means unlimited
+	Source string `json:"source"` // "override", "schedule" or "config"
+	Active int    `json:"active"` // downloads sharing the budget
+}
+
+type BandwidthRequest struct {
+	Limit *string `json:"limit"` // null removes the runtime override
+}
+
+type bandwidthSchedule struct {
+	from, to int // minutes since midnight
+	days     []time.Weekday
+	limit    int64
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseSchedule(s config.BandwidthSchedule) (bandwidthSchedule, error) {
+	var schedule bandwidthSchedule
+
+	from, err := time.Parse("15:04", s.From)
+	if err != nil {
+		return schedule, fmt.Errorf("invalid schedule start %q", s.From)
+	}
+	to, err := time.Parse("15:04", s.To)
+	if err != nil {
+		return schedule, fmt.Errorf("invalid schedule end %q", s.To)
+	}
+
+	schedule.from = from.Hour()*60 + from.Minute()
+	schedule.to = to.Hour()*60 + to.Minute()
+
+	for _, day := range s.Days {
+		wd, ok := weekdays[strings.ToLower(day)[:min(3, len(day))]]
+		if !ok {
+			return schedule, fmt.Errorf("invalid schedule day %q", day)
+		}
+		schedule.days = append(schedule.days, wd)
+	}
+
+	schedule.limit, err = ParseRate(s.Limit)
+	return schedule, err
+}
+
+func (s bandwidthSchedule) active(now time.Time) bool {
+	minutes := now.Hour()*60 + now.Minute()
+
+	if s.from <= s.to {
+		return minutes >= s.from && minutes < s.to && s.onDay(now)
+	}
+
+	// spans midnight, the part after midnight belongs to the previous day
+	if minutes >= s.from {
+		return s.onDay(now)
+	}
+	if minutes < s.to {
+		return s.onDay(now.AddDate(0, 0, -1))
+	}
+	return false
+}
+
+func (s bandwidthSchedule) onDay(t time.Time) bool {
+	return len(s.days) == 0 || slices.Contains(s.days, t.Weekday())
+}
+
+// Splits the global bandwidth budget among the active downloads.
+// yt-dlp cannot change its rate limit while running, so downloads are
+// restarted with the new limit when their share changes.
+type bandwidthScheduler struct {
+	mu        sync.Mutex
+	limit     int64
+	schedules []bandwidthSchedule
+	override  *int64 // set at runtime, takes precedence over the config
+	active    map[string]*Process
+	current   int64 // global limit the shares were last computed with
+}
+
+func newBandwidthScheduler(c config.Bandwidth) (*bandwidthScheduler, error) {
+	limit, err := ParseRate(c.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bandwidthScheduler{
+		limit:  limit,
+		active: make(map[string]*Process),
+	}
+
+	for _, s := range c.Schedules {
+		schedule, err := parseSchedule(s)
+		if err != nil {
+			return nil, errors.Join(errors.New("invalid bandwidth schedule"), err)
+		}
+		b.schedules = append(b.schedules, schedule)
+	}
+
+	return b, nil
+}
+
+// Global limit in effect at the given time and where it comes from.
+func (b *bandwidthScheduler) globalLimit(now time.Time) (int64, string) {
+	if b.override != nil {
+		return *b.override, "override"
+	}
+	for _, s := range b.schedules {
+		if s.active(now) {
+			return s.limit, "schedule"
+		}
+	}
+	return b.limit, "config"
+}
+
+func (b *bandwidthScheduler) status() BandwidthStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	limit, source := b.globalLimit(time.Now())
+
+	return BandwidthStatus{
+		Limit:  limit,
+		Source: source,
+		Active: len(b.active),
+	}
+}
+
+func (b *bandwidthScheduler) setOverride(limit *int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.override = limit
+	b.rebalance(time.Now(), nil)
+}
+
+// Assigns a rate limit to a process which is about to be started.
+func (b *bandwidthScheduler) started(p *Process) {
+	if p.Livestream {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.active[p.Id] = p
+	b.rebalance(time.Now(), p)
+}
+
+func (b *bandwidthScheduler) finished(p *Process) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.active[p.Id]; !ok {
+		return
+	}
+
+	delete(b.active, p.Id)
+	b.rebalance(time.Now(), nil)
+}
+
+// Rebalances the active downloads when a schedule starts or ends.
+func (b *bandwidthScheduler) tick(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if limit, _ := b.globalLimit(now); limit != b.current {
+		b.rebalance(now, nil)
+	}
+}
+
+// Downloads whose own limit is lower than their fair share leave the rest of
+// the budget to the others. Running downloads are restarted only if their
+// rate changes noticeably, the starting one just gets its rate.
+func (b *bandwidthScheduler) rebalance(now time.Time, starting *Process) {
+	global, source := b.globalLimit(now)
+	b.current = global
+
+	procs := slices.SortedFunc(maps.Values(b.active), func(a, c *Process) int {
+		return cmp.Compare(rateCap(a), rateCap(c))
+	})
+
+	remaining := global
+	for i, p := range procs {
+		rate := p.RateLimit
+		if global > 0 {
+			fair := remaining / int64(len(procs)-i)
+			if rate == 0 || rate > fair {
+				rate = fair
+			}
+			remaining -= rate
+		}
+
+		if p == starting {
+			p.appliedRate = rate
+			continue
+		}
+		if rateChanged(p.appliedRate, rate) {
+			p.appliedRate = rate
+			p.restart()
+		}
+	}
+
+	slog.Debug("rebalanced bandwidth",
+		slog.Int64("limit", global),
+		slog.String("source", source),
+		slog.Int("active", len(procs)),
+	)
+}
+
+// Per-job limit of a process, no limit sorts last.
+func rateCap(p *Process) int64 {
+	if p.RateLimit == 0 {
+		return math.MaxInt64
+	}
+	return p.RateLimit
+}
+
+// Changes within 10% aren't worth a restart.
+func rateChanged(old, new int64) bool {
+	if old == 0 || new == 0 {
+		return old != new
+	}
+	diff := old - new
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff*10 > old
+}
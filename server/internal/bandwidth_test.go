@@ -0,0 +1,246 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate    string
+		want    int64
+		wantErr bool
+	}{
+		{rate: "", want: 0},
+		{rate: "0", want: 0},
+		{rate: "500", want: 500},
+		{rate: "500K", want: 500 << 10},
+		{rate: "2M", want: 2 << 20},
+		{rate: "1.5m", want: 3 << 19},
+		{rate: "1G", want: 1 << 30},
+		{rate: " 2 MiB/s ", want: 2 << 20},
+		{rate: "2MB", want: 2 << 20},
+		{rate: "fast", wantErr: true},
+		{rate: "2X", wantErr: true},
+		{rate: "-1M", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.rate, func(t *testing.T) {
+			got, err := ParseRate(tc.rate)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseRate(%q) error = %v, want error %t", tc.rate, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ParseRate(%q) = %d, want %d", tc.rate, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBandwidthScheduleActive(t *testing.T) {
+	// january 1st 2024 is a monday
+	at := func(day int, clock string) time.Time {
+		t, _ := time.Parse("15:04", clock)
+		return time.Date(2024, 1, day, t.Hour(), t.Minute(), 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name     string
+		schedule config.BandwidthSchedule
+		now      time.Time
+		want     bool
+	}{
+		{name: "within", schedule: config.BandwidthSchedule{From: "09:00", To: "18:00"}, now: at(1, "12:00"), want: true},
+		{name: "at the start", schedule: config.BandwidthSchedule{From: "09:00", To: "18:00"}, now: at(1, "09:00"), want: true},
+		{name: "at the end", schedule: config.BandwidthSchedule{From: "09:00", To: "18:00"}, now: at(1, "18:00")},
+		{name: "before", schedule: config.BandwidthSchedule{From: "09:00", To: "18:00"}, now: at(1, "08:59")},
+		{name: "on one of its days", schedule: config.BandwidthSchedule{From: "09:00", To: "18:00", Days: []string{"Monday"}}, now: at(1, "12:00"), want: true},
+		{name: "on another day", schedule: config.BandwidthSchedule{From: "09:00", To: "18:00", Days: []string{"sat", "sun"}}, now: at(1, "12:00")},
+		{name: "spanning midnight, before it", schedule: config.BandwidthSchedule{From: "23:00", To: "07:00", Days: []string{"mon"}}, now: at(1, "23:30"), want: true},
+		// the part after midnight belongs to the day the schedule started on
+		{name: "spanning midnight, after it", schedule: config.BandwidthSchedule{From: "23:00", To: "07:00", Days: []string{"mon"}}, now: at(2, "06:00"), want: true},
+		{name: "spanning midnight, after it on the wrong day", schedule: config.BandwidthSchedule{From: "23:00", To: "07:00", Days: []string{"mon"}}, now: at(1, "06:00")},
+		{name: "spanning midnight, outside", schedule: config.BandwidthSchedule{From: "23:00", To: "07:00"}, now: at(1, "12:00")},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.schedule.Limit = "1M"
+
+			s, err := parseSchedule(tc.schedule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := s.active(tc.now); got != tc.want {
+				t.Errorf("active(%s) = %t, want %t", tc.now.Format("Mon 15:04"), got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewBandwidthScheduler(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.Bandwidth
+		wantErr bool
+	}{
+		{name: "no limit"},
+		{name: "valid", config: config.Bandwidth{Limit: "2M", Schedules: []config.BandwidthSchedule{{From: "09:00", To: "18:00", Days: []string{"mon"}, Limit: "500K"}}}},
+		{name: "invalid limit", config: config.Bandwidth{Limit: "fast"}, wantErr: true},
+		{name: "invalid start", config: config.Bandwidth{Schedules: []config.BandwidthSchedule{{From: "9am", To: "18:00"}}}, wantErr: true},
+		{name: "invalid end", config: config.Bandwidth{Schedules: []config.BandwidthSchedule{{From: "09:00", To: "25:00"}}}, wantErr: true},
+		{name: "invalid day", config: config.Bandwidth{Schedules: []config.BandwidthSchedule{{From: "09:00", To: "18:00", Days: []string{"someday"}}}}, wantErr: true},
+		{name: "invalid schedule limit", config: config.Bandwidth{Schedules: []config.BandwidthSchedule{{From: "09:00", To: "18:00", Limit: "fast"}}}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newBandwidthScheduler(tc.config); (err != nil) != tc.wantErr {
+				t.Errorf("newBandwidthScheduler() error = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestBandwidthSchedulerGlobalLimit(t *testing.T) {
+	b, err := newBandwidthScheduler(config.Bandwidth{
+		Limit:     "2M",
+		Schedules: []config.BandwidthSchedule{{From: "09:00", To: "18:00", Limit: "500K"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		day   = time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+		night = time.Date(2024, 1, 1, 22, 0, 0, 0, time.Local)
+	)
+
+	if limit, source := b.globalLimit(night); limit != 2<<20 || source != "config" {
+		t.Errorf("limit at night = %d from %s, want %d from config", limit, source, 2<<20)
+	}
+	if limit, source := b.globalLimit(day); limit != 500<<10 || source != "schedule" {
+		t.Errorf("limit during the day = %d from %s, want %d from schedule", limit, source, 500<<10)
+	}
+
+	// the schedule starting is picked up by the next tick
+	b.tick(night)
+	b.tick(day)
+	if b.current != 500<<10 {
+		t.Errorf("limit after the tick = %d, want %d", b.current, 500<<10)
+	}
+
+	// the override wins over both until it's removed
+	unlimited := int64(0)
+	b.setOverride(&unlimited)
+	if limit, source := b.globalLimit(day); limit != 0 || source != "override" {
+		t.Errorf("limit with the override = %d from %s, want 0 from override", limit, source)
+	}
+
+	b.setOverride(nil)
+	if _, source := b.globalLimit(night); source != "config" {
+		t.Errorf("limit from %s after removing the override, want config", source)
+	}
+}
+
+func TestBandwidthSchedulerShares(t *testing.T) {
+	b, err := newBandwidthScheduler(config.Bandwidth{Limit: "3M"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		capped     = &Process{Id: "capped", RateLimit: 512 << 10}
+		first      = &Process{Id: "first"}
+		second     = &Process{Id: "second"}
+		livestream = &Process{Id: "livestream", Livestream: true}
+	)
+
+	tests := []struct {
+		name   string
+		change func()
+		want   map[*Process]int64
+		active int
+	}{
+		{
+			name:   "alone",
+			change: func() { b.started(first) },
+			want:   map[*Process]int64{first: 3 << 20},
+			active: 1,
+		},
+		{
+			name:   "shared equally",
+			change: func() { b.started(second) },
+			want:   map[*Process]int64{first: 3 << 19, second: 3 << 19},
+			active: 2,
+		},
+		{
+			// the capped download leaves what it doesn't use to the others
+			name:   "below its fair share",
+			change: func() { b.started(capped) },
+			want:   map[*Process]int64{capped: 512 << 10, first: 1280 << 10, second: 1280 << 10},
+			active: 3,
+		},
+		{
+			name:   "livestreams aren't limited",
+			change: func() { b.started(livestream) },
+			want:   map[*Process]int64{capped: 512 << 10, first: 1280 << 10, second: 1280 << 10, livestream: 0},
+			active: 3,
+		},
+		{
+			name:   "finished",
+			change: func() { b.finished(second) },
+			want:   map[*Process]int64{capped: 512 << 10, first: 2560 << 10},
+			active: 2,
+		},
+		{
+			name: "unlimited override",
+			change: func() {
+				unlimited := int64(0)
+				b.setOverride(&unlimited)
+			},
+			want:   map[*Process]int64{capped: 512 << 10, first: 0},
+			active: 2,
+		},
+	}
+
+	// each step builds on the previous ones
+	for _, tc := range tests {
+		tc.change()
+
+		for p, want := range tc.want {
+			if p.appliedRate != want {
+				t.Errorf("%s: %s rate = %d, want %d", tc.name, p.Id, p.appliedRate, want)
+			}
+		}
+		if got := b.status().Active; got != tc.active {
+			t.Errorf("%s: %d active downloads, want %d", tc.name, got, tc.active)
+		}
+	}
+}
+
+func TestRateChanged(t *testing.T) {
+	tests := []struct {
+		old, new int64
+		want     bool
+	}{
+		{old: 1000, new: 1000},
+		{old: 1000, new: 950},
+		{old: 1000, new: 1100},
+		{old: 1000, new: 800, want: true},
+		{old: 1000, new: 1200, want: true},
+		{old: 0, new: 1000, want: true},
+		{old: 1000, new: 0, want: true},
+		{old: 0, new: 0},
+	}
+
+	for _, tc := range tests {
+		if got := rateChanged(tc.old, tc.new); got != tc.want {
+			t.Errorf("rateChanged(%d, %d) = %t, want %t", tc.old, tc.new, got, tc.want)
+		}
+	}
+}
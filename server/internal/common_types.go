@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"time"
+
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/common"
 )
 
@@ -21,6 +23,7 @@ type DownloadOutput struct {
 	Path          string
 	Filename      string
 	SavedFilePath string `json:"savedFilePath"`
+	ChannelFolder string `json:"channelFolder,omitempty"`
 }
 
 // Progress for the Running call
@@ -34,15 +37,28 @@ type DownloadProgress struct {
 // struct representing the response sent to the client
 // as JSON-RPC result field
 type ProcessResponse struct {
-	Id       string              `json:"id"`
-	Progress DownloadProgress    `json:"progress"`
-	Info     common.DownloadInfo `json:"info"`
-	Output   DownloadOutput      `json:"output"`
-	Params   []string            `json:"params"`
+	Id            string              `json:"id"`
+	Owner         string              `json:"owner,omitempty"`
+	Progress      DownloadProgress    `json:"progress"`
+	Info          common.DownloadInfo `json:"info"`
+	Output        DownloadOutput      `json:"output"`
+	Params        []string            `json:"params"`
+	Priority      int                 `json:"priority"`
+	QueuePosition int                 `json:"queuePosition"` // 1-based, 0 if not queued
+	Attempts      int                 `json:"attempts"`
+	NextRetryAt   *time.Time          `json:"nextRetryAt,omitempty"`
+	LastError     string              `json:"lastError,omitempty"`
+	ErrorClass    ErrorClass          `json:"errorClass,omitempty"`
+	RateLimit     int64               `json:"rateLimit,omitempty"`   // requested, bytes per second
+	AppliedRate   int64               `json:"appliedRate,omitempty"` // assigned by the bandwidth scheduler
+	ScheduledAt   *time.Time          `json:"scheduledAt,omitempty"`
+
+	PreferredFormats   []string `json:"preferredFormats,omitempty"`
+	PreferredQualities []string `json:"preferredQualities,omitempty"`
 }
 
-// struct representing the current status of the memoryDB
-// used for serializaton/persistence reasons
+// struct representing the status of the memoryDB as persisted by older
+// versions in session.dat, only used to import it into the job store
 type Session struct {
 	Processes []ProcessResponse `json:"processes"`
 }
@@ -54,13 +70,37 @@ type AbortRequest struct {
 
 // struct representing the intent to start a download
 type DownloadRequest struct {
-    URL    string   `json:"url"`
-    Params []string `json:"params"` // For raw yt-dlp params
-    Path   string   `json:"path,omitempty"`
-    Rename string   `json:"rename,omitempty"`
-    ChannelFolder string `json:"channel_folder,omitempty"`
-    PreferredFormats []string `json:"preferred_formats,omitempty"`   // New
-    PreferredQualities []string `json:"preferred_qualities,omitempty"` // New
+	Id                 string   `json:"id"`
+	URL                string   `json:"url"`
+	Params             []string `json:"params"` // For raw yt-dlp params
+	Path               string   `json:"path,omitempty"`
+	Rename             string   `json:"rename,omitempty"`
+	ChannelFolder      string   `json:"channel_folder,omitempty"`
+	PreferredFormats   []string `json:"preferred_formats,omitempty"`   // New
+	PreferredQualities []string `json:"preferred_qualities,omitempty"` // New
+	Priority           int      `json:"priority"`                      // -1 low, 0 normal, 1 high
+	RateLimit          string   `json:"rate_limit,omitempty"`          // e.g. "500K", "2M"
+	Owner              string   `json:"-"`                             // set from the caller identity
+
+	// The download is held until the given time. They're synonyms, if both
+	// are set the later one is used.
+	StartAt   *time.Time `json:"start_at,omitempty"`
+	NotBefore *time.Time `json:"not_before,omitempty"`
+}
+
+// Time until which the requested download has to be held, nil if it can
+// start right away.
+func (r DownloadRequest) ScheduledAt() *time.Time {
+	if r.StartAt == nil || (r.NotBefore != nil && r.NotBefore.After(*r.StartAt)) {
+		return r.NotBefore
+	}
+	return r.StartAt
+}
+
+// struct representing the intent to change the priority of a pending process
+type PriorityRequest struct {
+	Id       string `json:"id"`
+	Priority int    `json:"priority"`
 }
 
 // struct representing request of creating a netscape cookies file
@@ -73,4 +113,5 @@ type CustomTemplate struct {
 	Id      string `json:"id"`
 	Name    string `json:"name"`
 	Content string `json:"content"`
+	Owner   string `json:"owner,omitempty"` // empty for the templates shared with everyone
 }
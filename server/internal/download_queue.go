@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"container/heap"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+// Priority levels of a download. The zero value is the normal priority so
+// requests which do not specify one are queued as usual.
+const (
+	PriorityLow = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+var ErrInvalidPriority = errors.New("invalid priority")
+
+// Checks that priority is one of the defined levels.
+func ValidatePriority(priority int) error {
+	if priority < PriorityLow || priority > PriorityHigh {
+		return ErrInvalidPriority
+	}
+	return nil
+}
+
+type queueItem struct {
+	process  *Process
+	priority int
+	seq      uint64 // insertion order, used for FIFO within the same priority
+	index    int    // index in the heap
+}
+
+// queueHeap implements heap.Interface interface as a max priority queue,
+// ties are broken by insertion order.
+type queueHeap []*queueItem
+
+func (h queueHeap) Len() int { return len(h) }
+
+func (h queueHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+
+func (h queueHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *queueHeap) Push(x any) {
+	item := x.(*queueItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *queueHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	x.index = -1
+	*h = old[0 : n-1]
+	return x
+}
+
+func (i *queueItem) before(other *queueItem) bool {
+	if i.priority != other.priority {
+		return i.priority > other.priority
+	}
+	return i.seq < other.seq
+}
+
+// Thread-safe priority queue of the processes waiting for a download slot.
+// It also keeps track of the active downloads in order to enforce the
+// configured concurrency, the per-site and the per-user limits.
+type downloadQueue struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	items queueHeap
+	byId  map[string]*queueItem
+	seq   uint64
+
+	limit      int // max concurrent downloads
+	active     int // downloads currently holding a slot
+	sites      *siteLimiter
+	ownerLimit int            // max concurrent downloads of each user, 0 means no limit
+	owners     map[string]int // user -> downloads currently holding a slot
+}
+
+func newDownloadQueue(limit int, sites []config.SiteLimit, ownerLimit int) *downloadQueue {
+	q := &downloadQueue{
+		byId:       make(map[string]*queueItem),
+		limit:      limit,
+		sites:      newSiteLimiter(sites),
+		ownerLimit: ownerLimit,
+		owners:     make(map[string]int),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Enqueue a process with its own priority.
+func (q *downloadQueue) push(p *Process) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if old, ok := q.byId[p.Id]; ok {
+		heap.Remove(&q.items, old.index)
+	}
+
+	q.seq++
+	item := &queueItem{
+		process:  p,
+		priority: p.Priority,
+		seq:      q.seq,
+	}
+	heap.Push(&q.items, item)
+	q.byId[p.Id] = item
+
+	q.updatePositions()
+	q.cond.Broadcast()
+}
+
+// Blocks until a process can be started, then removes it from the queue.
+// A process can be started if there's a free download slot and the limits of
+// its site and of its owner allow it, livestreams can always be started since
+// they cannot be postponed.
+func (q *downloadQueue) pop() *Process {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		now := time.Now()
+
+		i, wakeAt := q.next(now)
+		if i >= 0 {
+			item := heap.Remove(&q.items, i).(*queueItem)
+			delete(q.byId, item.process.Id)
+
+			if !item.process.Livestream {
+				q.active++
+				q.sites.started(item.process, now)
+				q.owners[item.process.Owner]++
+			}
+
+			item.process.QueuePosition = 0
+			q.updatePositions()
+
+			return item.process
+		}
+
+		if wakeAt.IsZero() {
+			q.cond.Wait()
+			continue
+		}
+
+		// a site delay expires before anything else may happen
+		t := time.AfterFunc(time.Until(wakeAt), q.cond.Broadcast)
+		q.cond.Wait()
+		t.Stop()
+	}
+}
+
+// Index of the first dispatchable item in priority order, -1 if none.
+// Items waiting for their site limits are skipped so they don't hold back
+// downloads from other sites, in that case it also returns the earliest time
+// one of them may become dispatchable.
+func (q *downloadQueue) next(now time.Time) (int, time.Time) {
+	var (
+		best   = -1
+		wakeAt time.Time
+	)
+
+	for i, item := range q.items {
+		if best >= 0 && !item.before(q.items[best]) {
+			continue
+		}
+
+		if !item.process.Livestream {
+			if q.active >= q.limit {
+				continue
+			}
+
+			if owner := item.process.Owner; owner != "" && q.ownerLimit > 0 && q.owners[owner] >= q.ownerLimit {
+				continue
+			}
+
+			ok, at := q.sites.allow(item.process, now)
+			if !ok {
+				if !at.IsZero() && (wakeAt.IsZero() || at.Before(wakeAt)) {
+					wakeAt = at
+				}
+				continue
+			}
+		}
+
+		best = i
+	}
+
+	return best, wakeAt
+}
+
+// Release the download slot held by a process.
+func (q *downloadQueue) done(p *Process) {
+	if p.Livestream {
+		return
+	}
+
+	q.mu.Lock()
+	q.active--
+	q.sites.finished(p)
+	if q.owners[p.Owner]--; q.owners[p.Owner] <= 0 {
+		delete(q.owners, p.Owner)
+	}
+	q.mu.Unlock()
+
+	q.cond.Broadcast()
+}
+
+// Change the priority of a queued process.
+func (q *downloadQueue) update(id string, priority int) (*Process, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.byId[id]
+	if !ok {
+		return nil, errors.New("process is not in the download queue")
+	}
+
+	// join the back of the new priority level
+	q.seq++
+	item.seq = q.seq
+	item.priority = priority
+	item.process.Priority = priority
+	heap.Fix(&q.items, item.index)
+
+	q.updatePositions()
+	q.cond.Broadcast()
+
+	return item.process, nil
+}
+
+// Remove a process from the queue, if present.
+func (q *downloadQueue) remove(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.byId[id]
+	if !ok {
+		return
+	}
+
+	heap.Remove(&q.items, item.index)
+	delete(q.byId, id)
+	item.process.QueuePosition = 0
+
+	q.updatePositions()
+}
+
+// The heap is only partially ordered, sort a copy to assign each queued
+// process its 1-based position.
+func (q *downloadQueue) updatePositions() {
+	sorted := slices.Clone(q.items)
+	slices.SortFunc(sorted, func(a, b *queueItem) int {
+		if a.before(b) {
+			return -1
+		}
+		return 1
+	})
+	for i, item := range sorted {
+		item.process.QueuePosition = i + 1
+	}
+}
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+)
+
+// An operation on the download queue
+type queueOp struct {
+	op       string // push, update or remove
+	id       string
+	priority int
+}
+
+func TestDownloadQueue(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []queueOp
+		want []string // ids in the order they're popped
+	}{
+		{
+			name: "fifo within the same priority",
+			ops: []queueOp{
+				{"push", "a", PriorityNormal},
+				{"push", "b", PriorityNormal},
+				{"push", "c", PriorityNormal},
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "priority order",
+			ops: []queueOp{
+				{"push", "low", PriorityLow},
+				{"push", "normal", PriorityNormal},
+				{"push", "high", PriorityHigh},
+			},
+			want: []string{"high", "normal", "low"},
+		},
+		{
+			name: "fifo within each priority",
+			ops: []queueOp{
+				{"push", "n1", PriorityNormal},
+				{"push", "h1", PriorityHigh},
+				{"push", "l1", PriorityLow},
+				{"push", "n2", PriorityNormal},
+				{"push", "h2", PriorityHigh},
+				{"push", "l2", PriorityLow},
+			},
+			want: []string{"h1", "h2", "n1", "n2", "l1", "l2"},
+		},
+		{
+			name: "raised priority",
+			ops: []queueOp{
+				{"push", "a", PriorityNormal},
+				{"push", "b", PriorityNormal},
+				{"push", "c", PriorityNormal},
+				{"update", "c", PriorityHigh},
+			},
+			want: []string{"c", "a", "b"},
+		},
+		{
+			name: "lowered priority",
+			ops: []queueOp{
+				{"push", "a", PriorityNormal},
+				{"push", "b", PriorityNormal},
+				{"push", "c", PriorityLow},
+				{"update", "a", PriorityLow},
+			},
+			want: []string{"b", "c", "a"},
+		},
+		{
+			name: "reprioritized joins the back of its level",
+			ops: []queueOp{
+				{"push", "a", PriorityHigh},
+				{"push", "b", PriorityNormal},
+				{"push", "c", PriorityHigh},
+				{"update", "b", PriorityHigh},
+			},
+			want: []string{"a", "c", "b"},
+		},
+		{
+			name: "removed",
+			ops: []queueOp{
+				{"push", "a", PriorityNormal},
+				{"push", "b", PriorityHigh},
+				{"push", "c", PriorityNormal},
+				{"remove", "b", 0},
+			},
+			want: []string{"a", "c"},
+		},
+		{
+			name: "pushed again",
+			ops: []queueOp{
+				{"push", "a", PriorityNormal},
+				{"push", "b", PriorityNormal},
+				{"push", "a", PriorityNormal},
+			},
+			want: []string{"b", "a"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				q         = newDownloadQueue(len(tc.ops), nil, 0)
+				processes = make(map[string]*Process)
+				removed   []*Process
+			)
+
+			for _, op := range tc.ops {
+				switch op.op {
+				case "push":
+					p, ok := processes[op.id]
+					if !ok {
+						p = &Process{Id: op.id}
+						processes[op.id] = p
+					}
+					p.Priority = op.priority
+					q.push(p)
+				case "update":
+					if _, err := q.update(op.id, op.priority); err != nil {
+						t.Fatalf("update(%s): %v", op.id, err)
+					}
+				case "remove":
+					q.remove(op.id)
+					removed = append(removed, processes[op.id])
+				}
+			}
+
+			// the positions are 1-based and follow the order of the queue
+			for i, id := range tc.want {
+				if got := processes[id].QueuePosition; got != i+1 {
+					t.Errorf("position of %s = %d, want %d", id, got, i+1)
+				}
+			}
+			for _, p := range removed {
+				if p.QueuePosition != 0 {
+					t.Errorf("position of removed %s = %d, want 0", p.Id, p.QueuePosition)
+				}
+			}
+
+			var got []string
+			for range tc.want {
+				p := q.pop()
+				got = append(got, p.Id)
+
+				// the ones left move up by one
+				for i, id := range tc.want[len(got):] {
+					if pos := processes[id].QueuePosition; pos != i+1 {
+						t.Errorf("after popping %s, position of %s = %d, want %d", p.Id, id, pos, i+1)
+					}
+				}
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("popped %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDownloadQueueUpdateMissing(t *testing.T) {
+	q := newDownloadQueue(1, nil, 0)
+
+	if _, err := q.update("missing", PriorityHigh); err == nil {
+		t.Error("update of a process which isn't queued succeeded")
+	}
+}
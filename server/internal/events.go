@@ -0,0 +1,255 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archiver"
+)
+
+// Number of events kept to let clients resume after a reconnection
+const eventHistorySize = 2000
+
+// Min interval between two progress events of the same process, status
+// changes are always sent
+const progressEventInterval = 500 * time.Millisecond
+
+type EventType string
+
+const (
+	EventCreated   EventType = "created"
+	EventMetadata  EventType = "metadata"
+	EventProgress  EventType = "progress"
+	EventCompleted EventType = "completed"
+	EventErrored   EventType = "errored"
+	EventRemoved   EventType = "removed"
+
+	// Sent to a resuming client when the requested events are no longer
+	// available, it should fetch the whole state again with Running.
+	EventResync EventType = "resync"
+)
+
+var eventTypes = []EventType{
+	EventCreated,
+	EventMetadata,
+	EventProgress,
+	EventCompleted,
+	EventErrored,
+	EventRemoved,
+}
+
+// A change of a process. Progress events only carry the progress, the other
+// ones carry the whole process.
+type Event struct {
+	Seq      uint64            `json:"seq"`
+	Type     EventType         `json:"type"`
+	Time     time.Time         `json:"time"`
+	Id       string            `json:"id,omitempty"`
+	Owner    string            `json:"owner,omitempty"`
+	Status   int               `json:"status"`
+	Progress *DownloadProgress `json:"progress,omitempty"`
+	Process  *ProcessResponse  `json:"process,omitempty"`
+	Archive  *archiver.Message `json:"archive,omitempty"` // only sent with completed events
+}
+
+// Restricts the events received by a subscriber, empty fields match
+// everything.
+type EventFilter struct {
+	Ids      []string
+	Statuses []int
+	Types    []EventType
+	Owner    string // when set only the events of the processes of this user
+}
+
+// Builds a filter from the names used by the clients, statuses can be given
+// either by name or by value.
+func NewEventFilter(ids, statuses, types []string) (EventFilter, error) {
+	filter := EventFilter{Ids: ids}
+
+	for _, s := range statuses {
+		status, err := parseStatus(s)
+		if err != nil {
+			return filter, err
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	for _, t := range types {
+		if !slices.Contains(eventTypes, EventType(t)) {
+			return filter, fmt.Errorf("unknown event type %q", t)
+		}
+		filter.Types = append(filter.Types, EventType(t))
+	}
+
+	return filter, nil
+}
+
+func parseStatus(s string) (int, error) {
+	for status, name := range statusNames {
+		if strings.EqualFold(s, name) {
+			return status, nil
+		}
+	}
+	if status, err := strconv.Atoi(s); err == nil {
+		if _, ok := statusNames[status]; ok {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown status %q", s)
+}
+
+func (f EventFilter) match(e Event) bool {
+	if len(f.Ids) > 0 && !slices.Contains(f.Ids, e.Id) {
+		return false
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, e.Status) {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	if f.Owner != "" && f.Owner != e.Owner {
+		return false
+	}
+	return true
+}
+
+type eventSubscriber struct {
+	filter EventFilter
+	ch     chan Event
+}
+
+// Fan-out of the process events with a bounded history.
+// A subscriber which can't keep up is disconnected instead of blocking the
+// processes, it can resume from the last event it has received.
+type eventHub struct {
+	mu          sync.Mutex
+	seq         uint64
+	history     []Event
+	next        int // where the next event will be written
+	full        bool
+	subscribers map[*eventSubscriber]struct{}
+}
+
+var events = newEventHub(eventHistorySize)
+
+func newEventHub(size int) *eventHub {
+	return &eventHub{
+		history:     make([]Event, size),
+		subscribers: make(map[*eventSubscriber]struct{}),
+	}
+}
+
+func (h *eventHub) publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	e.Seq = h.seq
+	e.Time = time.Now()
+
+	h.history[h.next] = e
+	h.next = (h.next + 1) % len(h.history)
+	if h.next == 0 {
+		h.full = true
+	}
+
+	for s := range h.subscribers {
+		if !s.filter.match(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			delete(h.subscribers, s)
+			close(s.ch)
+		}
+	}
+}
+
+// Returns the events following since which match the filter, and a channel
+// receiving the next ones until ctx is done.
+// If since is no longer in the history, or is from before a restart, the
+// backlog starts with a resync event. since 0 means no backlog.
+func (h *eventHub) subscribe(ctx context.Context, filter EventFilter, since uint64) ([]Event, <-chan Event) {
+	s := &eventSubscriber{
+		filter: filter,
+		ch:     make(chan Event, 256),
+	}
+
+	h.mu.Lock()
+	backlog := h.backlogLocked(filter, since)
+	h.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		h.mu.Lock()
+		if _, ok := h.subscribers[s]; ok {
+			delete(h.subscribers, s)
+			close(s.ch)
+		}
+		h.mu.Unlock()
+	}()
+
+	return backlog, s.ch
+}
+
+func (h *eventHub) backlogLocked(filter EventFilter, since uint64) []Event {
+	if since == 0 || since == h.seq {
+		return nil
+	}
+
+	var history []Event
+	if h.full {
+		history = append(append(history, h.history[h.next:]...), h.history[:h.next]...)
+	} else {
+		history = h.history[:h.next]
+	}
+
+	if since > h.seq || len(history) == 0 || history[0].Seq > since+1 {
+		return []Event{{Seq: h.seq, Type: EventResync, Time: time.Now()}}
+	}
+
+	backlog := []Event{}
+	for _, e := range history {
+		if e.Seq > since && filter.match(e) {
+			backlog = append(backlog, e)
+		}
+	}
+	return backlog
+}
+
+// Publishes a process event, progress events are throttled.
+func (p *Process) emit(t EventType) {
+	p.emitEvent(Event{Type: t})
+}
+
+func (p *Process) emitEvent(e Event) {
+	e.Id = p.Id
+	e.Owner = p.Owner
+	e.Status = p.Progress.Status
+
+	if e.Type == EventProgress {
+		now := time.Now()
+		if p.Progress.Status == p.lastEventStatus && now.Sub(p.lastEventAt) < progressEventInterval {
+			return
+		}
+		p.lastEventAt = now
+
+		progress := p.Progress
+		e.Progress = &progress
+	} else {
+		res := p.response()
+		e.Process = &res
+	}
+
+	p.lastEventStatus = p.Progress.Status
+	events.publish(e)
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func seqs(events []Event) []uint64 {
+	var s []uint64
+	for _, e := range events {
+		s = append(s, e.Seq)
+	}
+	return s
+}
+
+func TestEventHubResume(t *testing.T) {
+	tests := []struct {
+		name      string
+		published int // events published before subscribing, in a history of 5
+		filter    EventFilter
+		since     uint64
+		want      []uint64
+		resync    bool
+	}{
+		{name: "no backlog", published: 3, since: 0},
+		{name: "up to date", published: 3, since: 3},
+		{name: "within the history", published: 3, since: 1, want: []uint64{2, 3}},
+		{name: "history full", published: 5, since: 1, want: []uint64{2, 3, 4, 5}},
+		{name: "history wrapped around", published: 8, since: 4, want: []uint64{5, 6, 7, 8}},
+		{name: "oldest event kept", published: 8, since: 3, want: []uint64{4, 5, 6, 7, 8}},
+		{name: "filtered", published: 6, since: 2, filter: EventFilter{Ids: []string{"even"}}, want: []uint64{4, 6}},
+		{name: "evicted", published: 8, since: 2, resync: true},
+		{name: "from before a restart", published: 3, since: 10, resync: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newEventHub(5)
+
+			for i := 1; i <= tc.published; i++ {
+				id := "odd"
+				if i%2 == 0 {
+					id = "even"
+				}
+				h.publish(Event{Type: EventProgress, Id: id})
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			backlog, _ := h.subscribe(ctx, tc.filter, tc.since)
+
+			if tc.resync {
+				if len(backlog) != 1 || backlog[0].Type != EventResync {
+					t.Fatalf("backlog = %+v, want a resync event", backlog)
+				}
+				if backlog[0].Seq != uint64(tc.published) {
+					t.Errorf("resync seq = %d, want %d", backlog[0].Seq, tc.published)
+				}
+				return
+			}
+
+			if got := seqs(backlog); !slices.Equal(got, tc.want) {
+				t.Errorf("backlog = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEventHubLiveEvents(t *testing.T) {
+	h := newEventHub(5)
+	h.publish(Event{Type: EventCreated, Id: "a"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	backlog, ch := h.subscribe(ctx, EventFilter{Types: []EventType{EventCompleted}}, 1)
+	if len(backlog) != 0 {
+		t.Fatalf("backlog = %v, want none", seqs(backlog))
+	}
+
+	h.publish(Event{Type: EventProgress, Id: "a"})
+	h.publish(Event{Type: EventCompleted, Id: "a"})
+
+	if e := <-ch; e.Seq != 3 || e.Type != EventCompleted {
+		t.Errorf("received %d %s, want 3 completed", e.Seq, e.Type)
+	}
+
+	cancel()
+
+	// closed once the subscriber is gone
+	if _, ok := <-ch; ok {
+		t.Error("channel still open after the context was cancelled")
+	}
+}
+
+func TestEventHubDropsSlowSubscriber(t *testing.T) {
+	h := newEventHub(eventHistorySize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, slow := h.subscribe(ctx, EventFilter{}, 0)
+	_, other := h.subscribe(ctx, EventFilter{Ids: []string{"other"}}, 0)
+
+	buffered := cap(slow)
+	for range buffered + 1 {
+		h.publish(Event{Type: EventProgress, Id: "a"})
+	}
+
+	// the buffered events are still delivered, then the channel is closed
+	var received int
+	for range slow {
+		received++
+	}
+	if received != buffered {
+		t.Errorf("received %d events, want %d", received, buffered)
+	}
+
+	h.mu.Lock()
+	subscribers := len(h.subscribers)
+	h.mu.Unlock()
+
+	if subscribers != 1 {
+		t.Errorf("%d subscribers left, want 1", subscribers)
+	}
+
+	// the others keep receiving
+	h.publish(Event{Type: EventProgress, Id: "other"})
+	if e := <-other; e.Id != "other" {
+		t.Errorf("received event of %s, want other", e.Id)
+	}
+
+	// and the dropped one resumes from the last event it received
+	backlog, _ := h.subscribe(ctx, EventFilter{}, uint64(received))
+	if got := seqs(backlog); !slices.Equal(got, []uint64{uint64(buffered + 1), uint64(buffered + 2)}) {
+		t.Errorf("backlog = %v, want the events after %d", got, received)
+	}
+}
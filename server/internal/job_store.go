@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+// Persists the state of each process in the jobs table so the download queue
+// survives restarts and crashes.
+type JobStore struct {
+	db *sql.DB
+	mu sync.Mutex // serializes writes, sqlite allows a single writer
+}
+
+func NewJobStore(db *sql.DB) *JobStore {
+	return &JobStore{db: db}
+}
+
+// Insert or update the row of a process.
+func (s *JobStore) Save(ctx context.Context, p *Process) error {
+	params, err := json.Marshal(p.Params)
+	if err != nil {
+		return err
+	}
+	info, err := json.Marshal(p.Info)
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(p.Output)
+	if err != nil {
+		return err
+	}
+	progress, err := json.Marshal(p.Progress)
+	if err != nil {
+		return err
+	}
+	formats, err := json.Marshal(p.PreferredFormats)
+	if err != nil {
+		return err
+	}
+	qualities, err := json.Marshal(p.PreferredQualities)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.db.ExecContext(
+		ctx,
+		`INSERT INTO jobs (
+			id, url, owner, subscription_id, status, priority, attempts, next_retry_at, last_error,
+			error_class, auto_remove, rate_limit, scheduled_at, params, info,
+			output, progress, preferred_formats, preferred_qualities, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			url = excluded.url,
+			owner = excluded.owner,
+			subscription_id = excluded.subscription_id,
+			status = excluded.status,
+			priority = excluded.priority,
+			attempts = excluded.attempts,
+			next_retry_at = excluded.next_retry_at,
+			last_error = excluded.last_error,
+			error_class = excluded.error_class,
+			auto_remove = excluded.auto_remove,
+			rate_limit = excluded.rate_limit,
+			scheduled_at = excluded.scheduled_at,
+			params = excluded.params,
+			info = excluded.info,
+			output = excluded.output,
+			progress = excluded.progress,
+			preferred_formats = excluded.preferred_formats,
+			preferred_qualities = excluded.preferred_qualities,
+			updated_at = excluded.updated_at`,
+		p.Id,
+		p.Url,
+		p.Owner,
+		p.Subscription,
+		p.Progress.Status,
+		p.Priority,
+		p.Attempts,
+		p.NextRetryAt,
+		p.LastError,
+		string(p.ErrorClass),
+		p.AutoRemove,
+		p.RateLimit,
+		p.ScheduledAt,
+		string(params),
+		string(info),
+		string(output),
+		string(progress),
+		string(formats),
+		string(qualities),
+		time.Now(),
+	)
+	return err
+}
+
+func (s *JobStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM jobs WHERE id = ?", id)
+	return err
+}
+
+// Load all the persisted processes.
+func (s *JobStore) All(ctx context.Context) ([]*Process, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, url, owner, subscription_id, priority, attempts, next_retry_at, last_error,
+			error_class, auto_remove, rate_limit, scheduled_at, params, info,
+			output, progress, preferred_formats, preferred_qualities
+		FROM jobs`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var processes []*Process
+
+	for rows.Next() {
+		var (
+			p           Process
+			nextRetryAt sql.NullTime
+			scheduledAt sql.NullTime
+			errorClass  string
+			params      string
+			info        string
+			output      string
+			progress    string
+			formats     string
+			qualities   string
+		)
+
+		if err := rows.Scan(
+			&p.Id,
+			&p.Url,
+			&p.Owner,
+			&p.Subscription,
+			&p.Priority,
+			&p.Attempts,
+			&nextRetryAt,
+			&p.LastError,
+			&errorClass,
+			&p.AutoRemove,
+			&p.RateLimit,
+			&scheduledAt,
+			&params,
+			&info,
+			&output,
+			&progress,
+			&formats,
+			&qualities,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := errors.Join(
+			json.Unmarshal([]byte(params), &p.Params),
+			json.Unmarshal([]byte(info), &p.Info),
+			json.Unmarshal([]byte(output), &p.Output),
+			json.Unmarshal([]byte(progress), &p.Progress),
+			json.Unmarshal([]byte(formats), &p.PreferredFormats),
+			json.Unmarshal([]byte(qualities), &p.PreferredQualities),
+		); err != nil {
+			slog.Warn("skipping malformed job", slog.String("id", p.Id), slog.String("err", err.Error()))
+			continue
+		}
+
+		if nextRetryAt.Valid {
+			p.NextRetryAt = &nextRetryAt.Time
+		}
+		if scheduledAt.Valid {
+			p.ScheduledAt = &scheduledAt.Time
+		}
+		p.ErrorClass = ErrorClass(errorClass)
+
+		processes = append(processes, &p)
+	}
+
+	return processes, rows.Err()
+}
+
+// One-time import of the gob encoded session.dat written by older versions.
+// Once imported the file is renamed so it won't be imported again.
+func (s *JobStore) ImportSession(ctx context.Context) error {
+	sf := filepath.Join(config.Instance().SessionFilePath, "session.dat")
+
+	fd, err := os.Open(sf)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer fd.Close()
+
+	var session Session
+
+	if err := gob.NewDecoder(fd).Decode(&session); err != nil {
+		return errors.Join(errors.New("failed to decode session"), err)
+	}
+
+	for _, proc := range session.Processes {
+		p := &Process{
+			Id:          proc.Id,
+			Url:         proc.Info.URL,
+			Info:        proc.Info,
+			Progress:    proc.Progress,
+			Output:      proc.Output,
+			Params:      proc.Params,
+			Priority:    proc.Priority,
+			Attempts:    proc.Attempts,
+			NextRetryAt: proc.NextRetryAt,
+			LastError:   proc.LastError,
+			ErrorClass:  proc.ErrorClass,
+
+			PreferredFormats:   proc.PreferredFormats,
+			PreferredQualities: proc.PreferredQualities,
+		}
+
+		if err := s.Save(ctx, p); err != nil {
+			return errors.Join(errors.New("failed to import session"), err)
+		}
+	}
+
+	slog.Info("imported session file", slog.String("path", sf), slog.Int("count", len(session.Processes)))
+
+	return os.Rename(sf, sf+".imported")
+}
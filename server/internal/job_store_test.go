@@ -0,0 +1,238 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/common"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/dbutil"
+	_ "modernc.org/sqlite"
+)
+
+func newTestStore(t *testing.T) *JobStore {
+	t.Helper()
+
+	// the migration leaves a lock file in the working directory
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "local.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := dbutil.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return NewJobStore(db)
+}
+
+func TestJobStore(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = newTestStore(t)
+		retry = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	saved := &Process{
+		Id:           "id",
+		Url:          "https://example.com/v",
+		Owner:        "alice",
+		Subscription: "sub",
+		AutoRemove:   true,
+		Params:       []string{"-x", "--audio-format", "mp3"},
+		Info:         common.DownloadInfo{URL: "https://example.com/v", Title: "video"},
+		Progress:     DownloadProgress{Status: StatusErrored, Percentage: "42%"},
+		Output:       DownloadOutput{Path: "/downloads/alice", Filename: "%(title)s.%(ext)s"},
+		Priority:     PriorityHigh,
+		Attempts:     2,
+		NextRetryAt:  &retry,
+		LastError:    "ERROR: HTTP Error 503",
+		ErrorClass:   ErrorClassServerError,
+		RateLimit:    1 << 20,
+
+		PreferredFormats:   []string{"mp4"},
+		PreferredQualities: []string{"1080p"},
+	}
+
+	if err := store.Save(ctx, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	processes, err := store.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(processes) != 1 {
+		t.Fatalf("%d jobs, want 1", len(processes))
+	}
+
+	got := processes[0]
+	if !got.NextRetryAt.Equal(retry) {
+		t.Errorf("next retry = %s, want %s", got.NextRetryAt, retry)
+	}
+	got.NextRetryAt = saved.NextRetryAt
+	if !reflect.DeepEqual(got, saved) {
+		t.Errorf("restored %+v, want %+v", got, saved)
+	}
+
+	// saving again updates the row
+	saved.Progress.Status = StatusCompleted
+	saved.NextRetryAt = nil
+	if err := store.Save(ctx, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	processes, err = store.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(processes) != 1 || processes[0].Progress.Status != StatusCompleted || processes[0].NextRetryAt != nil {
+		t.Errorf("restored %+v after the update", processes)
+	}
+
+	if err := store.Delete(ctx, saved.Id); err != nil {
+		t.Fatal(err)
+	}
+	if processes, _ := store.All(ctx); len(processes) != 0 {
+		t.Errorf("%d jobs left after the deletion", len(processes))
+	}
+}
+
+func TestJobStoreImportSession(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = newTestStore(t)
+		conf  = config.Instance()
+		dir   = t.TempDir()
+	)
+	defer func(path string) { conf.SessionFilePath = path }(conf.SessionFilePath)
+	conf.SessionFilePath = dir
+
+	// nothing to import
+	if err := store.ImportSession(ctx); err != nil {
+		t.Fatalf("ImportSession() without a session file: %v", err)
+	}
+
+	session := Session{Processes: []ProcessResponse{
+		{
+			Id:       "a",
+			Info:     common.DownloadInfo{URL: "https://example.com/a", Title: "a"},
+			Progress: DownloadProgress{Status: StatusCompleted},
+			Params:   []string{"-x"},
+		},
+		{
+			Id:       "b",
+			Info:     common.DownloadInfo{URL: "https://example.com/b", Title: "b"},
+			Progress: DownloadProgress{Status: StatusPending},
+			Priority: PriorityLow,
+		},
+	}}
+
+	fd, err := os.Create(filepath.Join(dir, "session.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gob.NewEncoder(fd).Encode(session); err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+
+	if err := store.ImportSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	processes, err := store.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byId := make(map[string]*Process)
+	for _, p := range processes {
+		byId[p.Id] = p
+	}
+	if a := byId["a"]; a == nil || a.Url != "https://example.com/a" || a.Progress.Status != StatusCompleted || len(a.Params) != 1 {
+		t.Errorf("imported a = %+v", a)
+	}
+	if b := byId["b"]; b == nil || b.Priority != PriorityLow || b.Progress.Status != StatusPending {
+		t.Errorf("imported b = %+v", b)
+	}
+
+	// the file is imported once
+	if _, err := os.Stat(filepath.Join(dir, "session.dat")); !os.IsNotExist(err) {
+		t.Error("session.dat still there after the import")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "session.dat.imported")); err != nil {
+		t.Errorf("session.dat not renamed: %v", err)
+	}
+}
+
+func TestMemoryDBRestore(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		store  = newTestStore(t)
+		later  = time.Now().Add(time.Hour)
+		before = time.Now().Add(-time.Hour)
+	)
+
+	tests := []struct {
+		process    *Process
+		wantStatus int
+		wantQueued bool
+		wantTimer  bool
+	}{
+		{process: &Process{Id: "pending", Progress: DownloadProgress{Status: StatusPending}}, wantStatus: StatusPending, wantQueued: true},
+		// interrupted by the restart, downloaded again from the partial file
+		{process: &Process{Id: "downloading", Progress: DownloadProgress{Status: StatusDownloading}}, wantStatus: StatusPending, wantQueued: true},
+		{process: &Process{Id: "paused", Progress: DownloadProgress{Status: StatusPaused}}, wantStatus: StatusPaused},
+		{process: &Process{Id: "completed", Progress: DownloadProgress{Status: StatusCompleted}}, wantStatus: StatusCompleted},
+		{process: &Process{Id: "failed", Progress: DownloadProgress{Status: StatusErrored}}, wantStatus: StatusErrored},
+		{process: &Process{Id: "retried", Progress: DownloadProgress{Status: StatusErrored}, NextRetryAt: &later}, wantStatus: StatusErrored, wantTimer: true},
+		{process: &Process{Id: "scheduled", Progress: DownloadProgress{Status: StatusScheduled}, ScheduledAt: &later}, wantStatus: StatusScheduled, wantTimer: true},
+		// due while the server wasn't running
+		{process: &Process{Id: "overdue", Progress: DownloadProgress{Status: StatusScheduled}, ScheduledAt: &before}, wantStatus: StatusPending, wantQueued: true},
+	}
+
+	for _, tc := range tests {
+		if err := store.Save(ctx, tc.process); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		mdb = NewMemoryDB(store)
+		m   = newTestQueue()
+	)
+	m.downloads = newDownloadQueue(len(tests), nil, 0)
+
+	mdb.Restore(m)
+
+	for _, tc := range tests {
+		t.Run(tc.process.Id, func(t *testing.T) {
+			defer m.Remove(tc.process.Id)
+
+			p, err := mdb.Get(tc.process.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.Progress.Status != tc.wantStatus {
+				t.Errorf("status = %d, want %d", p.Progress.Status, tc.wantStatus)
+			}
+			if queued := p.QueuePosition > 0; queued != tc.wantQueued {
+				t.Errorf("queued = %t, want %t", queued, tc.wantQueued)
+			}
+			if got := m.hasTimer(p.Id); got != tc.wantTimer {
+				t.Errorf("timer set = %t, want %t", got, tc.wantTimer)
+			}
+		})
+	}
+}
@@ -22,9 +22,10 @@ const (
 )
 
 // Defines a generic livestream.
-// A livestream is identified by its url.
+// A livestream is identified by its url and the user monitoring it.
 type LiveStream struct {
 	url          string
+	owner        string             // downloads into their directory
 	proc         *os.Process        // used to manually kill the yt-dlp process
 	status       int                // whether is monitoring or completed
 	done         chan *LiveStream   // where to signal the completition
@@ -36,9 +37,10 @@ type LiveStream struct {
 	db *internal.MemoryDB
 }
 
-func New(url string, done chan *LiveStream, mq *internal.MessageQueue, db *internal.MemoryDB) *LiveStream {
+func New(url, owner string, done chan *LiveStream, mq *internal.MessageQueue, db *internal.MemoryDB) *LiveStream {
 	return &LiveStream{
 		url:          url,
+		owner:        owner,
 		done:         done,
 		status:       waiting,
 		waitTime:     time.Second * 0,
@@ -57,7 +59,7 @@ func (l *LiveStream) Start() error {
 		"--no-colors", // no ansi color fuzz
 		"--simulate",
 		"--newline",
-		"--paths", config.Instance().DownloadPath,
+		"--paths", config.Instance().UserDownloadPath(l.owner),
 	)
 
 	stdout, err := cmd.StdoutPipe()
@@ -89,6 +91,7 @@ func (l *LiveStream) Start() error {
 	// Send the started livestream to the message queue! :D
 	p := &internal.Process{
 		Url:        l.url,
+		Owner:      l.owner,
 		Livestream: true,
 		Params:     []string{"--downloader", "ffmpeg", "--no-part"},
 	}
@@ -1,11 +1,13 @@
 package livestream
 
 import (
+	"bytes"
 	"encoding/gob"
+	"errors"
 	"log/slog"
-	"maps"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
@@ -14,15 +16,28 @@ import (
 type Monitor struct {
 	db      *internal.MemoryDB     // where the just started livestream will be published
 	mq      *internal.MessageQueue // where the just started livestream will be published
-	streams map[string]*LiveStream // keeps track of the livestreams
-	done    chan *LiveStream       // to signal individual processes completition
+	mu      sync.Mutex
+	streams map[streamKey]*LiveStream // keeps track of the livestreams
+	done    chan *LiveStream          // to signal individual processes completition
+}
+
+// Users monitor their livestreams independently, even the same one
+type streamKey struct {
+	url   string
+	owner string
+}
+
+// A monitored livestream, as persisted
+type persisted struct {
+	URL   string
+	Owner string
 }
 
 func NewMonitor(mq *internal.MessageQueue, db *internal.MemoryDB) *Monitor {
 	return &Monitor{
 		mq:      mq,
 		db:      db,
-		streams: make(map[string]*LiveStream),
+		streams: make(map[streamKey]*LiveStream),
 		done:    make(chan *LiveStream),
 	}
 }
@@ -30,43 +45,87 @@ func NewMonitor(mq *internal.MessageQueue, db *internal.MemoryDB) *Monitor {
 // Detect each livestream completition, if done detach it from the monitor.
 func (m *Monitor) Schedule() {
 	for l := range m.done {
-		delete(m.streams, l.url)
+		key := streamKey{l.url, l.owner}
+
+		m.mu.Lock()
+		if m.streams[key] == l {
+			delete(m.streams, key)
+		}
+		m.mu.Unlock()
 	}
 }
 
-func (m *Monitor) Add(url string) {
-	ls := New(url, m.done, m.mq, m.db)
+// Monitors a livestream on behalf of a user, it's downloaded into their
+// directory once it starts.
+func (m *Monitor) Add(url, owner string) {
+	ls := New(url, owner, m.done, m.mq, m.db)
+
+	m.mu.Lock()
+	previous := m.streams[streamKey{url, owner}]
+	m.streams[streamKey{url, owner}] = ls
+	m.mu.Unlock()
+
+	if previous != nil {
+		go previous.Kill()
+	}
 
 	go ls.Start()
-	m.streams[url] = ls
 }
 
-func (m *Monitor) Remove(url string) error {
-	return m.streams[url].Kill()
+// Stops monitoring a livestream, an empty owner stops everyone's.
+func (m *Monitor) Remove(url, owner string) error {
+	streams := m.of(owner, func(l *LiveStream) bool { return l.url == url })
+	if len(streams) == 0 {
+		return errors.New("livestream not found")
+	}
+	return kill(streams)
+}
+
+// Stops monitoring the livestreams of a user, an empty owner stops everyone's.
+func (m *Monitor) RemoveAll(owner string) error {
+	return kill(m.of(owner, nil))
 }
 
-func (m *Monitor) RemoveAll() error {
-	for _, v := range m.streams {
-		if err := v.Kill(); err != nil {
+// The livestreams of a user matching the filter, an empty owner matches
+// everyone's and a nil filter every livestream.
+func (m *Monitor) of(owner string, filter func(*LiveStream) bool) []*LiveStream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var streams []*LiveStream
+	for _, l := range m.streams {
+		if (owner == "" || l.owner == owner) && (filter == nil || filter(l)) {
+			streams = append(streams, l)
+		}
+	}
+	return streams
+}
+
+// Killing signals the completion, which needs the lock to be released
+func kill(streams []*LiveStream) error {
+	for _, l := range streams {
+		if err := l.Kill(); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (m *Monitor) Status() LiveStreamStatus {
+// The status of the livestreams of a user, an empty owner gets everyone's.
+func (m *Monitor) Status(owner string) LiveStreamStatus {
 	status := make(LiveStreamStatus)
 
-	for k, v := range m.streams {
+	for _, v := range m.of(owner, nil) {
 		// wt, ok := <-v.WaitTime()
 		// if !ok {
 		// 	continue
 		// }
 
-		status[k] = Status{
+		status[v.url] = Status{
 			Status:   v.status,
 			WaitTime: v.waitTime,
 			LiveDate: v.liveDate,
+			Owner:    v.owner,
 		}
 	}
 
@@ -85,9 +144,9 @@ func (m *Monitor) Persist() error {
 
 	slog.Debug("persisting livestream monitor state")
 
-	var toPersist []string
-	for url := range maps.Keys(m.streams) {
-		toPersist = append(toPersist, url)
+	var toPersist []persisted
+	for _, l := range m.of("", nil) {
+		toPersist = append(toPersist, persisted{URL: l.url, Owner: l.owner})
 	}
 
 	return gob.NewEncoder(fd).Encode(toPersist)
@@ -95,21 +154,26 @@ func (m *Monitor) Persist() error {
 
 // Restore a saved state and resume the monitored livestreams
 func (m *Monitor) Restore() error {
-	fd, err := os.Open(filepath.Join(config.Instance().SessionFilePath, "livestreams.dat"))
+	b, err := os.ReadFile(filepath.Join(config.Instance().SessionFilePath, "livestreams.dat"))
 	if err != nil {
 		return err
 	}
 
-	defer fd.Close()
+	var toRestore []persisted
 
-	var toRestore []string
-
-	if err := gob.NewDecoder(fd).Decode(&toRestore); err != nil {
-		return err
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&toRestore); err != nil {
+		// the livestreams used to be persisted as their urls only
+		var urls []string
+		if gob.NewDecoder(bytes.NewReader(b)).Decode(&urls) != nil {
+			return err
+		}
+		for _, url := range urls {
+			toRestore = append(toRestore, persisted{URL: url})
+		}
 	}
 
-	for _, url := range toRestore {
-		m.Add(url)
+	for _, l := range toRestore {
+		m.Add(l.URL, l.Owner)
 	}
 
 	slog.Debug("restored livestream monitor state")
@@ -8,4 +8,5 @@ type Status = struct {
 	Status   int           `json:"status"`
 	WaitTime time.Duration `json:"waitTime"`
 	LiveDate time.Time     `json:"liveDate"`
+	Owner    string        `json:"owner,omitempty"`
 }
@@ -1,28 +1,28 @@
 package internal
 
 import (
-	"encoding/gob"
+	"context"
 	"errors"
 	"log/slog"
-	"os"
-	"path/filepath"
 	"sync"
 
 	"github.com/google/uuid"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 )
 
 var memDbEvents = make(chan *Process)
 
-// In-Memory Thread-Safe Key-Value Storage with optional persistence
+// In-Memory Thread-Safe Key-Value Storage, it's a read cache of the job store
+// where every state change of a process is persisted.
 type MemoryDB struct {
 	table map[string]*Process
 	mu    sync.RWMutex
+	store *JobStore
 }
 
-func NewMemoryDB() *MemoryDB {
+func NewMemoryDB(store *JobStore) *MemoryDB {
 	return &MemoryDB{
 		table: make(map[string]*Process),
+		store: store,
 	}
 }
 
@@ -45,17 +45,33 @@ func (m *MemoryDB) Set(process *Process) string {
 
 	m.mu.Lock()
 	process.Id = id
+	process.store = m.store
 	m.table[id] = process
 	m.mu.Unlock()
 
+	process.persist()
+	process.emit(EventCreated)
+
 	return id
 }
 
 // Removes a process progress, given the process id
 func (m *MemoryDB) Delete(id string) {
 	m.mu.Lock()
+	p, ok := m.table[id]
 	delete(m.table, id)
 	m.mu.Unlock()
+
+	if ok {
+		p.emit(EventRemoved)
+	}
+
+	if m.store == nil {
+		return
+	}
+	if err := m.store.Delete(context.Background(), id); err != nil {
+		slog.Warn("failed to delete job", slog.String("id", id), slog.String("err", err.Error()))
+	}
 }
 
 func (m *MemoryDB) Keys() *[]string {
@@ -76,76 +92,77 @@ func (m *MemoryDB) All() *[]ProcessResponse {
 	running := []ProcessResponse{}
 
 	m.mu.RLock()
-	for k, v := range m.table {
-		running = append(running, ProcessResponse{
-			Id:       k,
-			Info:     v.Info,
-			Progress: v.Progress,
-			Output:   v.Output,
-			Params:   v.Params,
-		})
+	for _, v := range m.table {
+		running = append(running, v.response())
 	}
 	m.mu.RUnlock()
 
 	return &running
 }
 
-// Persist the database in a single file named "session.dat"
-func (m *MemoryDB) Persist() error {
-	running := m.All()
-
-	sf := filepath.Join(config.Instance().SessionFilePath, "session.dat")
-
-	fd, err := os.Create(sf)
-	if err != nil {
-		return errors.Join(errors.New("failed to persist session"), err)
-	}
+// Returns a slice of the processes of a user
+func (m *MemoryDB) AllOf(owner string) *[]ProcessResponse {
+	running := []ProcessResponse{}
 
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-	session := Session{Processes: *running}
-
-	if err := gob.NewEncoder(fd).Encode(session); err != nil {
-		return errors.Join(errors.New("failed to persist session"), err)
+	for _, v := range m.table {
+		if v.Owner == owner {
+			running = append(running, v.response())
+		}
 	}
+	m.mu.RUnlock()
 
-	return nil
+	return &running
 }
 
-// Restore a persisted state
+// Restore the processes persisted in the job store, importing the legacy
+// session file first if there's one.
 func (m *MemoryDB) Restore(mq *MessageQueue) {
-	sf := filepath.Join(config.Instance().SessionFilePath, "session.dat")
-
-	fd, err := os.Open(sf)
-	if err != nil {
+	if m.store == nil {
 		return
 	}
 
-	var session Session
+	ctx := context.Background()
+
+	if err := m.store.ImportSession(ctx); err != nil {
+		slog.Error("failed to import session file", slog.String("err", err.Error()))
+	}
 
-	if err := gob.NewDecoder(fd).Decode(&session); err != nil {
+	processes, err := m.store.All(ctx)
+	if err != nil {
+		slog.Error("failed to restore jobs", slog.String("err", err.Error()))
 		return
 	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, proc := range session.Processes {
-		restored := &Process{
-			Id:       proc.Id,
-			Url:      proc.Info.URL,
-			Info:     proc.Info,
-			Progress: proc.Progress,
-			Output:   proc.Output,
-			Params:   proc.Params,
-		}
-
-		m.table[proc.Id] = restored
+	for _, restored := range processes {
+		restored.store = m.store
+		m.table[restored.Id] = restored
 
-		if restored.Progress.Status != StatusCompleted {
+		// completed, paused and failed processes which won't be retried are
+		// kept as they are
+		switch restored.Progress.Status {
+		case StatusPending, StatusDownloading:
+			mq.Publish(restored)
+		case StatusErrored:
+			if restored.NextRetryAt != nil {
+				mq.retryAt(restored, *restored.NextRetryAt)
+			}
+		case StatusScheduled:
+			// released straight away if it was due while not running
 			mq.Publish(restored)
 		}
 	}
+
+	slog.Info("restored jobs", slog.Int("count", len(processes)))
+}
+
+// Returns the process events following since which match the filter, and a
+// channel receiving the next ones until ctx is done or the client falls behind.
+func (m *MemoryDB) Events(ctx context.Context, filter EventFilter, since uint64) ([]Event, <-chan Event) {
+	return events.subscribe(ctx, filter, since)
 }
 
 func (m *MemoryDB) EventListener() {
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"sync"
+	"time"
 
 	evbus "github.com/asaskevich/EventBus"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
@@ -15,6 +17,11 @@ const queueName = "process:pending"
 type MessageQueue struct {
 	concurrency int
 	eventBus    evbus.Bus
+	downloads   *downloadQueue
+	bandwidth   *bandwidthScheduler
+
+	timers   map[string]*time.Timer // processes waiting to be retried or to start
+	timersMu sync.Mutex
 }
 
 // Creates a new message queue.
@@ -28,53 +35,205 @@ func NewMessageQueue() (*MessageQueue, error) {
 		return nil, errors.New("invalid queue size")
 	}
 
+	bandwidth, err := newBandwidthScheduler(config.Instance().Bandwidth)
+	if err != nil {
+		return nil, err
+	}
+
 	return &MessageQueue{
 		concurrency: qs,
 		eventBus:    evbus.New(),
+		downloads:   newDownloadQueue(qs, config.Instance().SiteLimits, config.Instance().Quotas.MaxConcurrent),
+		bandwidth:   bandwidth,
+		timers:      make(map[string]*time.Timer),
 	}, nil
 }
 
 // Publish a message to the queue and set the task to a peding state.
+// Processes scheduled in the future are held until they're due.
 func (m *MessageQueue) Publish(p *Process) {
+	if p.ScheduledAt != nil && p.ScheduledAt.After(time.Now()) {
+		m.schedule(p)
+		return
+	}
+	p.ScheduledAt = nil
+
 	// needs to have an id set before
 	p.SetPending()
 
+	if p.Livestream {
+		// livestreams cannot wait behind other downloads
+		p.Priority = PriorityHigh
+	}
+
+	m.downloads.push(p)
 	m.eventBus.Publish(queueName, p)
 }
 
+// Change the priority of a process which is still waiting in the queue.
+func (m *MessageQueue) SetPriority(id string, priority int) error {
+	if err := ValidatePriority(priority); err != nil {
+		return err
+	}
+	p, err := m.downloads.update(id, priority)
+	if err != nil {
+		return err
+	}
+
+	p.persist()
+	return nil
+}
+
+// Pause a process, if it's still waiting it's taken out of the download queue.
+// A scheduled process loses its schedule and starts as soon as it's resumed.
+// A process which can't be paused, like a failed one waiting for its retry,
+// is left as it is.
+func (m *MessageQueue) Pause(p *Process) error {
+	if err := p.Pause(); err != nil {
+		return err
+	}
+
+	m.downloads.remove(p.Id)
+	m.cancelTimer(p.Id)
+
+	if p.ScheduledAt != nil {
+		p.ScheduledAt = nil
+		p.persist()
+	}
+	return nil
+}
+
+// Put a paused process back in the download queue.
+// Metadata has already been retrieved so it skips the metadata consumer and
+// the process keeps its params, output template and format selection.
+// yt-dlp will continue from the partially downloaded file.
+func (m *MessageQueue) Resume(p *Process) error {
+	if p.Progress.Status != StatusPaused {
+		return errors.New("process is not paused")
+	}
+
+	p.Progress.Status = StatusPending
+	p.persist()
+	p.emit(EventProgress)
+	m.downloads.push(p)
+
+	return nil
+}
+
+// Remove a process from the download queue, if it's still waiting, and
+// cancel its scheduled start or retry.
+func (m *MessageQueue) Remove(id string) {
+	m.downloads.remove(id)
+	m.cancelTimer(id)
+}
+
+// Calls f at the given time, replacing the timer previously set for the
+// same process.
+func (m *MessageQueue) runAt(id string, at time.Time, f func()) {
+	m.timersMu.Lock()
+	defer m.timersMu.Unlock()
+
+	if t, ok := m.timers[id]; ok {
+		t.Stop()
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(time.Until(at), func() {
+		m.timersMu.Lock()
+		if m.timers[id] == t {
+			delete(m.timers, id)
+		}
+		m.timersMu.Unlock()
+
+		f()
+	})
+	m.timers[id] = t
+}
+
+// Stops the timer set for a process, if any.
+func (m *MessageQueue) cancelTimer(id string) {
+	m.timersMu.Lock()
+	defer m.timersMu.Unlock()
+
+	if t, ok := m.timers[id]; ok {
+		t.Stop()
+		delete(m.timers, id)
+	}
+}
+
+// Current global bandwidth limit.
+func (m *MessageQueue) Bandwidth() BandwidthStatus {
+	return m.bandwidth.status()
+}
+
+// Change the global bandwidth limit at runtime, it takes precedence over the
+// configured limit and schedules until it's removed.
+func (m *MessageQueue) SetBandwidth(req BandwidthRequest) error {
+	if req.Limit == nil {
+		m.bandwidth.setOverride(nil)
+		return nil
+	}
+
+	limit, err := ParseRate(*req.Limit)
+	if err != nil {
+		return err
+	}
+
+	m.bandwidth.setOverride(&limit)
+	return nil
+}
+
 func (m *MessageQueue) SetupConsumers() {
 	go m.downloadConsumer()
 	go m.metadataSubscriber()
+	go m.bandwidthWatcher()
 }
 
-// Setup the consumer listener which subscribes to the changes to the producer
-// channel and triggers the "download" action.
-func (m *MessageQueue) downloadConsumer() {
-	sem := semaphore.NewWeighted(int64(m.concurrency))
+// Applies the bandwidth schedules as time goes by.
+func (m *MessageQueue) bandwidthWatcher() {
+	for now := range time.Tick(time.Minute) {
+		m.bandwidth.tick(now)
+	}
+}
 
-	m.eventBus.SubscribeAsync(queueName, func(p *Process) {
-		sem.Acquire(context.Background(), 1)
-		defer sem.Release(1)
+// Setup the consumer which takes processes from the download queue, in
+// priority order, and triggers the "download" action as soon as a slot is free.
+func (m *MessageQueue) downloadConsumer() {
+	for {
+		p := m.downloads.pop()
 
-		slog.Info("received process from event bus",
-			slog.String("bus", queueName),
+		slog.Info("received process from download queue",
 			slog.String("consumer", "downloadConsumer"),
 			slog.String("id", p.getShortId()),
+			slog.Int("priority", p.Priority),
 		)
 
-		if p.Progress.Status != StatusCompleted {
-			slog.Info("started process",
-				slog.String("bus", queueName),
-				slog.String("id", p.getShortId()),
-			)
-			if p.Livestream {
-				// livestreams have higher priorty and they ignore the semaphore
-				go p.Start()
-			} else {
+		if p.Progress.Status == StatusCompleted || p.Progress.Status == StatusPaused {
+			m.downloads.done(p)
+			continue
+		}
+
+		slog.Info("started process",
+			slog.String("id", p.getShortId()),
+		)
+
+		go func() {
+			m.bandwidth.started(p)
+
+			p.Start()
+			// restarted by the bandwidth scheduler to apply a new rate limit
+			for p.restarting && !p.killed {
 				p.Start()
 			}
-		}
-	}, false)
+
+			m.bandwidth.finished(p)
+			m.downloads.done(p)
+
+			if p.Progress.Status == StatusErrored {
+				m.scheduleRetry(p)
+			}
+		}()
+	}
 }
 
 // Setup the metadata consumer listener which subscribes to the changes to the
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	evbus "github.com/asaskevich/EventBus"
+)
+
+func newTestQueue() *MessageQueue {
+	return &MessageQueue{
+		eventBus:  evbus.New(),
+		downloads: newDownloadQueue(1, nil, 0),
+		timers:    make(map[string]*time.Timer),
+	}
+}
+
+func (m *MessageQueue) hasTimer(id string) bool {
+	m.timersMu.Lock()
+	defer m.timersMu.Unlock()
+
+	_, ok := m.timers[id]
+	return ok
+}
+
+func TestMessageQueuePause(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		// puts the process in the state it's paused from
+		setup      func(m *MessageQueue, p *Process)
+		wantErr    bool
+		wantStatus int
+		wantTimer  bool
+	}{
+		{
+			name: "waiting in the queue",
+			setup: func(m *MessageQueue, p *Process) {
+				p.SetPending()
+				m.downloads.push(p)
+			},
+			wantStatus: StatusPaused,
+		},
+		{
+			name: "already paused",
+			setup: func(m *MessageQueue, p *Process) {
+				p.Progress.Status = StatusPaused
+			},
+			wantStatus: StatusPaused,
+		},
+		{
+			name: "completed",
+			setup: func(m *MessageQueue, p *Process) {
+				p.Progress.Status = StatusCompleted
+			},
+			wantErr:    true,
+			wantStatus: StatusCompleted,
+		},
+		{
+			name: "livestream",
+			setup: func(m *MessageQueue, p *Process) {
+				p.Livestream = true
+				p.SetPending()
+				m.downloads.push(p)
+			},
+			wantErr:    true,
+			wantStatus: StatusPending,
+		},
+		{
+			name: "scheduled",
+			setup: func(m *MessageQueue, p *Process) {
+				p.ScheduledAt = &later
+				m.Publish(p)
+			},
+			wantStatus: StatusPaused,
+		},
+		{
+			name: "failed with a retry pending",
+			setup: func(m *MessageQueue, p *Process) {
+				p.Progress.Status = StatusErrored
+				m.retryAt(p, later)
+			},
+			wantErr:    true,
+			wantStatus: StatusErrored,
+			wantTimer:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				m = newTestQueue()
+				p = &Process{Id: "id", Url: "https://example.com/v"}
+			)
+			defer m.Remove(p.Id)
+
+			tc.setup(m, p)
+
+			err := m.Pause(p)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Pause() error = %v, want error %t", err, tc.wantErr)
+			}
+
+			if p.Progress.Status != tc.wantStatus {
+				t.Errorf("status = %d, want %d", p.Progress.Status, tc.wantStatus)
+			}
+			if got := m.hasTimer(p.Id); got != tc.wantTimer {
+				t.Errorf("timer set = %t, want %t", got, tc.wantTimer)
+			}
+			if tc.wantTimer && p.NextRetryAt == nil {
+				t.Error("the pending retry was dropped")
+			}
+			if !tc.wantErr && p.ScheduledAt != nil {
+				t.Error("the paused process kept its schedule")
+			}
+
+			// a paused process leaves the queue, a refused one stays where it was
+			wantQueued := tc.wantErr && p.Progress.Status == StatusPending
+			if queued := p.QueuePosition > 0; queued != wantQueued {
+				t.Errorf("queued = %t, want %t", queued, wantQueued)
+			}
+		})
+	}
+}
+
+func TestMessageQueueResume(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "paused", status: StatusPaused},
+		{name: "pending", status: StatusPending, wantErr: true},
+		{name: "downloading", status: StatusDownloading, wantErr: true},
+		{name: "completed", status: StatusCompleted, wantErr: true},
+		{name: "errored", status: StatusErrored, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				m = newTestQueue()
+				p = &Process{Id: "id", Url: "https://example.com/v", Progress: DownloadProgress{Status: tc.status}}
+			)
+
+			err := m.Resume(p)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Resume() error = %v, want error %t", err, tc.wantErr)
+			}
+
+			if tc.wantErr {
+				if p.Progress.Status != tc.status || p.QueuePosition != 0 {
+					t.Errorf("status = %d at position %d, want it untouched", p.Progress.Status, p.QueuePosition)
+				}
+				return
+			}
+
+			// back in the queue, without fetching the metadata again
+			if p.Progress.Status != StatusPending {
+				t.Errorf("status = %d, want pending", p.Progress.Status)
+			}
+			if p.QueuePosition != 1 {
+				t.Errorf("position = %d, want 1", p.QueuePosition)
+			}
+			if got := m.downloads.pop(); got != p {
+				t.Errorf("popped %s, want the resumed process", got.Id)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"strconv"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/metrics"
+)
+
+var statusNames = map[int]string{
+	StatusPending:     "pending",
+	StatusDownloading: "downloading",
+	StatusCompleted:   "completed",
+	StatusErrored:     "errored",
+	StatusLivestream:  "livestream",
+	StatusPaused:      "paused",
+	StatusScheduled:   "scheduled",
+}
+
+// Registers the gauges computed from the current state of the downloads.
+func RegisterMetrics(mdb *MemoryDB, mq *MessageQueue) {
+	metrics.NewGaugeFunc(
+		metrics.Namespace+"downloads",
+		"Number of downloads by status.",
+		func() []metrics.Sample {
+			counts := make(map[int]int, len(statusNames))
+			for _, p := range *mdb.All() {
+				counts[p.Progress.Status]++
+			}
+
+			samples := make([]metrics.Sample, 0, len(statusNames))
+			for status := range len(statusNames) {
+				name, ok := statusNames[status]
+				if !ok {
+					name = strconv.Itoa(status)
+				}
+				samples = append(samples, metrics.Sample{
+					Labels: []string{name},
+					Value:  float64(counts[status]),
+				})
+			}
+			return samples
+		},
+		"status",
+	)
+
+	metrics.NewGaugeFunc(
+		metrics.Namespace+"download_speed_bytes",
+		"Aggregate speed of the running downloads in bytes per second.",
+		func() []metrics.Sample {
+			var speed float64
+			for _, p := range *mdb.All() {
+				if p.Progress.Status == StatusDownloading {
+					speed += p.Progress.Speed
+				}
+			}
+			return []metrics.Sample{{Value: speed}}
+		},
+	)
+
+	metrics.NewGaugeFunc(
+		metrics.Namespace+"bandwidth_limit_bytes",
+		"Global bandwidth limit in bytes per second, 0 means unlimited.",
+		func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(mq.Bandwidth().Limit)}}
+		},
+	)
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+// yt-dlp options allowed to the users who aren't admins, mapped to whether
+// they take a value. The others can read or write files anywhere, run
+// commands or load configurations, so they aren't allowed.
+var allowedOptions = map[string]bool{
+	// format selection and conversion
+	"-f":                    true,
+	"--format":              true,
+	"-S":                    true,
+	"--format-sort":         true,
+	"--merge-output-format": true,
+	"--remux-video":         true,
+	"--recode-video":        true,
+	"-x":                    false,
+	"--extract-audio":       false,
+	"--audio-format":        true,
+	"--audio-quality":       true,
+	"-k":                    false,
+	"--keep-video":          false,
+	"--prefer-free-formats": false,
+	"--audio-multistreams":  false,
+	"--video-multistreams":  false,
+
+	// subtitles, thumbnails and metadata, written next to the download
+	"--write-subs":         false,
+	"--write-auto-subs":    false,
+	"--sub-langs":          true,
+	"--sub-format":         true,
+	"--convert-subs":       true,
+	"--embed-subs":         false,
+	"--write-thumbnail":    false,
+	"--embed-thumbnail":    false,
+	"--convert-thumbnails": true,
+	"--embed-metadata":     false,
+	"--add-metadata":       false,
+	"--embed-chapters":     false,
+	"--split-chapters":     false,
+	"--parse-metadata":     true,
+
+	// selection
+	"--no-playlist":         false,
+	"--yes-playlist":        false,
+	"-I":                    true,
+	"--playlist-items":      true,
+	"--playlist-start":      true,
+	"--playlist-end":        true,
+	"--playlist-reverse":    false,
+	"--max-downloads":       true,
+	"--match-filters":       true,
+	"--date":                true,
+	"--datebefore":          true,
+	"--dateafter":           true,
+	"--min-filesize":        true,
+	"--max-filesize":        true,
+	"--download-sections":   true,
+	"--live-from-start":     false,
+	"--sponsorblock-mark":   true,
+	"--sponsorblock-remove": true,
+
+	// download behaviour
+	"-r":                     true,
+	"--limit-rate":           true,
+	"-R":                     true,
+	"--retries":              true,
+	"--fragment-retries":     true,
+	"-N":                     true,
+	"--concurrent-fragments": true,
+	"--no-mtime":             false,
+	"--no-part":              false,
+	"--no-overwrites":        false,
+	"--force-overwrites":     false,
+	"--restrict-filenames":   false,
+	"--windows-filenames":    false,
+	"-i":                     false,
+	"--ignore-errors":        false,
+	"--abort-on-error":       false,
+	"--no-warnings":          false,
+}
+
+// Keeps the download of a user who isn't an admin within their download
+// directory: a relative path is resolved against it and an absolute one must
+// be inside it, the filename and the channel folder can't leave it and only
+// the yt-dlp options which can't reach outside of it are allowed.
+func (r *DownloadRequest) Confine() error {
+	root := config.Instance().UserDownloadPath(r.Owner)
+
+	if r.Path != "" {
+		path := r.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		if !IsWithin(root, path) {
+			return errors.New("the path is outside of your download directory")
+		}
+		r.Path = filepath.Clean(path)
+	}
+
+	if strings.ContainsAny(r.Rename, `/\`) || strings.Contains(r.Rename, "..") {
+		return errors.New("the filename can't contain path separators or ..")
+	}
+	if strings.ContainsAny(r.ChannelFolder, `/\`) || strings.Contains(r.ChannelFolder, "..") {
+		return errors.New("the channel folder can't contain path separators or ..")
+	}
+
+	return ConfineParams(r.Params)
+}
+
+// Checks the yt-dlp options of a user who isn't an admin are all allowed,
+// either as "--option value" or "--option=value".
+func ConfineParams(params []string) error {
+	for i := 0; i < len(params); i++ {
+		option, _, joined := strings.Cut(params[i], "=")
+
+		takesValue, ok := allowedOptions[option]
+		if !ok {
+			return errors.New("the " + option + " option isn't allowed")
+		}
+		if takesValue && !joined {
+			i++ // skip the value
+		}
+	}
+	return nil
+}
+
+// Reports whether path is root or one of its descendants
+func IsWithin(root, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+func TestIsWithin(t *testing.T) {
+	tests := []struct {
+		root, path string
+		want       bool
+	}{
+		{"/downloads/alice", "/downloads/alice", true},
+		{"/downloads/alice", "/downloads/alice/", true},
+		{"/downloads/alice", "/downloads/alice/music/a.mp3", true},
+		{"/downloads/alice", "/downloads/alice/../alice/a.mp3", true},
+		{"/downloads/alice", "/downloads/alice/..hidden", true},
+		{"/downloads/alice", "/downloads", false},
+		{"/downloads/alice", "/downloads/alice/../bob", false},
+		{"/downloads/alice", "/downloads/alicebob", false},
+		{"/downloads/alice", "/etc/passwd", false},
+		{"/downloads/alice", "relative", false},
+	}
+
+	for _, tc := range tests {
+		if got := IsWithin(tc.root, tc.path); got != tc.want {
+			t.Errorf("IsWithin(%q, %q) = %v, want %v", tc.root, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestConfine(t *testing.T) {
+	config.Instance().DownloadPath = "/downloads"
+	root := filepath.Join("/downloads", "alice")
+
+	tests := []struct {
+		name     string
+		req      DownloadRequest
+		wantErr  bool
+		wantPath string
+	}{
+		{name: "no path", req: DownloadRequest{}},
+		{name: "relative path", req: DownloadRequest{Path: "music"}, wantPath: root + "/music"},
+		{name: "absolute path within", req: DownloadRequest{Path: root + "/music"}, wantPath: root + "/music"},
+		{name: "relative path outside", req: DownloadRequest{Path: "../bob"}, wantErr: true},
+		{name: "absolute path outside", req: DownloadRequest{Path: "/etc"}, wantErr: true},
+
+		{name: "rename", req: DownloadRequest{Rename: "%(title)s.%(ext)s"}},
+		{name: "rename with separator", req: DownloadRequest{Rename: "music/%(title)s.%(ext)s"}, wantErr: true},
+		{name: "rename with backslash", req: DownloadRequest{Rename: `..\x`}, wantErr: true},
+		{name: "rename escaping", req: DownloadRequest{Rename: "../../x"}, wantErr: true},
+		{name: "rename with dots", req: DownloadRequest{Rename: ".."}, wantErr: true},
+		{name: "channel folder", req: DownloadRequest{ChannelFolder: "channel"}},
+		{name: "channel folder escaping", req: DownloadRequest{ChannelFolder: "../bob"}, wantErr: true},
+
+		{name: "allowed options", req: DownloadRequest{Params: []string{"-x", "--audio-format", "mp3", "-f", "best", "--embed-metadata"}}},
+		{name: "allowed option joined to its value", req: DownloadRequest{Params: []string{"--limit-rate=1M", "-N", "4"}}},
+		{name: "value looking like an option", req: DownloadRequest{Params: []string{"--match-filters", "--exec"}}},
+		{name: "output", req: DownloadRequest{Params: []string{"-o", "/tmp/x"}}, wantErr: true},
+		{name: "output joined", req: DownloadRequest{Params: []string{"--output=/tmp/x"}}, wantErr: true},
+		{name: "short output joined", req: DownloadRequest{Params: []string{"-o/tmp/x"}}, wantErr: true},
+		{name: "paths", req: DownloadRequest{Params: []string{"--paths", "temp:/tmp"}}, wantErr: true},
+		{name: "exec", req: DownloadRequest{Params: []string{"--exec", "rm -rf /"}}, wantErr: true},
+		{name: "print to file", req: DownloadRequest{Params: []string{"--print-to-file", "%(id)s", "/etc/x"}}, wantErr: true},
+		{name: "cookies", req: DownloadRequest{Params: []string{"--cookies", "/etc/passwd"}}, wantErr: true},
+		{name: "download archive", req: DownloadRequest{Params: []string{"--download-archive", "/tmp/a"}}, wantErr: true},
+		{name: "batch file", req: DownloadRequest{Params: []string{"-a", "/etc/passwd"}}, wantErr: true},
+		{name: "config locations", req: DownloadRequest{Params: []string{"--config-locations", "/tmp/c"}}, wantErr: true},
+		{name: "load info json", req: DownloadRequest{Params: []string{"--load-info-json", "/tmp/i"}}, wantErr: true},
+		{name: "write info json", req: DownloadRequest{Params: []string{"--write-info-json"}}, wantErr: true},
+		{name: "positional argument", req: DownloadRequest{Params: []string{"https://example.com"}}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := tc.req
+			req.Owner = "alice"
+
+			err := req.Confine()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Confine() error = %v, want error %v", err, tc.wantErr)
+			}
+			if err == nil && req.Path != tc.wantPath {
+				t.Errorf("path = %q, want %q", req.Path, tc.wantPath)
+			}
+		})
+	}
+}
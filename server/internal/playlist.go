@@ -1,8 +1,11 @@
 package internal
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"os/exec"
 	"slices"
@@ -15,6 +18,15 @@ import (
 )
 
 func PlaylistDetect(req DownloadRequest, mq *MessageQueue, db *MemoryDB) error {
+	if err := ValidatePriority(req.Priority); err != nil {
+		return err
+	}
+
+	rateLimit, err := ParseRate(req.RateLimit)
+	if err != nil {
+		return err
+	}
+
 	params := append(req.Params, "--flat-playlist", "-J")
 	urlWithParams := append([]string{req.URL}, params...)
 
@@ -28,6 +40,9 @@ func PlaylistDetect(req DownloadRequest, mq *MessageQueue, db *MemoryDB) error {
 		return err
 	}
 
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
 	var m playlist.Metadata
 
 	if err := cmd.Start(); err != nil {
@@ -36,12 +51,15 @@ func PlaylistDetect(req DownloadRequest, mq *MessageQueue, db *MemoryDB) error {
 
 	slog.Info("decoding playlist metadata", slog.String("url", req.URL))
 
-	if err := json.NewDecoder(stdout).Decode(&m); err != nil {
-		return err
-	}
+	decodeErr := json.NewDecoder(stdout).Decode(&m)
+	io.Copy(io.Discard, stdout)
 
 	if err := cmd.Wait(); err != nil {
-		return err
+		return NewYtDlpError(stderr.String())
+	}
+
+	if decodeErr != nil {
+		return decodeErr
 	}
 
 	slog.Info("decoded playlist metadata", slog.String("url", req.URL))
@@ -65,6 +83,10 @@ func PlaylistDetect(req DownloadRequest, mq *MessageQueue, db *MemoryDB) error {
 			return err
 		}
 
+		if err := db.Reserve(context.Background(), req.Owner, len(entries)); err != nil {
+			return err
+		}
+
 		for i, meta := range entries {
 			// detect playlist title from metadata since each playlist entry will be
 			// treated as an individual download
@@ -79,11 +101,15 @@ func PlaylistDetect(req DownloadRequest, mq *MessageQueue, db *MemoryDB) error {
 			meta.CreatedAt = time.Now().Add(time.Millisecond * time.Duration(i*10))
 
 			proc := &Process{
-				Url:      meta.URL,
-				Progress: DownloadProgress{},
-				Output:   DownloadOutput{Filename: req.Rename},
-				Info:     meta,
-				Params:   req.Params,
+				Url:         meta.URL,
+				Owner:       req.Owner,
+				Progress:    DownloadProgress{},
+				Output:      DownloadOutput{Filename: req.Rename},
+				Info:        meta,
+				Params:      req.Params,
+				Priority:    req.Priority,
+				RateLimit:   rateLimit,
+				ScheduledAt: req.ScheduledAt(),
 			}
 
 			proc.Info.URL = meta.URL
@@ -97,9 +123,17 @@ func PlaylistDetect(req DownloadRequest, mq *MessageQueue, db *MemoryDB) error {
 		return nil
 	}
 
+	if err := db.Reserve(context.Background(), req.Owner, 1); err != nil {
+		return err
+	}
+
 	proc := &Process{
-		Url:    req.URL,
-		Params: req.Params,
+		Url:         req.URL,
+		Owner:       req.Owner,
+		Params:      req.Params,
+		Priority:    req.Priority,
+		RateLimit:   rateLimit,
+		ScheduledAt: req.ScheduledAt(),
 	}
 
 	db.Set(proc)
@@ -16,12 +16,15 @@ import (
 
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archiver"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/common"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/metrics"
 )
 
 const downloadTemplate = `download:
@@ -42,30 +45,49 @@ const (
 	StatusDownloading
 	StatusCompleted
 	StatusErrored
+	StatusLivestream // only used by the web ui
+	StatusPaused
+	StatusScheduled
 )
 
-type Process struct {
-	Id         string
-	Url        string
-	Livestream bool
-	AutoRemove bool
-	Params     []string
-	Info       common.DownloadInfo
-	Progress   DownloadProgress
-	Output     DownloadOutput 
-	proc       *os.Process
-	PreferredFormats []string 
-	PreferredQualities []string 
-}
 
-type DownloadOutput struct {
-	Path          string
-	Filename      string
-	SavedFilePath string
-	ChannelFolder string 
+type Process struct {
+	Id                 string
+	Url                string
+	Owner              string // username of who queued it, empty without authentication
+	Subscription       string // id of the subscription which queued it, if any
+	Livestream         bool
+	AutoRemove         bool
+	Params             []string
+	Info               common.DownloadInfo
+	Progress           DownloadProgress
+	Output             DownloadOutput
+	Priority           int
+	QueuePosition      int        // 1-based position in the download queue, 0 if not queued
+	Attempts           int        // number of times the download has been started
+	NextRetryAt        *time.Time // when a failed download will be retried, nil if it won't
+	LastError          string     // last error lines reported by yt-dlp
+	ErrorClass         ErrorClass // class of the last error reported by yt-dlp
+	ScheduledAt        *time.Time // when a scheduled download is released into the queue
+	PreferredFormats   []string
+	PreferredQualities []string
+	RateLimit          int64 // bytes per second requested for this download, 0 means no limit
+	appliedRate        int64 // share of the global bandwidth assigned by the scheduler
+	proc               *os.Process
+	pausing            bool // set when the yt-dlp process is being stopped by Pause
+	killed             bool // set when the yt-dlp process is being stopped by Kill
+	restarting         bool // set when the yt-dlp process is being restarted with a new rate limit
+	logs               *processLog
+	logsOnce           sync.Once
+	store              *JobStore // set by MemoryDB, nil if the process isn't persisted
+	lastEventAt        time.Time // when the last progress event was sent
+	lastEventStatus    int       // status sent with the last event
 }
 
 func (p *Process) Start() {
+	restarted := p.restarting
+	p.restarting = false
+
 	p.Params = slices.DeleteFunc(p.Params, func(e string) bool {
 		match, _ := regexp.MatchString(`(\$\{)|(\&\&)`, e)
 		return match
@@ -75,7 +97,7 @@ func (p *Process) Start() {
 	})
 
 	if p.Output.Path == "" {
-        p.Output.Path = config.Instance().DownloadPath
+        p.Output.Path = config.Instance().UserDownloadPath(p.Owner)
     }
 	if p.Output.Filename == "" {
         p.Output.Filename = "%(title)s.%(ext)s"
@@ -160,6 +182,10 @@ func (p *Process) Start() {
     }
     // If neither, yt-dlp uses its default "best".
 
+	if p.appliedRate > 0 {
+		currentParams = append(currentParams, "--limit-rate", strconv.FormatInt(p.appliedRate, 10))
+	}
+
 	// Append user's raw parameters (p.Params)
     // These are added after our potential format string. If user also included -f, yt-dlp behavior
     // (usually last -f wins, or they can be additive if complex) applies.
@@ -174,6 +200,14 @@ func (p *Process) Start() {
 		fullOutputPath = filepath.Join(p.Output.Path, p.Output.Filename)
 	}
 
+	// the filename and the channel folder must not leave the output path
+	if !IsWithin(p.Output.Path, fullOutputPath) {
+		p.ErrorClass = ErrorClassInvalidOutput
+		p.LastError = "the output " + fullOutputPath + " is outside of " + p.Output.Path
+		p.setErrored(errors.New(p.LastError))
+		return
+	}
+
 	// Add -o unless user provided -P or --paths in their original p.Params
     userProvidedOutputFlag := false
     for _, param := range p.Params { // Check original p.Params
@@ -186,6 +220,12 @@ func (p *Process) Start() {
 		currentParams = append(currentParams, "-o", fullOutputPath)
 	}
 
+	// paused after the download consumer took it from the queue
+	if p.Progress.Status == StatusPaused {
+		slog.Info("not starting paused download", slog.String("id", p.getShortId()))
+		return
+	}
+
 	slog.Info("requesting download", slog.String("url", p.Url), slog.Any("params", currentParams))
 	cmd := exec.Command(config.Instance().DownloaderPath, currentParams...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
@@ -200,22 +240,44 @@ func (p *Process) Start() {
 		slog.Error("failed to get a stderr pipe", slog.Any("err", err))
 		panic(err)
 	}
+	spawnedAt := time.Now()
 	if err := cmd.Start(); err != nil {
 		slog.Error("failed to start yt-dlp process", slog.Any("err", err))
 		panic(err)
 	}
 	p.proc = cmd.Process
+	if !restarted {
+		p.Attempts++
+		p.ErrorClass = ""
+		p.LastError = ""
+		p.persist()
+		metrics.DownloadsStarted.Inc(p.extractor())
+	}
 	ctx, cancel := context.WithCancel(context.Background())
+	var waitErr error
 	defer func() {
 		stdout.Close()
-		p.Complete()
+		p.proc = nil
+		switch {
+		case p.pausing:
+			p.restarting = false
+			p.setPaused()
+		case p.restarting && !p.killed:
+			// started again by the download consumer
+		case !p.killed && isFailure(waitErr):
+			p.setErrored(waitErr)
+		default:
+			p.Complete()
+		}
 		cancel()
 	}()
 	logs := make(chan []byte)
 	go produceLogs(stdout, logs)
-	go p.consumeLogs(ctx, logs)
-	go p.detectYtDlpErrors(stderr)
-	cmd.Wait()
+	go p.consumeLogs(ctx, logs, spawnedAt)
+	// stderr must be fully read before calling Wait, it's needed to classify
+	// the error in case of failure
+	p.detectYtDlpErrors(stderr)
+	waitErr = cmd.Wait()
 }
 
 // ... (rest of the file remains the same as per previous state) ...
@@ -224,26 +286,39 @@ func produceLogs(r io.Reader, logs chan<- []byte) {
 	go func() {
 		scanner := bufio.NewScanner(r)
 		for scanner.Scan() {
-			logs <- scanner.Bytes()
+			// the scanner reuses its buffer
+			logs <- bytes.Clone(scanner.Bytes())
 		}
 	}()
 }
 
-func (p *Process) consumeLogs(ctx context.Context, logs <-chan []byte) {
+func (p *Process) consumeLogs(ctx context.Context, logs <-chan []byte, spawnedAt time.Time) {
+	first := true
 	for {
 		select {
 		case <-ctx.Done():
 			slog.Info("detaching from yt-dlp stdout", slog.String("id", p.getShortId()), slog.String("url", p.Url))
 			return
 		case entry := <-logs:
-			p.parseLogEntry(entry)
+			if first {
+				metrics.SpawnLatency.Observe(time.Since(spawnedAt).Seconds())
+				first = false
+			}
+			if !p.parseLogEntry(entry) {
+				p.log().append("stdout", string(entry))
+			}
 		}
 	}
 }
 
-func (p *Process) parseLogEntry(entry []byte) {
+// Reports whether the entry was one of the progress templates, other lines
+// are plain yt-dlp output.
+func (p *Process) parseLogEntry(entry []byte) bool {
 	var progress ProgressTemplate
 	var postprocess PostprocessTemplate
+	if !json.Valid(entry) {
+		return false
+	}
 	if err := json.Unmarshal(entry, &progress); err == nil {
 		p.Progress = DownloadProgress{
 			Status:     StatusDownloading,
@@ -251,35 +326,76 @@ func (p *Process) parseLogEntry(entry []byte) {
 			Speed:      progress.Speed,
 			ETA:        progress.Eta,
 		}
+		p.emit(EventProgress)
 		slog.Info("progress", slog.String("id", p.getShortId()), slog.String("url", p.Url), slog.String("percentage", progress.Percentage))
 	}
 	if err := json.Unmarshal(entry, &postprocess); err == nil {
 		p.Output.SavedFilePath = postprocess.FilePath
 	}
+	return true
 }
 
 func (p *Process) detectYtDlpErrors(r io.Reader) {
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		slog.Error("yt-dlp process error", slog.String("id", p.getShortId()), slog.String("url", p.Url), slog.String("err", scanner.Text()))
+		line := scanner.Text()
+		p.log().append("stderr", line)
+		if strings.HasPrefix(line, "ERROR:") {
+			p.ErrorClass = ClassifyError(line)
+			p.appendLastError(line)
+		}
+		slog.Error("yt-dlp process error", slog.String("id", p.getShortId()), slog.String("url", p.Url), slog.String("err", line))
+	}
+}
+
+// Keeps the last error lines, to be shown without fetching the whole log.
+func (p *Process) appendLastError(line string) {
+	lines := append(strings.Split(p.LastError, "\n"), line)
+	if p.LastError == "" {
+		lines = lines[1:]
+	}
+	if len(lines) > lastErrorLines {
+		lines = lines[len(lines)-lastErrorLines:]
 	}
+	p.LastError = strings.Join(lines, "\n")
+}
+
+func (p *Process) log() *processLog {
+	p.logsOnce.Do(func() {
+		p.logs = newProcessLog()
+	})
+	return p.logs
+}
+
+// Buffered yt-dlp output of the process.
+func (p *Process) Log() []LogLine {
+	return p.log().snapshot()
+}
+
+// Buffered yt-dlp output of the process and a channel receiving the following
+// lines until ctx is done.
+func (p *Process) TailLog(ctx context.Context) ([]LogLine, <-chan LogLine) {
+	return p.log().observe(ctx)
 }
 
 func (p *Process) Complete() {
+	var serializedMetadata bytes.Buffer
+	json.NewEncoder(&serializedMetadata).Encode(p.Info)
+	entry := &archiver.Message{ // archiver.Message is an alias for archive.Entity (data.ArchiveEntry)
+		Id:           p.Id,
+		Owner:        p.Owner,
+		Subscription: p.Subscription,
+		Path:         p.Output.SavedFilePath,
+		Title:     p.Info.Title,
+		Thumbnail: p.Info.Thumbnail,
+		Source:    p.Info.URL, // Using p.Info.URL (webpage_url from yt-dlp)
+		Metadata:  serializedMetadata.String(),
+		CreatedAt: p.Info.CreatedAt, // This is when metadata was fetched
+		Duration:  int64(p.Info.Duration), // New
+		Format:    p.Info.Ext,             // New
+	}
 	if p.Progress.Percentage == "" && p.Progress.Speed == 0 {
-		var serializedMetadata bytes.Buffer
-		json.NewEncoder(&serializedMetadata).Encode(p.Info)
-		archiver.Publish(&archiver.Message{ // archiver.Message is an alias for archive.Entity (data.ArchiveEntry)
-			Id:        p.Id,
-			Path:      p.Output.SavedFilePath,
-			Title:     p.Info.Title,
-			Thumbnail: p.Info.Thumbnail,
-			Source:    p.Info.URL, // Using p.Info.URL (webpage_url from yt-dlp)
-			Metadata:  serializedMetadata.String(),
-			CreatedAt: p.Info.CreatedAt, // This is when metadata was fetched
-			Duration:  int64(p.Info.Duration), // New
-			Format:    p.Info.Ext,             // New
-		})
+		archiver.Publish(entry)
 	}
 	p.Progress = DownloadProgress{
 		Status:     StatusCompleted,
@@ -291,14 +407,29 @@ func (p *Process) Complete() {
 		p.GetFileName(&p.Output)
 	}
 	slog.Info("finished", slog.String("id", p.getShortId()), slog.String("url", p.Url))
+	if !p.killed {
+		p.collectCompletedMetrics()
+	}
+	p.persist()
+	p.emitEvent(Event{Type: EventCompleted, Archive: entry})
 	memDbEvents <- p
 }
 
 func (p *Process) Kill() error {
-	defer func() { p.Progress.Status = StatusCompleted }()
+	defer func() {
+		p.Progress.Status = StatusCompleted
+		p.persist()
+		p.emit(EventProgress)
+	}()
 	if p.proc == nil {
+		switch p.Progress.Status {
+		case StatusPaused, StatusErrored, StatusScheduled:
+			// nothing is running
+			return nil
+		}
 		return errors.New("*os.Process not set")
 	}
+	p.killed = true
 	pgid, err := syscall.Getpgid(p.proc.Pid)
 	if err != nil {
 		return err
@@ -309,6 +440,112 @@ func (p *Process) Kill() error {
 	return nil
 }
 
+// Pause stops the yt-dlp process keeping the partially downloaded file.
+// The yt-dlp process is interrupted with SIGINT so it can exit cleanly, once
+// it has exited the process is marked as paused.
+// A process which is still waiting for a download slot is paused straight away.
+func (p *Process) Pause() error {
+	if p.Livestream {
+		return errors.New("livestreams cannot be paused")
+	}
+
+	switch p.Progress.Status {
+	case StatusCompleted, StatusErrored:
+		return errors.New("cannot pause a finished download")
+	case StatusPaused:
+		return nil
+	}
+
+	if p.proc == nil {
+		p.setPaused()
+		return nil
+	}
+
+	p.pausing = true
+
+	pgid, err := syscall.Getpgid(p.proc.Pid)
+	if err != nil {
+		p.pausing = false
+		return err
+	}
+	if err := syscall.Kill(-pgid, syscall.SIGINT); err != nil {
+		p.pausing = false
+		return err
+	}
+	return nil
+}
+
+// Stops the yt-dlp process so it's started again with the current rate limit,
+// like when paused the download continues from the partially downloaded file.
+func (p *Process) restart() {
+	if p.proc == nil {
+		return
+	}
+
+	pgid, err := syscall.Getpgid(p.proc.Pid)
+	if err != nil {
+		return
+	}
+
+	p.restarting = true
+	if err := syscall.Kill(-pgid, syscall.SIGINT); err != nil {
+		p.restarting = false
+		return
+	}
+
+	slog.Info("restarting to apply a new rate limit",
+		slog.String("id", p.getShortId()),
+		slog.Int64("rate", p.appliedRate),
+	)
+}
+
+func (p *Process) setPaused() {
+	p.pausing = false
+	p.Progress.Status = StatusPaused
+	p.Progress.Speed = 0
+	p.Progress.ETA = 0
+	slog.Info("paused", slog.String("id", p.getShortId()), slog.String("url", p.Url))
+	p.persist()
+	p.emit(EventProgress)
+}
+
+func (p *Process) setErrored(err error) {
+	if p.ErrorClass == "" {
+		p.ErrorClass = ErrorClassUnknown
+	}
+	p.Progress.Status = StatusErrored
+	p.Progress.Speed = 0
+	p.Progress.ETA = 0
+	slog.Error("download failed",
+		slog.String("id", p.getShortId()),
+		slog.String("url", p.Url),
+		slog.Int("attempt", p.Attempts),
+		slog.String("class", string(p.ErrorClass)),
+		slog.String("err", err.Error()),
+	)
+	metrics.DownloadsFailed.Inc(p.extractor(), string(p.ErrorClass))
+	p.persist()
+	p.emit(EventErrored)
+}
+
+func (p *Process) collectCompletedMetrics() {
+	metrics.DownloadsCompleted.Inc(p.extractor())
+
+	size := p.Info.FilesizeApprox
+	if fi, err := os.Stat(p.Output.SavedFilePath); err == nil {
+		size = fi.Size()
+	}
+	metrics.DownloadedBytes.Add(float64(size), p.extractor())
+}
+
+// Extractor name used as metrics label.
+func (p *Process) extractor() string {
+	if p.Info.Extractor == "" {
+		return "unknown"
+	}
+	return strings.ToLower(p.Info.Extractor)
+}
+
 func (p *Process) GetFileName(o *DownloadOutput) error {
 	var outputPathArgs []string
 	if o.ChannelFolder != "" {
@@ -339,6 +576,8 @@ func (p *Process) SetPending() {
 		CreatedAt: time.Now(),
 	}
 	p.Progress.Status = StatusPending
+	p.persist()
+	p.emit(EventProgress)
 }
 
 func (p *Process) SetMetadata() error {
@@ -359,19 +598,73 @@ func (p *Process) SetMetadata() error {
 		return err
 	}
 	var bufferedStderr bytes.Buffer
-	go func() { io.Copy(&bufferedStderr, stderr) }()
+	stderrDone := make(chan struct{})
+	go func() {
+		io.Copy(&bufferedStderr, stderr)
+		close(stderrDone)
+	}()
 	slog.Info("retrieving metadata", slog.String("id", p.getShortId()), slog.String("url", p.Url))
-	if err := json.NewDecoder(stdout).Decode(&info); err != nil {
-		return err
+	fetchStart := time.Now()
+	decodeErr := json.NewDecoder(stdout).Decode(&info)
+	io.Copy(io.Discard, stdout)
+	<-stderrDone
+	waitErr := cmd.Wait()
+	if waitErr != nil || decodeErr != nil {
+		metrics.MetadataFetchDuration.Observe(time.Since(fetchStart).Seconds(), "error")
+	} else {
+		metrics.MetadataFetchDuration.Observe(time.Since(fetchStart).Seconds(), "ok")
+	}
+	if waitErr != nil {
+		for _, line := range strings.Split(strings.TrimSpace(bufferedStderr.String()), "\n") {
+			p.log().append("stderr", line)
+		}
+		return NewYtDlpError(bufferedStderr.String())
+	}
+	if decodeErr != nil {
+		return decodeErr
 	}
 	p.Info = info
 	p.Progress.Status = StatusPending
-	if err := cmd.Wait(); err != nil {
-		return errors.New(bufferedStderr.String())
-	}
+	p.persist()
+	p.emit(EventMetadata)
 	return nil
 }
 
+// Saves the current state of the process in the job store.
+// Livestreams are persisted by the livestream monitor.
+func (p *Process) persist() {
+	if p.store == nil || p.Livestream {
+		return
+	}
+	if err := p.store.Save(context.Background(), p); err != nil {
+		slog.Warn("failed to persist job", slog.String("id", p.getShortId()), slog.String("err", err.Error()))
+	}
+}
+
+// Snapshot of the process sent to the clients.
+func (p *Process) response() ProcessResponse {
+	return ProcessResponse{
+		Id:            p.Id,
+		Owner:         p.Owner,
+		Info:          p.Info,
+		Progress:      p.Progress,
+		Output:        p.Output,
+		Params:        p.Params,
+		Priority:      p.Priority,
+		QueuePosition: p.QueuePosition,
+		Attempts:      p.Attempts,
+		NextRetryAt:   p.NextRetryAt,
+		LastError:     p.LastError,
+		ErrorClass:    p.ErrorClass,
+		RateLimit:     p.RateLimit,
+		AppliedRate:   p.appliedRate,
+		ScheduledAt:   p.ScheduledAt,
+
+		PreferredFormats:   p.PreferredFormats,
+		PreferredQualities: p.PreferredQualities,
+	}
+}
+
 func (p *Process) getShortId() string { return strings.Split(p.Id, "-")[0] }
 
 func buildFilename(o *DownloadOutput) {
@@ -380,13 +673,3 @@ func buildFilename(o *DownloadOutput) {
     }
 	o.Filename = strings.Replace(o.Filename, ".%(ext)s.%(ext)s", ".%(ext)s", 1)
 }
-
-type ProgressTemplate struct {
-	ETA        float64 `json:"eta"`
-	Percentage string  `json:"percentage"`
-	Speed      float64 `json:"speed"`
-}
-
-type PostprocessTemplate struct {
-	FilePath string `json:"filepath"`
-}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Max number of lines kept for each process
+const processLogSize = 1000
+
+// Max number of error lines copied into ProcessResponse.LastError
+const lastErrorLines = 3
+
+// A single line of the yt-dlp output
+type LogLine struct {
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"` // stdout or stderr
+	Line   string    `json:"line"`
+}
+
+// Bounded ring buffer of the raw output of a yt-dlp process.
+// Once full the oldest lines are overwritten.
+// Observers receive the new lines as soon as they're written, slow observers
+// lose lines instead of blocking the process.
+type processLog struct {
+	mu        sync.Mutex
+	lines     []LogLine
+	next      int // where the next line will be written
+	full      bool
+	observers map[chan LogLine]struct{}
+}
+
+func newProcessLog() *processLog {
+	return &processLog{
+		lines:     make([]LogLine, processLogSize),
+		observers: make(map[chan LogLine]struct{}),
+	}
+}
+
+func (l *processLog) append(stream, line string) {
+	entry := LogLine{
+		Time:   time.Now(),
+		Stream: stream,
+		Line:   line,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines[l.next] = entry
+	l.next = (l.next + 1) % len(l.lines)
+	if l.next == 0 {
+		l.full = true
+	}
+
+	for o := range l.observers {
+		select {
+		case o <- entry:
+		default:
+		}
+	}
+}
+
+// Copy of the buffered lines, from the oldest to the newest.
+func (l *processLog) snapshot() []LogLine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.snapshotLocked()
+}
+
+func (l *processLog) snapshotLocked() []LogLine {
+	if !l.full {
+		return append([]LogLine{}, l.lines[:l.next]...)
+	}
+	return append(append([]LogLine{}, l.lines[l.next:]...), l.lines[:l.next]...)
+}
+
+// Returns the buffered lines and a channel receiving the following ones until
+// ctx is done.
+func (l *processLog) observe(ctx context.Context) ([]LogLine, <-chan LogLine) {
+	ch := make(chan LogLine, 100)
+
+	l.mu.Lock()
+	backlog := l.snapshotLocked()
+	l.observers[ch] = struct{}{}
+	l.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		l.mu.Lock()
+		delete(l.observers, ch)
+		close(ch)
+		l.mu.Unlock()
+	}()
+
+	return backlog, ch
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func lines(log []LogLine) []string {
+	var s []string
+	for _, l := range log {
+		s = append(s, l.Line)
+	}
+	return s
+}
+
+func numbered(from, to int) []string {
+	var s []string
+	for i := from; i <= to; i++ {
+		s = append(s, strconv.Itoa(i))
+	}
+	return s
+}
+
+func TestProcessLogSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		written int
+		want    []string
+	}{
+		{name: "empty", written: 0},
+		{name: "partly filled", written: 3, want: numbered(1, 3)},
+		{name: "full", written: processLogSize, want: numbered(1, processLogSize)},
+		{name: "wrapped around", written: processLogSize + 5, want: numbered(6, processLogSize+5)},
+		{name: "wrapped around twice", written: processLogSize*2 + 1, want: numbered(processLogSize+2, processLogSize*2+1)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newProcessLog()
+			for i := 1; i <= tc.written; i++ {
+				l.append("stdout", strconv.Itoa(i))
+			}
+
+			if got := lines(l.snapshot()); !slices.Equal(got, tc.want) {
+				t.Errorf("snapshot = %d lines from %s, want %d from %s", len(got), firstLine(got), len(tc.want), firstLine(tc.want))
+			}
+		})
+	}
+}
+
+func firstLine(s []string) string {
+	if len(s) == 0 {
+		return "none"
+	}
+	return s[0]
+}
+
+func TestProcessLogObserve(t *testing.T) {
+	l := newProcessLog()
+	l.append("stdout", "before")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	backlog, ch := l.observe(ctx)
+	if got := lines(backlog); !slices.Equal(got, []string{"before"}) {
+		t.Errorf("backlog = %v, want [before]", got)
+	}
+
+	l.append("stderr", "after")
+	if line := <-ch; line.Line != "after" || line.Stream != "stderr" {
+		t.Errorf("received %s %s, want stderr after", line.Stream, line.Line)
+	}
+
+	// a slow observer loses the lines past its buffer, the writer isn't blocked
+	for i := range cap(ch) + 10 {
+		l.append("stdout", strconv.Itoa(i))
+	}
+	if len(ch) != cap(ch) {
+		t.Errorf("%d lines buffered, want %d", len(ch), cap(ch))
+	}
+
+	cancel()
+
+	// closed once the observer is gone, after the buffered lines
+	for range ch {
+	}
+}
+
+func TestAppendLastError(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{name: "one", lines: []string{"ERROR: a"}, want: "ERROR: a"},
+		{name: "up to the limit", lines: []string{"ERROR: a", "ERROR: b", "ERROR: c"}, want: "ERROR: a\nERROR: b\nERROR: c"},
+		{name: "the last ones", lines: []string{"ERROR: a", "ERROR: b", "ERROR: c", "ERROR: d"}, want: "ERROR: b\nERROR: c\nERROR: d"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Process{}
+			for _, line := range tc.lines {
+				p.appendLastError(line)
+			}
+
+			if p.LastError != tc.want {
+				t.Errorf("LastError = %q, want %q", p.LastError, tc.want)
+			}
+		})
+	}
+}
+
+func TestDetectYtDlpErrors(t *testing.T) {
+	p := &Process{Id: "id"}
+
+	p.detectYtDlpErrors(strings.NewReader(
+		"WARNING: falling back\n" +
+			"ERROR: [youtube] abc: Private video. Sign in if you've been granted access to this video\n",
+	))
+
+	if p.ErrorClass != ErrorClassPrivateVideo {
+		t.Errorf("class = %s, want %s", p.ErrorClass, ErrorClassPrivateVideo)
+	}
+	if !strings.HasPrefix(p.LastError, "ERROR: [youtube] abc") || strings.Contains(p.LastError, "WARNING") {
+		t.Errorf("LastError = %q, want only the error line", p.LastError)
+	}
+
+	// every line is kept in the log, tagged with its stream
+	log := p.Log()
+	if len(log) != 2 || log[0].Stream != "stderr" || log[0].Line != "WARNING: falling back" {
+		t.Errorf("log = %+v, want both stderr lines", log)
+	}
+}
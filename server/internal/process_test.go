@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+func TestStartPaused(t *testing.T) {
+	conf := config.Instance()
+	defer func(path string) { conf.DownloaderPath = path }(conf.DownloaderPath)
+
+	// spawning it would fail the test with a panic
+	conf.DownloaderPath = filepath.Join(t.TempDir(), "yt-dlp")
+
+	p := &Process{
+		Id:       "id",
+		Url:      "https://example.com/v",
+		Progress: DownloadProgress{Status: StatusPaused},
+		Output:   DownloadOutput{Path: t.TempDir()},
+	}
+
+	p.Start()
+
+	if p.Progress.Status != StatusPaused {
+		t.Errorf("status = %d, want paused", p.Progress.Status)
+	}
+	if p.Attempts != 0 {
+		t.Errorf("%d attempts, want none", p.Attempts)
+	}
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Checks the storage and daily downloads quotas of a user before queueing n
+// downloads of theirs, and counts them towards the daily quota. The
+// concurrent downloads quota is enforced by the download queue.
+// Downloads without an owner, queued without authentication, have no quotas.
+func (m *MemoryDB) Reserve(ctx context.Context, owner string, n int) error {
+	if owner == "" || m.store == nil {
+		return nil
+	}
+
+	quotas := config.Instance().Quotas
+
+	maxStorage, err := ParseRate(quotas.MaxStorage)
+	if err != nil {
+		return err
+	}
+
+	if maxStorage > 0 {
+		used, err := dirSize(config.Instance().UserDownloadPath(owner))
+		if err != nil {
+			return err
+		}
+		if used >= maxStorage {
+			return fmt.Errorf("%w: storage limit of %s reached", ErrQuotaExceeded, quotas.MaxStorage)
+		}
+	}
+
+	return m.store.countDownloads(ctx, owner, n, quotas.MaxDailyDownloads)
+}
+
+// Adds n to the downloads of the owner of the current day, failing if they'd
+// be more than max. A max of 0 means no limit.
+func (s *JobStore) countDownloads(ctx context.Context, owner string, n, max int) error {
+	day := time.Now().Format(time.DateOnly)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(SUM(count), 0) FROM daily_downloads WHERE owner = ? AND day = ?",
+		owner,
+		day,
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if max > 0 && count+n > max {
+		return fmt.Errorf("%w: %d of %d daily downloads left", ErrQuotaExceeded, max-min(count, max), max)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO daily_downloads (owner, day, count) VALUES (?, ?, ?)
+		ON CONFLICT (owner, day) DO UPDATE SET count = count + excluded.count`,
+		owner,
+		day,
+		n,
+	)
+	if err != nil {
+		return err
+	}
+
+	// the previous days aren't needed anymore
+	if _, err := tx.ExecContext(ctx, "DELETE FROM daily_downloads WHERE day < ?", day); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Total size of the files in a directory, 0 if it doesn't exist yet.
+func dirSize(root string) (int64, error) {
+	var size int64
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed meanwhile
+		}
+		size += info.Size()
+
+		return nil
+	})
+
+	return size, err
+}
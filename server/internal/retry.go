@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+// yt-dlp exits with 101 when the download is cancelled on purpose, e.g. by
+// --max-downloads or --break-on-existing.
+const exitCodeCancelled = 101
+
+func isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode() != exitCodeCancelled
+	}
+
+	return true
+}
+
+func shouldRetry(policy config.RetryPolicy, p *Process) bool {
+	return p.Attempts < policy.MaxAttempts &&
+		!p.ErrorClass.Permanent() &&
+		slices.Contains(policy.RetryOn, string(p.ErrorClass))
+}
+
+// Exponential backoff: the delay before the n-th retry is base*2^(n-1),
+// capped at the configured max and randomized by the jitter fraction.
+func retryDelay(policy config.RetryPolicy, attempt int) time.Duration {
+	delay := float64(policy.BackoffBase) * math.Pow(2, float64(attempt-1))
+
+	if policy.BackoffMax > 0 {
+		delay = math.Min(delay, float64(policy.BackoffMax))
+	}
+
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// Schedules a failed process to be put back in the download queue, according
+// to the configured retry policy.
+func (m *MessageQueue) scheduleRetry(p *Process) {
+	policy := config.Instance().Retry
+
+	if !shouldRetry(policy, p) {
+		slog.Warn("not retrying failed download",
+			slog.String("id", p.getShortId()),
+			slog.Int("attempts", p.Attempts),
+			slog.String("class", string(p.ErrorClass)),
+		)
+		return
+	}
+
+	m.retryAt(p, time.Now().Add(retryDelay(policy, p.Attempts)))
+}
+
+// Puts the process back in the download queue at the given time.
+func (m *MessageQueue) retryAt(p *Process, at time.Time) {
+	p.NextRetryAt = &at
+	p.persist()
+
+	slog.Info("scheduled retry of failed download",
+		slog.String("id", p.getShortId()),
+		slog.Int("attempts", p.Attempts),
+		slog.Time("at", at),
+	)
+
+	m.runAt(p.Id, at, func() { m.retry(p) })
+}
+
+func (m *MessageQueue) retry(p *Process) {
+	if p.Progress.Status != StatusErrored {
+		return
+	}
+
+	p.NextRetryAt = nil
+	p.Progress.Status = StatusPending
+	p.persist()
+	p.emit(EventProgress)
+	m.downloads.push(p)
+}
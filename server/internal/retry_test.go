@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+func TestShouldRetry(t *testing.T) {
+	policy := config.RetryPolicy{
+		MaxAttempts: 3,
+		RetryOn:     []string{"rate_limited", "network", "private_video"},
+	}
+
+	tests := []struct {
+		name     string
+		attempts int
+		class    ErrorClass
+		want     bool
+	}{
+		{name: "retryable", attempts: 1, class: ErrorClassNetwork, want: true},
+		{name: "last retry", attempts: 2, class: ErrorClassRateLimited, want: true},
+		{name: "attempts exhausted", attempts: 3, class: ErrorClassNetwork},
+		{name: "not in retry_on", attempts: 1, class: ErrorClassServerError},
+		{name: "unknown error", attempts: 1, class: ErrorClassUnknown},
+		// even if listed, retrying can't change the outcome
+		{name: "permanent", attempts: 1, class: ErrorClassPrivateVideo},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Process{Attempts: tc.attempts, ErrorClass: tc.class}
+
+			if got := shouldRetry(policy, p); got != tc.want {
+				t.Errorf("shouldRetry() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+
+	if shouldRetry(config.RetryPolicy{MaxAttempts: 1, RetryOn: policy.RetryOn}, &Process{Attempts: 1, ErrorClass: ErrorClassNetwork}) {
+		t.Error("retried with max_attempts 1")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := config.RetryPolicy{
+		BackoffBase: time.Second * 30,
+		BackoffMax:  time.Minute * 3,
+	}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: time.Second * 30},
+		{attempt: 2, want: time.Minute},
+		{attempt: 3, want: time.Minute * 2},
+		{attempt: 4, want: time.Minute * 3},
+		{attempt: 20, want: time.Minute * 3},
+	}
+
+	for _, tc := range tests {
+		if got := retryDelay(policy, tc.attempt); got != tc.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tc.attempt, got, tc.want)
+		}
+	}
+
+	// without a max the delay keeps doubling
+	if got := retryDelay(config.RetryPolicy{BackoffBase: time.Second}, 11); got != time.Second*1024 {
+		t.Errorf("retryDelay() without max = %s, want %s", got, time.Second*1024)
+	}
+
+	// the jitter stays within its fraction of the delay
+	policy.Jitter = 0.2
+	for range 100 {
+		if got := retryDelay(policy, 2); got < time.Second*48 || got > time.Second*72 {
+			t.Fatalf("retryDelay() with jitter = %s, want within 48s and 72s", got)
+		}
+	}
+}
+
+func TestIsFailure(t *testing.T) {
+	exit := func(code string) error {
+		return exec.Command("sh", "-c", "exit "+code).Run()
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "success", err: nil},
+		{name: "cancelled by yt-dlp", err: exit("101")},
+		{name: "failed", err: exit("1"), want: true},
+		{name: "not started", err: errors.New("exec: not found"), want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isFailure(tc.err); got != tc.want {
+				t.Errorf("isFailure(%v) = %t, want %t", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus int
+		wantQueued bool
+	}{
+		{name: "still failed", status: StatusErrored, wantStatus: StatusPending, wantQueued: true},
+		{name: "killed meanwhile", status: StatusCompleted, wantStatus: StatusCompleted},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				m  = newTestQueue()
+				at = time.Now()
+				p  = &Process{Id: "id", Progress: DownloadProgress{Status: tc.status}, NextRetryAt: &at}
+			)
+
+			m.retry(p)
+
+			if p.Progress.Status != tc.wantStatus {
+				t.Errorf("status = %d, want %d", p.Progress.Status, tc.wantStatus)
+			}
+			if queued := p.QueuePosition > 0; queued != tc.wantQueued {
+				t.Errorf("queued = %t, want %t", queued, tc.wantQueued)
+			}
+			if tc.wantQueued && p.NextRetryAt != nil {
+				t.Error("the retry time is still set after the retry")
+			}
+		})
+	}
+}
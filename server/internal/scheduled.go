@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/common"
+)
+
+// Holds a process until its scheduled time, then releases it into the queue.
+func (m *MessageQueue) schedule(p *Process) {
+	at := *p.ScheduledAt
+
+	if p.Info.URL == "" {
+		p.Info = common.DownloadInfo{
+			URL:       p.Url,
+			Title:     p.Url,
+			CreatedAt: time.Now(),
+		}
+	}
+	p.Progress.Status = StatusScheduled
+	p.persist()
+	p.emit(EventProgress)
+
+	slog.Info("scheduled download",
+		slog.String("id", p.getShortId()),
+		slog.String("url", p.Url),
+		slog.Time("at", at),
+	)
+
+	m.runAt(p.Id, at, func() { m.release(p) })
+}
+
+func (m *MessageQueue) release(p *Process) {
+	if p.Progress.Status != StatusScheduled {
+		return
+	}
+
+	slog.Info("releasing scheduled download", slog.String("id", p.getShortId()))
+
+	p.ScheduledAt = nil
+	m.Publish(p)
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDownloadRequestScheduledAt(t *testing.T) {
+	var (
+		early = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		late  = early.Add(time.Hour)
+	)
+
+	tests := []struct {
+		name string
+		body string
+		want *time.Time
+	}{
+		{name: "right away", body: `{"url": "https://example.com/v"}`},
+		{name: "start_at", body: `{"start_at": "2024-01-01T12:00:00Z"}`, want: &early},
+		{name: "not_before", body: `{"not_before": "2024-01-01T13:00:00Z"}`, want: &late},
+		{name: "later not_before", body: `{"start_at": "2024-01-01T12:00:00Z", "not_before": "2024-01-01T13:00:00Z"}`, want: &late},
+		{name: "later start_at", body: `{"start_at": "2024-01-01T13:00:00Z", "not_before": "2024-01-01T12:00:00Z"}`, want: &late},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var req DownloadRequest
+			if err := json.Unmarshal([]byte(tc.body), &req); err != nil {
+				t.Fatal(err)
+			}
+
+			got := req.ScheduledAt()
+			if (got == nil) != (tc.want == nil) || (got != nil && !got.Equal(*tc.want)) {
+				t.Errorf("ScheduledAt() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPublishScheduled(t *testing.T) {
+	var (
+		later  = time.Now().Add(time.Hour)
+		before = time.Now().Add(-time.Minute)
+	)
+
+	tests := []struct {
+		name        string
+		scheduledAt *time.Time
+		wantStatus  int
+		wantQueued  bool
+	}{
+		{name: "not scheduled", wantStatus: StatusPending, wantQueued: true},
+		{name: "in the future", scheduledAt: &later, wantStatus: StatusScheduled},
+		{name: "already due", scheduledAt: &before, wantStatus: StatusPending, wantQueued: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				m = newTestQueue()
+				p = &Process{Id: "id", Url: "https://example.com/v", ScheduledAt: tc.scheduledAt}
+			)
+			defer m.Remove(p.Id)
+
+			m.Publish(p)
+
+			if p.Progress.Status != tc.wantStatus {
+				t.Errorf("status = %d, want %d", p.Progress.Status, tc.wantStatus)
+			}
+			if queued := p.QueuePosition > 0; queued != tc.wantQueued {
+				t.Errorf("queued = %t, want %t", queued, tc.wantQueued)
+			}
+
+			// a held download waits for its timer and keeps its time
+			held := tc.wantStatus == StatusScheduled
+			if got := m.hasTimer(p.Id); got != held {
+				t.Errorf("timer set = %t, want %t", got, held)
+			}
+			if (p.ScheduledAt != nil) != held {
+				t.Errorf("scheduled at %v once published", p.ScheduledAt)
+			}
+			if held && p.Info.URL != p.Url {
+				t.Errorf("info url = %q, want a placeholder until the metadata is fetched", p.Info.URL)
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus int
+		wantQueued bool
+	}{
+		{name: "scheduled", status: StatusScheduled, wantStatus: StatusPending, wantQueued: true},
+		// paused or removed before its time
+		{name: "paused meanwhile", status: StatusPaused, wantStatus: StatusPaused},
+		{name: "completed meanwhile", status: StatusCompleted, wantStatus: StatusCompleted},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				m = newTestQueue()
+				p = &Process{Id: "id", Url: "https://example.com/v", ScheduledAt: &later, Progress: DownloadProgress{Status: tc.status}}
+			)
+			defer m.Remove(p.Id)
+
+			m.release(p)
+
+			if p.Progress.Status != tc.wantStatus {
+				t.Errorf("status = %d, want %d", p.Progress.Status, tc.wantStatus)
+			}
+			if queued := p.QueuePosition > 0; queued != tc.wantQueued {
+				t.Errorf("queued = %t, want %t", queued, tc.wantQueued)
+			}
+			if tc.wantQueued && p.ScheduledAt != nil {
+				t.Error("the released process kept its schedule")
+			}
+		})
+	}
+}
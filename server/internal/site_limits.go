@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+// Enforces the per-site politeness rules. It's not thread-safe, it's only
+// used by the download queue while holding its lock.
+type siteLimiter struct {
+	rules   []config.SiteLimit
+	states  []siteState
+	running map[string]int // process id -> index of the rule it started under
+}
+
+type siteState struct {
+	active int
+	starts []time.Time // start times within the last hour
+}
+
+func newSiteLimiter(rules []config.SiteLimit) *siteLimiter {
+	return &siteLimiter{
+		rules:   rules,
+		states:  make([]siteState, len(rules)),
+		running: make(map[string]int),
+	}
+}
+
+// Index of the first rule matching the process, -1 if none.
+func (l *siteLimiter) match(p *Process) int {
+	host := ""
+	if u, err := url.Parse(p.Url); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+
+	for i, rule := range l.rules {
+		for _, domain := range rule.Domains {
+			domain = strings.ToLower(domain)
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return i
+			}
+		}
+		for _, extractor := range rule.Extractors {
+			if p.Info.Extractor != "" && strings.EqualFold(p.Info.Extractor, extractor) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Reports whether the process can be started now, if it can't because of a
+// time based rule it also returns when it's worth to check again.
+// A zero time means it has to wait for another download of the same site
+// to finish.
+func (l *siteLimiter) allow(p *Process, now time.Time) (bool, time.Time) {
+	i := l.match(p)
+	if i < 0 {
+		return true, time.Time{}
+	}
+
+	var (
+		rule  = l.rules[i]
+		state = &l.states[i]
+	)
+
+	state.prune(now)
+
+	if rule.MaxConcurrent > 0 && state.active >= rule.MaxConcurrent {
+		return false, time.Time{}
+	}
+
+	var wakeAt time.Time
+
+	if rule.MinGap > 0 && len(state.starts) > 0 {
+		if next := state.starts[len(state.starts)-1].Add(rule.MinGap); next.After(now) {
+			wakeAt = next
+		}
+	}
+	if rule.MaxPerHour > 0 && len(state.starts) >= rule.MaxPerHour {
+		// wait for the oldest start to fall out of the window
+		if next := state.starts[len(state.starts)-rule.MaxPerHour].Add(time.Hour); next.After(wakeAt) {
+			wakeAt = next
+		}
+	}
+
+	return wakeAt.IsZero(), wakeAt
+}
+
+func (l *siteLimiter) started(p *Process, now time.Time) {
+	i := l.match(p)
+	if i < 0 {
+		return
+	}
+
+	l.states[i].active++
+	l.states[i].starts = append(l.states[i].starts, now)
+	l.running[p.Id] = i
+}
+
+func (l *siteLimiter) finished(p *Process) {
+	i, ok := l.running[p.Id]
+	if !ok {
+		return
+	}
+
+	l.states[i].active--
+	delete(l.running, p.Id)
+}
+
+func (s *siteState) prune(now time.Time) {
+	cutoff := now.Add(-time.Hour)
+
+	n := 0
+	for n < len(s.starts) && !s.starts[n].After(cutoff) {
+		n++
+	}
+	s.starts = s.starts[n:]
+}
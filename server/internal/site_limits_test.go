@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/common"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+func TestSiteLimiterMatch(t *testing.T) {
+	l := newSiteLimiter([]config.SiteLimit{
+		{Domains: []string{"YouTube.com", "youtu.be"}},
+		{Extractors: []string{"vimeo"}},
+	})
+
+	tests := []struct {
+		name      string
+		url       string
+		extractor string
+		want      int
+	}{
+		{name: "domain", url: "https://youtube.com/watch?v=a", want: 0},
+		{name: "subdomain", url: "https://www.YOUTUBE.com/watch?v=a", want: 0},
+		{name: "second domain", url: "https://youtu.be/a", want: 0},
+		{name: "suffix isn't a subdomain", url: "https://notyoutube.com/a", want: -1},
+		{name: "extractor", url: "https://player.example.com/a", extractor: "Vimeo", want: 1},
+		{name: "extractor not known yet", url: "https://player.example.com/a", want: -1},
+		{name: "no rule", url: "https://example.com/a", want: -1},
+		{name: "invalid url", url: "://", want: -1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Process{Url: tc.url, Info: common.DownloadInfo{Extractor: tc.extractor}}
+
+			if got := l.match(p); got != tc.want {
+				t.Errorf("match() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSiteLimiterAllow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		rule       config.SiteLimit
+		starts     []time.Duration // ago, from the oldest
+		running    int             // of the starts, still downloading
+		want       bool
+		wantWakeAt time.Time
+	}{
+		{
+			name: "no previous start",
+			rule: config.SiteLimit{MaxConcurrent: 1, MinGap: time.Minute, MaxPerHour: 1},
+			want: true,
+		},
+		{
+			name:    "max concurrent reached",
+			rule:    config.SiteLimit{MaxConcurrent: 2},
+			starts:  []time.Duration{time.Hour * 2, time.Hour * 2},
+			running: 2,
+		},
+		{
+			name:    "below max concurrent",
+			rule:    config.SiteLimit{MaxConcurrent: 2},
+			starts:  []time.Duration{time.Minute},
+			running: 1,
+			want:    true,
+		},
+		{
+			name:       "within the min gap",
+			rule:       config.SiteLimit{MinGap: time.Minute},
+			starts:     []time.Duration{time.Second * 20},
+			wantWakeAt: now.Add(time.Second * 40),
+		},
+		{
+			name:   "past the min gap",
+			rule:   config.SiteLimit{MinGap: time.Minute},
+			starts: []time.Duration{time.Minute},
+			want:   true,
+		},
+		{
+			name:       "max per hour reached",
+			rule:       config.SiteLimit{MaxPerHour: 2},
+			starts:     []time.Duration{time.Minute * 50, time.Minute * 40, time.Minute * 10},
+			wantWakeAt: now.Add(time.Minute * 20),
+		},
+		{
+			name:   "starts older than an hour",
+			rule:   config.SiteLimit{MaxPerHour: 2},
+			starts: []time.Duration{time.Hour * 2, time.Minute * 61, time.Minute * 10},
+			want:   true,
+		},
+		{
+			name:       "the later of the time based rules",
+			rule:       config.SiteLimit{MinGap: time.Minute * 5, MaxPerHour: 1},
+			starts:     []time.Duration{time.Minute * 58},
+			wantWakeAt: now.Add(time.Minute * 2),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.rule.Domains = []string{"example.com"}
+			l := newSiteLimiter([]config.SiteLimit{tc.rule})
+
+			for i, ago := range tc.starts {
+				p := &Process{Id: string(rune('a' + i)), Url: "https://example.com/v"}
+				l.started(p, now.Add(-ago))
+				if i >= tc.running {
+					l.finished(p)
+				}
+			}
+
+			ok, wakeAt := l.allow(&Process{Url: "https://example.com/next"}, now)
+			if ok != tc.want || !wakeAt.Equal(tc.wantWakeAt) {
+				t.Errorf("allow() = %t, %s, want %t, %s", ok, wakeAt, tc.want, tc.wantWakeAt)
+			}
+
+			// other sites aren't limited
+			if ok, _ := l.allow(&Process{Url: "https://other.com/v"}, now); !ok {
+				t.Error("a site without rules was held")
+			}
+		})
+	}
+}
+
+func TestDownloadQueueSiteLimits(t *testing.T) {
+	q := newDownloadQueue(3, []config.SiteLimit{{Domains: []string{"example.com"}, MaxConcurrent: 1}}, 0)
+
+	var (
+		first  = &Process{Id: "first", Url: "https://example.com/1", Priority: PriorityHigh}
+		second = &Process{Id: "second", Url: "https://example.com/2", Priority: PriorityHigh}
+		other  = &Process{Id: "other", Url: "https://other.com/1"}
+	)
+	for _, p := range []*Process{first, second, other} {
+		q.push(p)
+	}
+
+	// the held site doesn't hold back the downloads of the others
+	for _, want := range []*Process{first, other} {
+		if got := q.pop(); got != want {
+			t.Fatalf("popped %s, want %s", got.Id, want.Id)
+		}
+	}
+
+	if i, _ := q.next(time.Now()); i >= 0 {
+		t.Fatalf("%s can start while the other download of its site is running", q.items[i].process.Id)
+	}
+
+	q.done(first)
+
+	if got := q.pop(); got != second {
+		t.Errorf("popped %s, want second", got.Id)
+	}
+}
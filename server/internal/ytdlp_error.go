@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Class of a yt-dlp failure, exposed to the clients as an error code and used
+// to decide whether a download is retried.
+type ErrorClass string
+
+const (
+	ErrorClassUnknown        ErrorClass = "unknown"
+	ErrorClassGeoBlocked     ErrorClass = "geo_blocked"
+	ErrorClassPrivateVideo   ErrorClass = "private_video"
+	ErrorClassMembersOnly    ErrorClass = "members_only"
+	ErrorClassAgeRestricted  ErrorClass = "age_restricted"
+	ErrorClassRemoved        ErrorClass = "removed"
+	ErrorClassRateLimited    ErrorClass = "rate_limited"
+	ErrorClassUnsupportedURL ErrorClass = "unsupported_url"
+	ErrorClassFfmpegMissing  ErrorClass = "ffmpeg_missing"
+	ErrorClassDiskFull       ErrorClass = "disk_full"
+	ErrorClassServerError    ErrorClass = "server_error"
+	ErrorClassNetwork        ErrorClass = "network"
+	ErrorClassInvalidOutput  ErrorClass = "invalid_output"
+)
+
+// Rules are evaluated in order, the first match wins. More specific rules
+// come first since yt-dlp often wraps the cause in a generic message,
+// e.g. "Video unavailable. This video is private", so removed videos are
+// matched last among the permanent errors.
+var errorClassRules = []struct {
+	re    *regexp.Regexp
+	class ErrorClass
+}{
+	{regexp.MustCompile(`(?i)not made this video available|not available (in|from) your (country|location)|geo.?restrict|blocked it in your country`), ErrorClassGeoBlocked},
+	{regexp.MustCompile(`(?i)private video|video is private|this video has been made private`), ErrorClassPrivateVideo},
+	{regexp.MustCompile(`(?i)members.only|join this channel to get access|available to this channel's members|requires a subscription`), ErrorClassMembersOnly},
+	{regexp.MustCompile(`(?i)sign in to confirm your age|age.restricted|inappropriate for some users|age verification`), ErrorClassAgeRestricted},
+	{regexp.MustCompile(`(?i)not a bot|HTTP Error 429|too many requests|rate.?limit`), ErrorClassRateLimited},
+	{regexp.MustCompile(`(?i)unsupported url|is not a valid url`), ErrorClassUnsupportedURL},
+	{regexp.MustCompile(`(?i)ffmpeg( and ffprobe)? (is|are) not (installed|found)|ffprobe( and ffmpeg)? not found|ffmpeg-location .* does not exist`), ErrorClassFfmpegMissing},
+	{regexp.MustCompile(`(?i)no space left on device|errno 28|disk quota exceeded`), ErrorClassDiskFull},
+	{regexp.MustCompile(`(?i)has been removed|account .*terminated|no longer available|video unavailable|does not exist|HTTP Error 404|HTTP Error 410`), ErrorClassRemoved},
+	{regexp.MustCompile(`HTTP Error 5\d\d`), ErrorClassServerError},
+	{regexp.MustCompile(`(?i)timed out|connection (reset|refused|aborted)|name resolution|name or service not known|network is unreachable|IncompleteRead`), ErrorClassNetwork},
+}
+
+// Maps a yt-dlp error line to its class.
+func ClassifyError(line string) ErrorClass {
+	for _, rule := range errorClassRules {
+		if rule.re.MatchString(line) {
+			return rule.class
+		}
+	}
+	return ErrorClassUnknown
+}
+
+// Permanent errors won't go away by trying again later.
+func (c ErrorClass) Permanent() bool {
+	switch c {
+	case ErrorClassGeoBlocked,
+		ErrorClassPrivateVideo,
+		ErrorClassMembersOnly,
+		ErrorClassAgeRestricted,
+		ErrorClassRemoved,
+		ErrorClassUnsupportedURL,
+		ErrorClassInvalidOutput:
+		return true
+	}
+	return false
+}
+
+// Classified yt-dlp failure.
+type YtDlpError struct {
+	Class   ErrorClass `json:"code"`
+	Message string     `json:"message"`
+}
+
+func (e *YtDlpError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Class, e.Message)
+}
+
+// Builds a YtDlpError out of the stderr of a failed yt-dlp process. The last
+// ERROR line is the most relevant one, if there's none the whole output is
+// used.
+func NewYtDlpError(stderr string) *YtDlpError {
+	message := strings.TrimSpace(stderr)
+
+	lines := strings.Split(message, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.HasPrefix(lines[i], "ERROR:") {
+			message = strings.TrimSpace(lines[i])
+			break
+		}
+	}
+
+	return &YtDlpError{
+		Class:   ClassifyError(message),
+		Message: message,
+	}
+}
+
+// Converts the error returned by exec.Cmd.Output into a YtDlpError, any other
+// error is returned as is.
+func ClassifyExecError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return NewYtDlpError(string(exitErr.Stderr))
+	}
+	return err
+}
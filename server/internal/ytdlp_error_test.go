@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"testing"
+)
+
+// Error lines as printed by yt-dlp.
+var errorCorpus = []struct {
+	line  string
+	class ErrorClass
+}{
+	// geo blocked
+	{"ERROR: [youtube] dQw4w9WgXcQ: The uploader has not made this video available in your country", ErrorClassGeoBlocked},
+	{"ERROR: [BBCiPlayer] p0hb5xcs: This video is not available from your location due to geo restriction", ErrorClassGeoBlocked},
+	{"ERROR: [vimeo] 123456: The uploader has not made this video available in your country. You might want to use a VPN or a proxy server (with --proxy) to workaround.", ErrorClassGeoBlocked},
+	{"ERROR: [youtube] xyz: Video unavailable. The uploader has not made this video available in your country", ErrorClassGeoBlocked},
+
+	// private
+	{"ERROR: [youtube] 2Q5K8N3b6bE: Private video. Sign in if you've been granted access to this video", ErrorClassPrivateVideo},
+	{"ERROR: [youtube] abc: Video unavailable. This video is private", ErrorClassPrivateVideo},
+	{"ERROR: [vimeo] 987654: This video is private", ErrorClassPrivateVideo},
+
+	// members only
+	{"ERROR: [youtube] 0zM3nApSvMg: Join this channel to get access to members-only content like this video, and other exclusive perks.", ErrorClassMembersOnly},
+	{"ERROR: [youtube] Ks-_Mh1QhMc: This video is available to this channel's members on level: Tier 1 (or any higher level). Join this channel to get access to members-only content and other exclusive perks.", ErrorClassMembersOnly},
+	{"ERROR: [patreon] 12345: This post requires a subscription", ErrorClassMembersOnly},
+
+	// age restricted
+	{"ERROR: [youtube] 07FYdnEawAQ: Sign in to confirm your age. This video may be inappropriate for some users. Use --cookies-from-browser or --cookies for the authentication.", ErrorClassAgeRestricted},
+	{"ERROR: [youtube] HtVdAasjOgU: This video may be inappropriate for some users.", ErrorClassAgeRestricted},
+
+	// removed
+	{"ERROR: [youtube] aaaaaaaaaaa: Video unavailable. This video has been removed by the uploader", ErrorClassRemoved},
+	{"ERROR: [youtube] bbbbbbbbbbb: Video unavailable. This video is no longer available because the YouTube account associated with this video has been terminated.", ErrorClassRemoved},
+	{"ERROR: [youtube] ccccccccccc: Video unavailable. This video has been removed for violating YouTube's Terms of Service", ErrorClassRemoved},
+	{"ERROR: [youtube] ddddddddddd: Video unavailable", ErrorClassRemoved},
+	{"ERROR: [twitter] 1234567890: Unable to download JSON metadata: HTTP Error 404: Not Found (caused by <HTTPError 404: Not Found>)", ErrorClassRemoved},
+	{"ERROR: [youtube:tab] @nonexistent: This channel does not exist.", ErrorClassRemoved},
+
+	// rate limited
+	{"ERROR: [youtube] dQw4w9WgXcQ: Sign in to confirm you’re not a bot. Use --cookies-from-browser or --cookies for the authentication.", ErrorClassRateLimited},
+	{"ERROR: unable to download video data: HTTP Error 429: Too Many Requests", ErrorClassRateLimited},
+	{"ERROR: [instagram] Cx1y2z3: Requested content is not available, rate-limit reached or login required. Use --cookies, --cookies-from-browser, --username and --password, --netrc-cmd, or --netrc (instagram) to provide account credentials", ErrorClassRateLimited},
+
+	// unsupported url
+	{"ERROR: Unsupported URL: https://example.com/", ErrorClassUnsupportedURL},
+	{"ERROR: [generic] Unable to download webpage: Unsupported URL: https://example.org/page", ErrorClassUnsupportedURL},
+	{"ERROR: 'notaurl' is not a valid URL. Set --default-search \"ytsearch\" (or run  yt-dlp \"ytsearch:notaurl\" ) to search YouTube", ErrorClassUnsupportedURL},
+
+	// ffmpeg missing
+	{"ERROR: You have requested merging of multiple formats but ffmpeg is not installed. Aborting due to --abort-on-error", ErrorClassFfmpegMissing},
+	{"ERROR: Postprocessing: ffprobe and ffmpeg not found. Please install or provide the path using --ffmpeg-location", ErrorClassFfmpegMissing},
+	{"ERROR: ffmpeg-location /opt/ffmpeg does not exist! Continuing without ffmpeg", ErrorClassFfmpegMissing},
+
+	// disk full
+	{"ERROR: unable to write data: [Errno 28] No space left on device", ErrorClassDiskFull},
+	{"ERROR: Unable to open fragment 3; [Errno 122] Disk quota exceeded", ErrorClassDiskFull},
+
+	// server error
+	{"ERROR: unable to download video data: HTTP Error 503: Service Unavailable", ErrorClassServerError},
+	{"ERROR: [youtube] dQw4w9WgXcQ: Unable to download API page: HTTP Error 500: Internal Server Error (caused by <HTTPError 500: Internal Server Error>)", ErrorClassServerError},
+
+	// network
+	{"ERROR: [youtube] dQw4w9WgXcQ: Unable to download API page: <urlopen error [Errno -3] Temporary failure in name resolution> (caused by TransportError('<urlopen error [Errno -3] Temporary failure in name resolution>'))", ErrorClassNetwork},
+	{"ERROR: unable to download video data: The read operation timed out", ErrorClassNetwork},
+	{"ERROR: [download] Got error: [Errno 104] Connection reset by peer", ErrorClassNetwork},
+	{"ERROR: 3 bytes read, 1024 more expected (caused by IncompleteRead(3 bytes read, 1024 more expected))", ErrorClassNetwork},
+
+	// unknown
+	{"ERROR: Postprocessing: Conversion failed!", ErrorClassUnknown},
+	{"ERROR: [youtube] dQw4w9WgXcQ: Requested format is not available. Use --list-formats for a list of available formats", ErrorClassUnknown},
+}
+
+func TestClassifyError(t *testing.T) {
+	for _, tc := range errorCorpus {
+		if got := ClassifyError(tc.line); got != tc.class {
+			t.Errorf("ClassifyError(%q) = %s, want %s", tc.line, got, tc.class)
+		}
+	}
+}
+
+func TestNewYtDlpError(t *testing.T) {
+	stderr := "WARNING: [youtube] Falling back to generic n function search\n" +
+		"ERROR: [youtube] 2Q5K8N3b6bE: Private video. Sign in if you've been granted access to this video\n"
+
+	err := NewYtDlpError(stderr)
+
+	if err.Class != ErrorClassPrivateVideo {
+		t.Errorf("got class %s, want %s", err.Class, ErrorClassPrivateVideo)
+	}
+	if err.Message != "ERROR: [youtube] 2Q5K8N3b6bE: Private video. Sign in if you've been granted access to this video" {
+		t.Errorf("unexpected message %q", err.Message)
+	}
+}
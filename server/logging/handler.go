@@ -9,9 +9,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/openid"
 )
 
 var upgrader = websocket.Upgrader{
@@ -91,12 +89,10 @@ func sse(logger *ObservableLogger) http.HandlerFunc {
 
 func ApplyRouter(logger *ObservableLogger) func(chi.Router) {
 	return func(r chi.Router) {
-		if config.Instance().RequireAuth {
+		if middlewares.AuthEnabled() {
 			r.Use(middlewares.Authenticated)
 		}
-		if config.Instance().UseOpenId {
-			r.Use(openid.Middleware)
-		}
+		r.Use(middlewares.RequireScope(middlewares.ScopeAdmin))
 		r.Get("/ws", webSocket(logger))
 		r.Get("/sse", sse(logger))
 	}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+)
+
+// Prefix of the names of the metrics
+const Namespace = "ytdlp_webui_"
+
+var (
+	DownloadsStarted = NewCounterVec(
+		Namespace+"downloads_started_total",
+		"Number of yt-dlp download processes started.",
+		"extractor",
+	)
+	DownloadsCompleted = NewCounterVec(
+		Namespace+"downloads_completed_total",
+		"Number of downloads completed successfully.",
+		"extractor",
+	)
+	DownloadsFailed = NewCounterVec(
+		Namespace+"downloads_failed_total",
+		"Number of failed download attempts.",
+		"extractor", "error_class",
+	)
+	DownloadedBytes = NewCounterVec(
+		Namespace+"downloaded_bytes_total",
+		"Size of the completed downloads in bytes.",
+		"extractor",
+	)
+	SpawnLatency = NewHistogramVec(
+		Namespace+"ytdlp_spawn_seconds",
+		"Time between spawning yt-dlp and its first line of output.",
+		[]float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	)
+	MetadataFetchDuration = NewHistogramVec(
+		Namespace+"metadata_fetch_seconds",
+		"Duration of the yt-dlp metadata retrieval.",
+		[]float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+		"outcome",
+	)
+	SubscriptionRuns = NewCounterVec(
+		Namespace+"subscription_runs_total",
+		"Number of subscription checks by outcome.",
+		"outcome",
+	)
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	Write(w)
+}
+
+func ApplyRouter() func(chi.Router) {
+	return func(r chi.Router) {
+		if middlewares.AuthEnabled() {
+			r.Use(middlewares.Authenticated)
+		}
+		r.Use(middlewares.RequireScope(middlewares.ScopeRead))
+		r.Get("/", Handler)
+	}
+}
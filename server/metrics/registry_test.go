@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric func() metric
+		want   string
+	}{
+		{
+			name: "counter",
+			metric: func() metric {
+				c := NewCounterVec("test_counter_total", "A counter.", "site", "class")
+				c.Inc("youtube", "network")
+				c.Add(2.5, "vimeo", "network")
+				c.Inc("youtube", "network")
+				return c
+			},
+			want: `# HELP test_counter_total A counter.
+# TYPE test_counter_total counter
+test_counter_total{site="vimeo",class="network"} 2.5
+test_counter_total{site="youtube",class="network"} 2
+`,
+		},
+		{
+			name: "counter without labels",
+			metric: func() metric {
+				c := NewCounterVec("test_plain_total", "A counter without labels.")
+				c.Inc()
+				return c
+			},
+			want: `# HELP test_plain_total A counter without labels.
+# TYPE test_plain_total counter
+test_plain_total 1
+`,
+		},
+		{
+			name: "escaping",
+			metric: func() metric {
+				c := NewCounterVec("test_escaped_total", "Backslash \\ and\nline feed.", "value")
+				c.Inc("quote \" backslash \\ line\nfeed\ttab é")
+				return c
+			},
+			want: `# HELP test_escaped_total Backslash \\ and\nline feed.
+# TYPE test_escaped_total counter
+test_escaped_total{value="quote \" backslash \\ line\nfeed` + "\t" + `tab é"} 1
+`,
+		},
+		{
+			name: "histogram",
+			metric: func() metric {
+				h := NewHistogramVec("test_seconds", "A histogram.", []float64{1, 0.5, 2}, "outcome")
+				h.Observe(0.25, "ok")
+				h.Observe(0.5, "ok")
+				h.Observe(1.5, "ok")
+				h.Observe(10, "ok")
+				h.Observe(3, "failed")
+				return h
+			},
+			want: `# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{outcome="failed",le="0.5"} 0
+test_seconds_bucket{outcome="failed",le="1"} 0
+test_seconds_bucket{outcome="failed",le="2"} 0
+test_seconds_bucket{outcome="failed",le="+Inf"} 1
+test_seconds_sum{outcome="failed"} 3
+test_seconds_count{outcome="failed"} 1
+test_seconds_bucket{outcome="ok",le="0.5"} 2
+test_seconds_bucket{outcome="ok",le="1"} 2
+test_seconds_bucket{outcome="ok",le="2"} 3
+test_seconds_bucket{outcome="ok",le="+Inf"} 4
+test_seconds_sum{outcome="ok"} 12.25
+test_seconds_count{outcome="ok"} 4
+`,
+		},
+		{
+			name: "histogram without labels",
+			metric: func() metric {
+				h := NewHistogramVec("test_plain_seconds", "A histogram without labels.", []float64{1})
+				h.Observe(0.5)
+				return h
+			},
+			want: `# HELP test_plain_seconds A histogram without labels.
+# TYPE test_plain_seconds histogram
+test_plain_seconds_bucket{le="1"} 1
+test_plain_seconds_bucket{le="+Inf"} 1
+test_plain_seconds_sum 0.5
+test_plain_seconds_count 1
+`,
+		},
+		{
+			name: "gauge",
+			metric: func() metric {
+				return NewGaugeFunc("test_gauge", "A gauge.", func() []Sample {
+					return []Sample{
+						{Labels: []string{"queued"}, Value: 3},
+						{Labels: []string{"running"}, Value: 0},
+					}
+				}, "status")
+			},
+			want: `# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge{status="queued"} 3
+test_gauge{status="running"} 0
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.metric().write(&buf)
+
+			if got := buf.String(); got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
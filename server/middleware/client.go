@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+type clientIPKey struct{}
+
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// Returns the address of the client set by ClientIP, if any
+func ClientIPFrom(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// Middleware storing the address of the client in the request context
+func ClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithClientIP(r.Context(), clientIP(r))))
+	})
+}
+
+// Host part of a remote address, the address itself if it has no port
+func RemoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// The remote address or, for the requests coming through trusted proxies,
+// the nearest address in X-Forwarded-For which isn't one of them.
+func clientIP(r *http.Request) string {
+	ip := RemoteIP(r.RemoteAddr)
+	if !trustedProxy(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !trustedProxy(hop) {
+			break
+		}
+	}
+
+	return ip
+}
+
+var (
+	trustedPrefixes []netip.Prefix
+	trustUnix       bool
+	trustedOnce     sync.Once
+)
+
+// Reports whether the address belongs to a proxy listed in trusted_proxies,
+// "unix" trusts the peers of a unix socket.
+func trustedProxy(ip string) bool {
+	trustedOnce.Do(func() {
+		for _, entry := range config.Instance().TrustedProxies {
+			if entry == "unix" {
+				trustUnix = true
+				continue
+			}
+
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				addr, aerr := netip.ParseAddr(entry)
+				if aerr != nil {
+					slog.Warn("invalid trusted proxy", slog.String("proxy", entry), slog.String("err", err.Error()))
+					continue
+				}
+				prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+			}
+			trustedPrefixes = append(trustedPrefixes, prefix.Masked())
+		}
+	})
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		// the address of a unix socket peer is empty or "@"
+		return trustUnix && (ip == "" || ip == "@")
+	}
+
+	for _, prefix := range trustedPrefixes {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
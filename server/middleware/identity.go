@@ -0,0 +1,175 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"  // everything, including the users management
+	RoleUser   Role = "user"   // downloads and manages files
+	RoleViewer Role = "viewer" // read only
+)
+
+// A permission, granted by the roles and restricted by the api tokens
+type Scope string
+
+const (
+	ScopeRead    Scope = "read"
+	ScopeEnqueue Scope = "enqueue" // queue and control downloads
+	ScopeDelete  Scope = "delete"  // kill downloads, delete files and entries
+	ScopeAdmin   Scope = "admin"
+)
+
+var roleScopes = map[Role][]Scope{
+	RoleViewer: {ScopeRead},
+	RoleUser:   {ScopeRead, ScopeEnqueue, ScopeDelete},
+	RoleAdmin:  {ScopeRead, ScopeEnqueue, ScopeDelete, ScopeAdmin},
+}
+
+func (r Role) Valid() bool {
+	_, ok := roleScopes[r]
+	return ok
+}
+
+// Reports whether the role grants the scope
+func (r Role) Grants(scope Scope) bool {
+	return slices.Contains(roleScopes[r], scope)
+}
+
+func (s Scope) Valid() bool {
+	return RoleAdmin.Grants(s)
+}
+
+// The authenticated caller of a request
+type Identity struct {
+	Username string  `json:"username"`
+	Role     Role    `json:"role"`
+	Scopes   []Scope `json:"scopes,omitempty"` // set when authenticated with an api token
+	TokenId  string  `json:"-"`                // the api token authenticating the caller
+}
+
+// Reports whether the identity is allowed to act within the scope, an api
+// token can't do more than its owner.
+func (i *Identity) Can(scope Scope) bool {
+	if !i.Role.Grants(scope) {
+		return false
+	}
+	return i.Scopes == nil || slices.Contains(i.Scopes, scope)
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// Returns the identity set by the authentication middlewares, if any
+func IdentityFrom(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
+
+// Resolves the identities of the login tokens, by their username, and of the
+// api tokens.
+type IdentityStore interface {
+	Resolve(ctx context.Context, username string) (*Identity, error)
+	ResolveToken(ctx context.Context, token string) (*Identity, error)
+}
+
+var store IdentityStore
+
+// Sets where the identities are resolved, it must be registered before
+// serving any authenticated route.
+func RegisterStore(s IdentityStore) {
+	store = s
+}
+
+func identityStore() (IdentityStore, error) {
+	if store == nil {
+		return nil, errors.New("no users store registered")
+	}
+	return store, nil
+}
+
+// Reports whether the callers must authenticate, with a local account or
+// through openid
+func AuthEnabled() bool {
+	return config.Instance().RequireAuth || config.Instance().UseOpenId
+}
+
+// Checks if the caller is allowed to act within the given scope.
+// Without authentication every caller is allowed.
+func Authorize(ctx context.Context, scope Scope) error {
+	if !AuthEnabled() {
+		return nil
+	}
+
+	identity, ok := IdentityFrom(ctx)
+	if !ok {
+		return errors.New("not authenticated")
+	}
+	if !identity.Can(scope) {
+		return errors.New("insufficient permissions")
+	}
+
+	return nil
+}
+
+// Middleware restricting a route to the callers allowed to act within the
+// given scope, it must be placed after the authentication middlewares.
+func RequireScope(scope Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := Authorize(r.Context(), scope); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Prefix of the identities of the server acting on its own, followed by the
+// task. The local accounts can't use it, so they're never mistaken for it.
+const SystemUsernamePrefix = "system:"
+
+// Identity of the server acting on its own for a task, allowed to act on
+// everyone's downloads and entries.
+func SystemIdentity(task string) *Identity {
+	return &Identity{Username: SystemUsernamePrefix + task, Role: RoleAdmin}
+}
+
+// Username recorded as the owner of what the caller creates, empty without
+// authentication.
+func Owner(ctx context.Context) string {
+	if identity, ok := IdentityFrom(ctx); ok {
+		return identity.Username
+	}
+	return ""
+}
+
+// Returns the user whose downloads, files and entries the caller is
+// restricted to. Admins and, without authentication, every caller can access
+// everyone's.
+func RestrictedTo(ctx context.Context) (string, bool) {
+	if !AuthEnabled() {
+		return "", false
+	}
+
+	identity, ok := IdentityFrom(ctx)
+	if !ok {
+		return "", true
+	}
+	if identity.Role == RoleAdmin {
+		return "", false
+	}
+
+	return identity.Username, true
+}
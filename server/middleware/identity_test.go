@@ -0,0 +1,32 @@
+package middlewares
+
+import "testing"
+
+func TestIdentityCan(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity Identity
+		scope    Scope
+		want     bool
+	}{
+		{name: "viewer reading", identity: Identity{Role: RoleViewer}, scope: ScopeRead, want: true},
+		{name: "viewer enqueuing", identity: Identity{Role: RoleViewer}, scope: ScopeEnqueue},
+		{name: "user deleting", identity: Identity{Role: RoleUser}, scope: ScopeDelete, want: true},
+		{name: "user administering", identity: Identity{Role: RoleUser}, scope: ScopeAdmin},
+		{name: "admin", identity: Identity{Role: RoleAdmin}, scope: ScopeAdmin, want: true},
+		{name: "unknown role", identity: Identity{Role: "root"}, scope: ScopeRead},
+		{name: "token within its scopes", identity: Identity{Role: RoleUser, Scopes: []Scope{ScopeRead, ScopeEnqueue}}, scope: ScopeEnqueue, want: true},
+		{name: "token outside its scopes", identity: Identity{Role: RoleAdmin, Scopes: []Scope{ScopeRead}}, scope: ScopeDelete},
+		// a token can't do more than its owner
+		{name: "token beyond its role", identity: Identity{Role: RoleViewer, Scopes: []Scope{ScopeRead, ScopeEnqueue}}, scope: ScopeEnqueue},
+		{name: "token without scopes", identity: Identity{Role: RoleAdmin, Scopes: []Scope{}}, scope: ScopeRead},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.identity.Can(tc.scope); got != tc.want {
+				t.Errorf("Can(%s) = %t, want %t", tc.scope, got, tc.want)
+			}
+		})
+	}
+}
@@ -1,16 +1,19 @@
 package middlewares
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func validateToken(tokenValue string) error {
+// Returns the username the token was issued to
+func validateToken(tokenValue string) (string, error) {
 	token, err := jwt.Parse(tokenValue, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
@@ -18,40 +21,90 @@ func validateToken(tokenValue string) error {
 		return []byte(os.Getenv("JWT_SECRET")), nil
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		expiresAt, err := time.Parse(time.RFC3339, claims["expiresAt"].(string))
 		if err != nil {
-			return err
+			return "", err
 		}
 
 		if time.Now().After(expiresAt) {
-			return errors.New("token expired")
+			return "", errors.New("token expired")
 		}
-	} else {
-		return errors.New("invalid token")
+
+		username, _ := claims["username"].(string)
+		return username, nil
 	}
 
-	return nil
+	return "", errors.New("invalid token")
 }
 
 // Authentication does NOT use http-Only cookies since there's not risk for XSS
 // By exposing the server through https it's completely safe to use httpheaders
 
+// Prefix of the api tokens, it tells them apart from the login tokens
+const APITokenPrefix = "ytdlp_"
+
+// Middleware resolving the caller from a token of a local account or from
+// the openid session cookie, see Authenticate.
 func Authenticated(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get("X-Authentication")
-		if token == "" {
-			token = r.URL.Query().Get("token")
+		token := requestToken(r)
+
+		var session string
+		if cookie, err := r.Cookie(SessionCookie); err == nil {
+			session = cookie.Value
 		}
 
-		if err := validateToken(token); err != nil {
+		identity, err := Authenticate(r.Context(), token, session)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
 	})
 }
+
+// Token of a local account sent with a request, if any
+func requestToken(r *http.Request) string {
+	token := BearerToken(r.Header.Get("Authorization"))
+	if token == "" {
+		token = r.Header.Get("X-Authentication")
+	}
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return token
+}
+
+// Returns the token of an Authorization header using the Bearer scheme
+func BearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Validates either an api token or a token obtained through the login and
+// returns the identity of its user, for the transports which can't use the
+// Authenticated middleware.
+func ValidateToken(ctx context.Context, token string) (*Identity, error) {
+	store, err := identityStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(token, APITokenPrefix) {
+		return store.ResolveToken(ctx, token)
+	}
+
+	username, err := validateToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return store.Resolve(ctx, username)
+}
@@ -0,0 +1,99 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+// Buckets unused for this long are dropped
+const bucketIdle = time.Minute * 10
+
+// Token bucket refilled at the configured rate
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+var limiter = &rateLimiter{buckets: make(map[string]*bucket)}
+
+// Middleware limiting the requests of each api token or user, or of each
+// address without authentication, as set in rate_limit. The callers over the
+// limit get 429 with Retry-After.
+// It must be placed after ClientIP and Authenticated.
+func RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conf := config.Instance().RateLimit
+		if conf.Requests <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := "ip:" + ClientIPFrom(r.Context())
+		if identity, ok := IdentityFrom(r.Context()); ok {
+			key = "user:" + identity.Username
+			if identity.TokenId != "" {
+				key = "token:" + identity.TokenId
+			}
+		}
+
+		if wait := limiter.take(key, conf); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Takes a token from the bucket of the key, returning how long to wait for
+// one if it's empty.
+func (l *rateLimiter) take(key string, conf config.RateLimit) time.Duration {
+	burst := float64(max(conf.Burst, 1))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*conf.Requests)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / conf.Requests * float64(time.Second))
+	}
+
+	b.tokens--
+	return 0
+}
+
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < bucketIdle {
+		return
+	}
+	l.swept = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > bucketIdle {
+			delete(l.buckets, key)
+		}
+	}
+}
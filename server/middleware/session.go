@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+// Cookie holding the token of an openid session
+const SessionCookie = "oid-session"
+
+// Prefix of the usernames of the openid users, followed by their subject.
+// The local accounts can't use it, so the two never own the same downloads.
+const OpenIdUsernamePrefix = "oidc:"
+
+// Resolves the identities of the openid sessions, by their token.
+type SessionStore interface {
+	ResolveSession(ctx context.Context, token string) (*Identity, error)
+}
+
+var sessions SessionStore
+
+// Sets where the openid sessions are resolved, it must be registered before
+// serving any authenticated route when openid is enabled.
+func RegisterSessions(s SessionStore) {
+	sessions = s
+}
+
+// Resolves the caller from either a token of a local account or an openid
+// session, as long as the respective authentication is enabled. When both are
+// sent the token takes precedence.
+func Authenticate(ctx context.Context, token, session string) (*Identity, error) {
+	var (
+		local  = config.Instance().RequireAuth
+		openid = config.Instance().UseOpenId
+	)
+
+	if local && (token != "" || !openid || session == "") {
+		return ValidateToken(ctx, token)
+	}
+
+	if !openid {
+		return nil, errors.New("authentication is disabled")
+	}
+	if session == "" {
+		return nil, errors.New("missing openid session")
+	}
+	if sessions == nil {
+		return nil, errors.New("no openid sessions store registered")
+	}
+
+	return sessions.ResolveSession(ctx, session)
+}
@@ -0,0 +1,186 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+// Largest login request body read by the throttle
+const maxLoginBody = 1 << 16
+
+// Failed logins of an address or a username
+type attempts struct {
+	failures    []time.Time // within the window
+	lockouts    int         // since the failures started
+	lockedUntil time.Time
+	last        time.Time
+}
+
+type loginThrottle struct {
+	mu        sync.Mutex
+	ips       map[string]*attempts
+	usernames map[string]*attempts
+	swept     time.Time
+}
+
+var throttle = &loginThrottle{
+	ips:       make(map[string]*attempts),
+	usernames: make(map[string]*attempts),
+}
+
+// Middleware throttling the login attempts, per client address and per
+// username as set in login_throttle. Once the failures within the window
+// reach the limit the caller is locked out, for twice as long at each
+// further lockout, and gets 429 with Retry-After.
+// It must be placed after ClientIP.
+func LoginThrottle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxLoginBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var req struct {
+			Username string `json:"username"`
+		}
+		json.Unmarshal(body, &req)
+
+		var (
+			ip       = ClientIPFrom(r.Context())
+			username = strings.ToLower(strings.TrimSpace(req.Username))
+		)
+
+		if wait := throttle.locked(ip, username); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many failed login attempts, retry later", http.StatusTooManyRequests)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		// only the rejected credentials are failures, not the malformed requests
+		switch {
+		case sw.status == http.StatusUnauthorized || sw.status == http.StatusForbidden:
+			throttle.fail(ip, username)
+		case sw.status < 300:
+			throttle.succeed(username)
+		}
+	})
+}
+
+// How long the address or the username are still locked out
+func (t *loginThrottle) locked(ip, username string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	wait := time.Duration(0)
+
+	if a, ok := t.ips[ip]; ok {
+		wait = max(wait, a.lockedUntil.Sub(now))
+	}
+	if a, ok := t.usernames[username]; ok && username != "" {
+		wait = max(wait, a.lockedUntil.Sub(now))
+	}
+
+	return wait
+}
+
+func (t *loginThrottle) fail(ip, username string) {
+	conf := config.Instance().LoginThrottle
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+
+	t.sweep(now, conf)
+
+	t.record(t.ips, ip, conf.MaxPerIP, now, conf)
+	if username != "" {
+		t.record(t.usernames, username, conf.MaxPerUsername, now, conf)
+	}
+}
+
+// A successful login clears the failures of the account, not the ones of the
+// address which might be trying many accounts.
+func (t *loginThrottle) succeed(username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.usernames, username)
+}
+
+func (t *loginThrottle) record(entries map[string]*attempts, key string, limit int, now time.Time, conf config.LoginThrottle) {
+	if limit <= 0 {
+		return
+	}
+
+	a, ok := entries[key]
+	if !ok {
+		a = &attempts{}
+		entries[key] = a
+	}
+
+	a.last = now
+	a.failures = slices.DeleteFunc(a.failures, func(f time.Time) bool {
+		return now.Sub(f) > conf.Window
+	})
+	a.failures = append(a.failures, now)
+
+	if len(a.failures) < limit {
+		return
+	}
+
+	lockout := conf.Lockout << min(a.lockouts, 30)
+	if conf.MaxLockout > 0 && (lockout > conf.MaxLockout || lockout <= 0) {
+		lockout = conf.MaxLockout
+	}
+
+	a.lockedUntil = now.Add(lockout)
+	a.lockouts++
+	a.failures = nil
+}
+
+// Forgets the callers with no recent failures, once per window
+func (t *loginThrottle) sweep(now time.Time, conf config.LoginThrottle) {
+	if now.Sub(t.swept) < conf.Window {
+		return
+	}
+	t.swept = now
+
+	// the lockouts keep doubling as long as the failures go on
+	idle := max(conf.Window, conf.MaxLockout) * 2
+
+	for _, entries := range []map[string]*attempts{t.ips, t.usernames} {
+		for key, a := range entries {
+			if now.Sub(a.last) > idle && now.After(a.lockedUntil) {
+				delete(entries, key)
+			}
+		}
+	}
+}
+
+// Records the status code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
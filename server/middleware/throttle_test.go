@@ -0,0 +1,173 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+)
+
+// Answers 200 to the right password, 401 to a wrong one and 400 without one
+var login = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	switch req.Password {
+	case "":
+		w.WriteHeader(http.StatusBadRequest)
+	case "right":
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+})
+
+type loginStep struct {
+	ip, username, password string
+	want                   int
+	retryAfter             string
+}
+
+func TestLoginThrottle(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxPerIP       int
+		maxPerUsername int
+		steps          []loginStep
+	}{
+		{
+			name:           "per address",
+			maxPerIP:       3,
+			maxPerUsername: 10,
+			steps: []loginStep{
+				{"10.0.0.1", "a", "wrong", http.StatusUnauthorized, ""},
+				{"10.0.0.1", "b", "wrong", http.StatusUnauthorized, ""},
+				{"10.0.0.1", "c", "wrong", http.StatusUnauthorized, ""},
+				{"10.0.0.1", "d", "right", http.StatusTooManyRequests, "60"},
+				{"10.0.0.2", "a", "right", http.StatusOK, ""},
+			},
+		},
+		{
+			name:           "per username",
+			maxPerIP:       10,
+			maxPerUsername: 2,
+			steps: []loginStep{
+				{"10.0.0.1", "alice", "wrong", http.StatusUnauthorized, ""},
+				{"10.0.0.2", " Alice", "wrong", http.StatusUnauthorized, ""},
+				{"10.0.0.3", "alice", "right", http.StatusTooManyRequests, "60"},
+				{"10.0.0.3", "bob", "right", http.StatusOK, ""},
+			},
+		},
+		{
+			name:           "username reset after a successful login",
+			maxPerIP:       10,
+			maxPerUsername: 2,
+			steps: []loginStep{
+				{"10.0.0.1", "alice", "wrong", http.StatusUnauthorized, ""},
+				{"10.0.0.1", "alice", "right", http.StatusOK, ""},
+				{"10.0.0.1", "alice", "wrong", http.StatusUnauthorized, ""},
+				{"10.0.0.1", "alice", "right", http.StatusOK, ""},
+			},
+		},
+		{
+			name:           "address not reset after a successful login",
+			maxPerIP:       2,
+			maxPerUsername: 10,
+			steps: []loginStep{
+				{"10.0.0.1", "a", "wrong", http.StatusUnauthorized, ""},
+				{"10.0.0.1", "b", "right", http.StatusOK, ""},
+				{"10.0.0.1", "c", "wrong", http.StatusUnauthorized, ""},
+				{"10.0.0.1", "b", "right", http.StatusTooManyRequests, "60"},
+			},
+		},
+		{
+			name:           "malformed requests aren't failures",
+			maxPerIP:       1,
+			maxPerUsername: 1,
+			steps: []loginStep{
+				{"10.0.0.1", "a", "", http.StatusBadRequest, ""},
+				{"10.0.0.1", "a", "", http.StatusBadRequest, ""},
+				{"10.0.0.1", "a", "right", http.StatusOK, ""},
+			},
+		},
+	}
+
+	conf := config.Instance()
+	defer func(previous config.LoginThrottle) { conf.LoginThrottle = previous }(conf.LoginThrottle)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conf.LoginThrottle = config.LoginThrottle{
+				Window:         time.Minute * 15,
+				MaxPerIP:       tc.maxPerIP,
+				MaxPerUsername: tc.maxPerUsername,
+				Lockout:        time.Minute,
+				MaxLockout:     time.Hour,
+			}
+			throttle = &loginThrottle{
+				ips:       make(map[string]*attempts),
+				usernames: make(map[string]*attempts),
+			}
+
+			handler := LoginThrottle(login)
+
+			for i, step := range tc.steps {
+				body, _ := json.Marshal(map[string]string{"username": step.username, "password": step.password})
+
+				r := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(string(body)))
+				r = r.WithContext(WithClientIP(r.Context(), step.ip))
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, r)
+
+				if w.Code != step.want {
+					t.Errorf("step %d: status = %d, want %d", i, w.Code, step.want)
+				}
+				if got := w.Header().Get("Retry-After"); got != step.retryAfter {
+					t.Errorf("step %d: Retry-After = %q, want %q", i, got, step.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginThrottleLockouts(t *testing.T) {
+	conf := config.LoginThrottle{
+		Window:     time.Minute * 15,
+		Lockout:    time.Minute,
+		MaxLockout: time.Minute * 3,
+	}
+
+	var (
+		tr      = &loginThrottle{ips: make(map[string]*attempts)}
+		now     = time.Now()
+		lockout = func() time.Duration {
+			return tr.ips["10.0.0.1"].lockedUntil.Sub(now)
+		}
+	)
+
+	// each lockout lasts twice as long as the previous one, up to max_lockout
+	for _, want := range []time.Duration{time.Minute, time.Minute * 2, time.Minute * 3, time.Minute * 3} {
+		now = now.Add(time.Hour)
+		tr.record(tr.ips, "10.0.0.1", 2, now, conf)
+		tr.record(tr.ips, "10.0.0.1", 2, now, conf)
+
+		if got := lockout(); got != want {
+			t.Errorf("lockout = %s, want %s", got, want)
+		}
+	}
+
+	// the failures older than the window aren't counted
+	tr.record(tr.ips, "10.0.0.2", 2, now, conf)
+	tr.record(tr.ips, "10.0.0.2", 2, now.Add(conf.Window+time.Second), conf)
+
+	if until := tr.ips["10.0.0.2"].lockedUntil; !until.IsZero() {
+		t.Errorf("locked out until %s by failures outside the window", until)
+	}
+}
@@ -18,6 +18,10 @@ func Configure() {
 		return
 	}
 
+	if err := validateRoles(); err != nil {
+		panic(err)
+	}
+
 	provider, err := oidc.NewProvider(context.Background(), config.Instance().OpenIdProviderURL)
 	if err != nil {
 		panic(err)
@@ -28,7 +32,10 @@ func Configure() {
 		ClientSecret: config.Instance().OpenIdClientSecret,
 		RedirectURL:  config.Instance().OpenIdRedirectURL,
 		Endpoint:     provider.Endpoint(),
-		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		Scopes: append(
+			[]string{oidc.ScopeOpenID, "profile", "email"},
+			config.Instance().OpenIdScopes...,
+		),
 	}
 
 	verifier = provider.Verifier(&oidc.Config{
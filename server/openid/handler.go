@@ -6,20 +6,16 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"slices"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
 	"golang.org/x/oauth2"
 )
 
-type OAuth2SuccessResponse struct {
-	OAuth2Token   *oauth2.Token
-	IDTokenClaims *json.RawMessage
-}
-
 // var cookieMaxAge = int(time.Hour * 24 * 30) XXX: overflows on 32 bit architectures.
 
 func Login(w http.ResponseWriter, r *http.Request) {
@@ -50,146 +46,133 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		Expires: time.Now().Add(time.Hour * 24 * 30), // XXX: change to MaxAge
 	})
 
-	http.Redirect(w, r, oauth2Config.AuthCodeURL(state, oidc.Nonce(nonce)), http.StatusFound)
+	// offline access lets the providers which need it issue a refresh token
+	http.Redirect(w, r, oauth2Config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.AccessTypeOffline), http.StatusFound)
 }
 
-func doAuthentification(r *http.Request, setCookieCallback func(t *oauth2.Token)) (*OAuth2SuccessResponse, error) {
+func doAuthentification(r *http.Request) (*oidc.IDToken, *oauth2.Token, error) {
 	state, err := r.Cookie("state")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if r.URL.Query().Get("state") != state.Value {
-		return nil, errors.New("auth state does not match")
+		return nil, nil, errors.New("auth state does not match")
 	}
 
 	oauth2Token, err := oauth2Config.Exchange(r.Context(), r.URL.Query().Get("code"))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	rawToken, ok := oauth2Token.Extra("id_token").(string)
 	if !ok {
-		return nil, errors.New("openid field \"id_token\" not found in oauth2 token")
+		return nil, nil, errors.New("openid field \"id_token\" not found in oauth2 token")
 	}
 
 	idToken, err := verifier.Verify(r.Context(), rawToken)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var claims struct {
-		Email    string `json:"email"`
-		Verified bool   `json:"email_verified"`
+	nonce, err := r.Cookie("nonce")
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err := idToken.Claims(&claims); err != nil {
-		return nil, err
+	if idToken.Nonce != nonce.Value {
+		return nil, nil, errors.New("auth nonce does not match")
 	}
 
-	whitelist := config.Instance().OpenIdEmailWhitelist
+	return idToken, oauth2Token, nil
+}
 
-	if len(whitelist) > 0 && !slices.Contains(whitelist, claims.Email) {
-		return nil, errors.New("email address not found in ACL")
+// Completes the login starting a server-side session, the role is mapped from
+// the claims of the id token.
+func SingIn(w http.ResponseWriter, r *http.Request) {
+	idToken, oauth2Token, err := doAuthentification(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	nonce, err := r.Cookie("nonce")
+	identity, err := identityOf(idToken)
 	if err != nil {
-		return nil, err
+		audit.Record(r.Context(), audit.ActionLoginFailed, idToken.Subject)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
 	}
 
-	if idToken.Nonce != nonce.Value {
-		return nil, errors.New("auth nonce does not match")
+	token, err := store.create(r.Context(), identity, idToken.Expiry, oauth2Token.RefreshToken)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	setCookieCallback(oauth2Token)
+	audit.Record(middlewares.WithIdentity(r.Context(), identity), audit.ActionLogin, identity.Username)
 
-	// redact
-	oauth2Token.AccessToken = "*REDACTED*"
-
-	res := OAuth2SuccessResponse{
-		oauth2Token,
-		&json.RawMessage{},
-	}
-
-	if err := idToken.Claims(&res.IDTokenClaims); err != nil {
-		return nil, err
-	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     middlewares.SessionCookie,
+		Value:    token,
+		HttpOnly: true,
+		Path:     "/",
+		Secure:   r.TLS != nil,
+		Expires:  time.Now().Add(sessionLifetime),
+	})
 
-	return &res, nil
+	w.Write([]byte("Login succesfully, you may now close this window and refresh yt-dlp-webui."))
 }
 
-func SingIn(w http.ResponseWriter, r *http.Request) {
-	_, err := doAuthentification(r, func(t *oauth2.Token) {
-		idToken, _ := t.Extra("id_token").(string)
+// Ends the session of the caller, on the server too
+func Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(middlewares.SessionCookie); err == nil {
+		if session, err := store.get(r.Context(), cookie.Value); err == nil {
+			store.revoke(r.Context(), session.Id)
+		}
+	}
 
+	for _, name := range []string{middlewares.SessionCookie, "state", "nonce"} {
 		http.SetCookie(w, &http.Cookie{
-			Name:     "oid-token",
-			Value:    idToken,
+			Name:     name,
 			HttpOnly: true,
 			Path:     "/",
 			Secure:   r.TLS != nil,
-			// MaxAge:   int(time.Hour * 24 * 30), XXX: overflows on 32 bit architectures.
+			MaxAge:   -1,
 		})
-	})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
 	}
-
-	w.Write([]byte("Login succesfully, you may now close this window and refresh yt-dlp-webui."))
 }
 
-func Refresh(w http.ResponseWriter, r *http.Request) {
-	refreshToken := r.URL.Query().Get("refresh-token")
+// Lists the active sessions, for the admins
+func ListSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-	ts := oauth2Config.TokenSource(r.Context(), &oauth2.Token{RefreshToken: refreshToken})
-
-	token, err := ts.Token()
+	sessions, err := store.list(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "oid-token",
-		Value:    token.AccessToken,
-		HttpOnly: true,
-		Path:     "/",
-		Secure:   r.TLS != nil,
-		// MaxAge:   int(time.Hour * 24 * 30), XXX: overflows on 32 bit architectures.
-	})
-
-	token.AccessToken = "*redacted*"
-
-	if err := json.NewEncoder(w).Encode(token); err != nil {
+	if err := json.NewEncoder(w).Encode(sessions); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
-func Logout(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "oid-token",
-		HttpOnly: true,
-		Path:     "/",
-		Secure:   r.TLS != nil,
-		MaxAge:   -1,
-	})
+// Revokes a session, its user has to sign in again
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "state",
-		HttpOnly: true,
-		Path:     "/",
-		Secure:   r.TLS != nil,
-		MaxAge:   -1,
-	})
+	id := chi.URLParam(r, "id")
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "nonce",
-		HttpOnly: true,
-		Path:     "/",
-		Secure:   r.TLS != nil,
-		MaxAge:   -1,
-	})
+	if err := store.revoke(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	audit.Record(r.Context(), audit.ActionSessionRevoke, id)
+
+	if err := json.NewEncoder(w).Encode("ok"); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 }
@@ -0,0 +1,132 @@
+package openid
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+)
+
+// Roles from the least to the most privileged
+var roleRanks = []middlewares.Role{
+	middlewares.RoleViewer,
+	middlewares.RoleUser,
+	middlewares.RoleAdmin,
+}
+
+// Returns the identity of the user an id token was issued to, if the email
+// whitelist and the role mappings let them in. The user is identified by the
+// subject of the token, which unlike the email can't be changed to take over
+// the downloads of another user.
+func identityOf(idToken *oidc.IDToken) (*middlewares.Identity, error) {
+	var claims map[string]any
+
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	email, _ := claims["email"].(string)
+	if email == "" {
+		return nil, errors.New("openid field \"email\" not found in id token")
+	}
+
+	// some providers send it as a string
+	if verified := claims["email_verified"]; verified != true && verified != "true" {
+		return nil, errors.New("email address not verified")
+	}
+
+	if idToken.Subject == "" {
+		return nil, errors.New("openid field \"sub\" not found in id token")
+	}
+
+	whitelist := config.Instance().OpenIdEmailWhitelist
+
+	if len(whitelist) > 0 && !slices.Contains(whitelist, email) {
+		return nil, errors.New("email address not found in ACL")
+	}
+
+	role, err := roleOf(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &middlewares.Identity{Username: middlewares.OpenIdUsernamePrefix + idToken.Subject, Role: role}, nil
+}
+
+// The most privileged role among the matching mappings, otherwise the default
+// one. Without any, every openid user is an admin as before the mappings.
+func roleOf(claims map[string]any) (middlewares.Role, error) {
+	var (
+		mappings    = config.Instance().OpenIdRoles
+		defaultRole = middlewares.Role(config.Instance().OpenIdDefaultRole)
+	)
+
+	if len(mappings) == 0 && defaultRole == "" {
+		return middlewares.RoleAdmin, nil
+	}
+
+	role := defaultRole
+
+	for _, m := range mappings {
+		granted := middlewares.Role(m.Role)
+		if contains(claim(claims, m.Claim), m.Value) &&
+			slices.Index(roleRanks, granted) > slices.Index(roleRanks, role) {
+			role = granted
+		}
+	}
+
+	if role == "" {
+		return "", errors.New("no role granted to this account")
+	}
+
+	return role, nil
+}
+
+// Value of a claim, the ones of nested objects are given as dotted paths
+func claim(claims map[string]any, path string) any {
+	var value any = claims
+
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+
+	return value
+}
+
+// Reports whether a claim is, or is a list containing, the value
+func contains(claim any, value string) bool {
+	switch c := claim.(type) {
+	case nil:
+		return false
+	case []any:
+		return slices.ContainsFunc(c, func(e any) bool { return contains(e, value) })
+	default:
+		return fmt.Sprint(c) == value
+	}
+}
+
+// Checks the role mappings of the config file
+func validateRoles() error {
+	for _, m := range config.Instance().OpenIdRoles {
+		if m.Claim == "" || m.Value == "" {
+			return errors.New("openid_roles: claim and value are required")
+		}
+		if !middlewares.Role(m.Role).Valid() {
+			return fmt.Errorf("openid_roles: invalid role %q", m.Role)
+		}
+	}
+
+	if r := config.Instance().OpenIdDefaultRole; r != "" && !middlewares.Role(r).Valid() {
+		return fmt.Errorf("openid_default_role: invalid role %q", r)
+	}
+
+	return nil
+}
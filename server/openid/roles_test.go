@@ -0,0 +1,262 @@
+package openid
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+)
+
+// Sets the role mappings and the whitelist for the duration of a test
+func withConfig(t *testing.T, roles []config.OpenIdRole, defaultRole string, whitelist []string) {
+	t.Helper()
+
+	conf := config.Instance()
+
+	previous := struct {
+		roles       []config.OpenIdRole
+		defaultRole string
+		whitelist   []string
+	}{conf.OpenIdRoles, conf.OpenIdDefaultRole, conf.OpenIdEmailWhitelist}
+
+	t.Cleanup(func() {
+		conf.OpenIdRoles = previous.roles
+		conf.OpenIdDefaultRole = previous.defaultRole
+		conf.OpenIdEmailWhitelist = previous.whitelist
+	})
+
+	conf.OpenIdRoles, conf.OpenIdDefaultRole, conf.OpenIdEmailWhitelist = roles, defaultRole, whitelist
+}
+
+func TestRoleOf(t *testing.T) {
+	mappings := []config.OpenIdRole{
+		{Claim: "groups", Value: "media", Role: "user"},
+		{Claim: "groups", Value: "ops", Role: "admin"},
+		{Claim: "realm_access.roles", Value: "viewer", Role: "viewer"},
+		{Claim: "department", Value: "42", Role: "user"},
+	}
+
+	tests := []struct {
+		name        string
+		mappings    []config.OpenIdRole
+		defaultRole string
+		claims      map[string]any
+		want        middlewares.Role
+		wantErr     bool
+	}{
+		{
+			// as before the mappings existed
+			name:   "without mappings",
+			claims: map[string]any{},
+			want:   middlewares.RoleAdmin,
+		},
+		{
+			name:        "only a default role",
+			defaultRole: "viewer",
+			claims:      map[string]any{"groups": []any{"ops"}},
+			want:        middlewares.RoleViewer,
+		},
+		{
+			name:     "group in a list",
+			mappings: mappings,
+			claims:   map[string]any{"groups": []any{"family", "media"}},
+			want:     middlewares.RoleUser,
+		},
+		{
+			name:     "most privileged of the matches",
+			mappings: mappings,
+			claims:   map[string]any{"groups": []any{"media", "ops"}, "realm_access": map[string]any{"roles": []any{"viewer"}}},
+			want:     middlewares.RoleAdmin,
+		},
+		{
+			name:     "nested claim",
+			mappings: mappings,
+			claims:   map[string]any{"realm_access": map[string]any{"roles": []any{"viewer"}}},
+			want:     middlewares.RoleViewer,
+		},
+		{
+			name:     "single value",
+			mappings: mappings,
+			claims:   map[string]any{"groups": "ops"},
+			want:     middlewares.RoleAdmin,
+		},
+		{
+			// json numbers are decoded as float64
+			name:     "number",
+			mappings: mappings,
+			claims:   map[string]any{"department": float64(42)},
+			want:     middlewares.RoleUser,
+		},
+		{
+			name:        "default role below the mapped one",
+			mappings:    mappings,
+			defaultRole: "viewer",
+			claims:      map[string]any{"groups": []any{"media"}},
+			want:        middlewares.RoleUser,
+		},
+		{
+			name:        "mapped role below the default one",
+			mappings:    mappings,
+			defaultRole: "user",
+			claims:      map[string]any{"realm_access": map[string]any{"roles": []any{"viewer"}}},
+			want:        middlewares.RoleUser,
+		},
+		{
+			name:     "no match nor default role",
+			mappings: mappings,
+			claims:   map[string]any{"groups": []any{"family"}, "realm_access": "viewer"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			withConfig(t, tc.mappings, tc.defaultRole, nil)
+
+			got, err := roleOf(tc.claims)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("roleOf() error = %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("roleOf() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateRoles(t *testing.T) {
+	tests := []struct {
+		name        string
+		mappings    []config.OpenIdRole
+		defaultRole string
+		wantErr     bool
+	}{
+		{name: "none"},
+		{name: "valid", mappings: []config.OpenIdRole{{Claim: "groups", Value: "ops", Role: "admin"}}, defaultRole: "viewer"},
+		{name: "missing claim", mappings: []config.OpenIdRole{{Value: "ops", Role: "admin"}}, wantErr: true},
+		{name: "missing value", mappings: []config.OpenIdRole{{Claim: "groups", Role: "admin"}}, wantErr: true},
+		{name: "invalid role", mappings: []config.OpenIdRole{{Claim: "groups", Value: "ops", Role: "root"}}, wantErr: true},
+		{name: "invalid default role", defaultRole: "guest", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			withConfig(t, tc.mappings, tc.defaultRole, nil)
+
+			if err := validateRoles(); (err != nil) != tc.wantErr {
+				t.Errorf("validateRoles() error = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+const testIssuer = "https://id.example.com"
+
+// Signs the claims and verifies them as the provider's id token
+func idToken(t *testing.T, claims jwt.MapClaims) *oidc.IDToken {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims["iss"] = testIssuer
+	claims["aud"] = "client"
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+
+	raw, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := oidc.NewVerifier(
+		testIssuer,
+		&oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{&key.PublicKey}},
+		&oidc.Config{ClientID: "client"},
+	)
+
+	token, err := verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestIdentityOf(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    jwt.MapClaims
+		whitelist []string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:   "verified",
+			claims: jwt.MapClaims{"sub": "1234", "email": "alice@example.com", "email_verified": true},
+			want:   middlewares.OpenIdUsernamePrefix + "1234",
+		},
+		{
+			name:   "verified as a string",
+			claims: jwt.MapClaims{"sub": "1234", "email": "alice@example.com", "email_verified": "true"},
+			want:   middlewares.OpenIdUsernamePrefix + "1234",
+		},
+		{
+			name:    "not verified",
+			claims:  jwt.MapClaims{"sub": "1234", "email": "alice@example.com", "email_verified": false},
+			wantErr: true,
+		},
+		{
+			name:    "without email_verified",
+			claims:  jwt.MapClaims{"sub": "1234", "email": "alice@example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "without email",
+			claims:  jwt.MapClaims{"sub": "1234", "email_verified": true},
+			wantErr: true,
+		},
+		{
+			name:    "without subject",
+			claims:  jwt.MapClaims{"email": "alice@example.com", "email_verified": true},
+			wantErr: true,
+		},
+		{
+			name:      "whitelisted",
+			claims:    jwt.MapClaims{"sub": "1234", "email": "alice@example.com", "email_verified": true},
+			whitelist: []string{"alice@example.com"},
+			want:      middlewares.OpenIdUsernamePrefix + "1234",
+		},
+		{
+			name:      "not whitelisted",
+			claims:    jwt.MapClaims{"sub": "1234", "email": "mallory@example.com", "email_verified": true},
+			whitelist: []string{"alice@example.com"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			withConfig(t, nil, "", tc.whitelist)
+
+			identity, err := identityOf(idToken(t, tc.claims))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("identityOf() error = %v, want error %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if identity.Username != tc.want || identity.Role != middlewares.RoleAdmin {
+				t.Errorf("identity = %+v, want admin %s", identity, tc.want)
+			}
+		})
+	}
+}
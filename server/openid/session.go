@@ -0,0 +1,322 @@
+package openid
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// id tokens are renewed this long before they expire
+	refreshMargin  = time.Minute
+	refreshTimeout = time.Second * 10
+	// sessions are dropped after this long even if they can be refreshed
+	sessionLifetime = time.Hour * 24 * 30
+)
+
+var errSessionNotFound = errors.New("openid session not found or revoked")
+
+// A signed in openid user
+type Session struct {
+	Id        string           `json:"id"`
+	Username  string           `json:"username"`
+	Role      middlewares.Role `json:"role"`
+	ExpiresAt time.Time        `json:"expires_at"` // of the current id token
+	CreatedAt time.Time        `json:"created_at"`
+
+	refreshToken string
+}
+
+// The sessions are kept on the server: the cookie only holds a random token,
+// so they can be revoked, while the refresh token is used to renew the id
+// token before it expires.
+type sessionStore struct {
+	db *sql.DB
+	mu sync.Mutex // refresh tokens may be single use, the refreshes are serialized
+}
+
+var store *sessionStore
+
+// Sets where the sessions are stored and lets the authentication middleware
+// resolve them.
+func Register(db *sql.DB) {
+	store = &sessionStore{db: db}
+	middlewares.RegisterSessions(store)
+}
+
+// Creates a session for the identity, returning its token
+func (s *sessionStore) create(ctx context.Context, identity *middlewares.Identity, expiresAt time.Time, refreshToken string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	defer conn.Close()
+
+	now := time.Now().UTC()
+
+	// the expired sessions are cleaned up along the way
+	if _, err := conn.ExecContext(
+		ctx,
+		"DELETE FROM openid_sessions WHERE created_at < ?",
+		now.Add(-sessionLifetime),
+	); err != nil {
+		return "", err
+	}
+
+	_, err = conn.ExecContext(
+		ctx,
+		`INSERT INTO openid_sessions (id, token_hash, username, role, refresh_token, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		uuid.NewString(),
+		hashToken(token),
+		identity.Username,
+		identity.Role,
+		refreshToken,
+		expiresAt.UTC(),
+		now,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *sessionStore) get(ctx context.Context, token string) (*Session, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	var session Session
+
+	err = conn.QueryRowContext(
+		ctx,
+		"SELECT id, username, role, refresh_token, expires_at, created_at FROM openid_sessions WHERE token_hash = ?",
+		hashToken(token),
+	).Scan(
+		&session.Id,
+		&session.Username,
+		&session.Role,
+		&session.refreshToken,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// ResolveSession implements middlewares.SessionStore.
+func (s *sessionStore) ResolveSession(ctx context.Context, token string) (*middlewares.Identity, error) {
+	session, err := s.get(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(session.CreatedAt) > sessionLifetime {
+		s.revoke(ctx, session.Id)
+		return nil, errors.New("openid session expired")
+	}
+
+	if time.Until(session.ExpiresAt) < refreshMargin {
+		if session, err = s.refresh(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+
+	return &middlewares.Identity{Username: session.Username, Role: session.Role}, nil
+}
+
+// Renews the id token of a session with its refresh token, the role is
+// mapped again from the new claims. A session which can't be renewed is kept
+// until its id token expires.
+func (s *sessionStore) refresh(ctx context.Context, token string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the provider may rotate the refresh token, an interrupted request must
+	// not lose it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+	defer cancel()
+
+	// another request may have renewed it meanwhile
+	session, err := s.get(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if time.Until(session.ExpiresAt) >= refreshMargin {
+		return session, nil
+	}
+
+	renewed, err := s.renew(ctx, session)
+	if err == nil {
+		return renewed, nil
+	}
+
+	if time.Now().Before(session.ExpiresAt) {
+		if session.refreshToken == "" {
+			return session, nil
+		}
+		slog.Warn(
+			"failed to refresh openid session",
+			slog.String("username", session.Username),
+			slog.String("err", err.Error()),
+		)
+		return session, nil
+	}
+
+	s.revoke(ctx, session.Id)
+	return nil, errors.New("openid session expired: " + err.Error())
+}
+
+func (s *sessionStore) renew(ctx context.Context, session *Session) (*Session, error) {
+	if session.refreshToken == "" {
+		return nil, errors.New("no refresh token")
+	}
+
+	t, err := oauth2Config.TokenSource(ctx, &oauth2.Token{RefreshToken: session.refreshToken}).Token()
+	if err != nil {
+		return nil, err
+	}
+
+	rawToken, ok := t.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("openid field \"id_token\" not found in oauth2 token")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// the account might have been removed from the whitelist or its groups
+	identity, err := identityOf(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.RefreshToken != "" {
+		session.refreshToken = t.RefreshToken
+	}
+	session.Username = identity.Username
+	session.Role = identity.Role
+	session.ExpiresAt = idToken.Expiry
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(
+		ctx,
+		"UPDATE openid_sessions SET username = ?, role = ?, refresh_token = ?, expires_at = ? WHERE id = ?",
+		session.Username,
+		session.Role,
+		session.refreshToken,
+		session.ExpiresAt.UTC(),
+		session.Id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *sessionStore) list(ctx context.Context) (*[]Session, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(
+		ctx,
+		"SELECT id, username, role, expires_at, created_at FROM openid_sessions ORDER BY created_at DESC",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []Session{}
+
+	for rows.Next() {
+		var session Session
+
+		if err := rows.Scan(
+			&session.Id,
+			&session.Username,
+			&session.Role,
+			&session.ExpiresAt,
+			&session.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return &sessions, rows.Err()
+}
+
+func (s *sessionStore) revoke(ctx context.Context, id string) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	res, err := conn.ExecContext(ctx, "DELETE FROM openid_sessions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errSessionNotFound
+	}
+
+	return nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// The tokens are random, a plain hash is enough to not store them in clear
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,6 +2,10 @@ package rest
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
 
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
 )
@@ -11,3 +15,32 @@ type ContainerArgs struct {
 	MDB *internal.MemoryDB
 	MQ  *internal.MessageQueue
 }
+
+// Writes a classified yt-dlp error as a JSON object with its code, so clients
+// can tell apart why yt-dlp failed. Any other error is sent as plain text
+// with the given status.
+func writeError(w http.ResponseWriter, err error, status int) {
+	var ytdlpErr *internal.YtDlpError
+	if !errors.As(err, &ytdlpErr) {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(ytdlpErr)
+}
+
+// Values of a query parameter given either repeated or as a comma separated list.
+func splitQuery(values []string) []string {
+	var split []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				split = append(split, s)
+			}
+		}
+	}
+	return split
+}
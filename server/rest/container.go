@@ -2,9 +2,7 @@ package rest
 
 import (
 	"github.com/go-chi/chi/v5"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/openid"
 )
 
 func Container(args *ContainerArgs) *Handler {
@@ -19,23 +17,37 @@ func ApplyRouter(args *ContainerArgs) func(chi.Router) {
 	h := Container(args)
 
 	return func(r chi.Router) {
-		if config.Instance().RequireAuth {
+		if middlewares.AuthEnabled() {
 			r.Use(middlewares.Authenticated)
 		}
-		if config.Instance().UseOpenId {
-			r.Use(openid.Middleware)
-		}
-		r.Post("/exec", h.Exec())
-		r.Post("/execPlaylist", h.ExecPlaylist())
-		r.Post("/execLivestream", h.ExecLivestream())
-		r.Get("/running", h.Running())
-		r.Get("/version", h.GetVersion())
-		r.Get("/cookies", h.GetCookies())
-		r.Post("/cookies", h.SetCookies())
-		r.Delete("/cookies", h.DeleteCookies())
-		r.Post("/template", h.AddTemplate())
-		r.Patch("/template", h.UpdateTemplate())
-		r.Get("/template/all", h.GetTemplates())
-		r.Delete("/template/{id}", h.DeleteTemplate())
+		r.Use(middlewares.RateLimit)
+
+		var (
+			read    = middlewares.RequireScope(middlewares.ScopeRead)
+			enqueue = middlewares.RequireScope(middlewares.ScopeEnqueue)
+			remove  = middlewares.RequireScope(middlewares.ScopeDelete)
+			admin   = middlewares.RequireScope(middlewares.ScopeAdmin)
+		)
+
+		r.With(enqueue).Post("/exec", h.Exec())
+		r.With(enqueue).Post("/execPlaylist", h.ExecPlaylist())
+		r.With(enqueue).Post("/execLivestream", h.ExecLivestream())
+		r.With(read).Get("/running", h.Running())
+		r.With(read).Get("/events", h.Events())
+		r.With(enqueue).Post("/process/{id}/priority", h.SetPriority())
+		r.With(enqueue).Post("/process/{id}/pause", h.Pause())
+		r.With(enqueue).Post("/process/{id}/resume", h.Resume())
+		r.With(read).Get("/process/{id}/log", h.ProcessLog())
+		r.With(read).Get("/process/{id}/log/sse", h.TailProcessLog())
+		r.With(read).Get("/bandwidth", h.Bandwidth())
+		r.With(admin).Post("/bandwidth", h.SetBandwidth())
+		r.With(read).Get("/version", h.GetVersion())
+		r.With(admin).Get("/cookies", h.GetCookies())
+		r.With(admin).Post("/cookies", h.SetCookies())
+		r.With(admin).Delete("/cookies", h.DeleteCookies())
+		r.With(enqueue).Post("/template", h.AddTemplate())
+		r.With(enqueue).Patch("/template", h.UpdateTemplate())
+		r.With(read).Get("/template/all", h.GetTemplates())
+		r.With(remove).Delete("/template/{id}", h.DeleteTemplate())
 	}
 }
@@ -1,8 +1,12 @@
 package rest
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
@@ -28,7 +32,7 @@ func (h *Handler) Exec() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 
-		id, err := h.service.Exec(req)
+		id, err := h.service.Exec(r.Context(), req)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -53,9 +57,9 @@ func (h *Handler) ExecPlaylist() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 
-		err := h.service.ExecPlaylist(req)
+		err := h.service.ExecPlaylist(r.Context(), req)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -79,7 +83,10 @@ func (h *Handler) ExecLivestream() http.HandlerFunc {
 			return
 		}
 
-		h.service.ExecLivestream(req)
+		if err := h.service.ExecLivestream(r.Context(), req); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
 		if err := json.NewEncoder(w).Encode("ok"); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -107,6 +114,260 @@ func (h *Handler) Running() http.HandlerFunc {
 	}
 }
 
+func (h *Handler) SetPriority() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var req internal.PriorityRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req.Id = chi.URLParam(r, "id")
+
+		if err := h.service.SetPriority(r.Context(), req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode("ok"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (h *Handler) Bandwidth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(h.service.Bandwidth(r.Context())); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (h *Handler) SetBandwidth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var req internal.BandwidthRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		status, err := h.service.SetBandwidth(r.Context(), req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (h *Handler) Pause() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := h.service.Pause(r.Context(), chi.URLParam(r, "id")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode("ok"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (h *Handler) Resume() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := h.service.Resume(r.Context(), chi.URLParam(r, "id")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode("ok"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (h *Handler) ProcessLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		res, err := h.service.ProcessLog(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// Server sent events stream of the yt-dlp output of a process, starting with
+// the buffered lines.
+func (h *Handler) TailProcessLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "SSE not supported", http.StatusInternalServerError)
+			return
+		}
+
+		backlog, lines, err := h.service.TailProcessLog(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		send := func(line internal.LogLine) error {
+			var b bytes.Buffer
+
+			b.WriteString("event: log\n")
+			b.WriteString("data: ")
+
+			if err := json.NewEncoder(&b).Encode(line); err != nil {
+				return err
+			}
+
+			b.WriteRune('\n')
+
+			if _, err := io.Copy(w, &b); err != nil {
+				return err
+			}
+
+			flusher.Flush()
+			return nil
+		}
+
+		for _, line := range backlog {
+			if err := send(line); err != nil {
+				return
+			}
+		}
+
+		for line := range lines {
+			if err := send(line); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Server-sent events of the processes changes.
+// Events can be filtered with the id, status and type query parameters, each
+// one accepting a comma separated list. A client resumes from the last event
+// it has received through the Last-Event-ID header or the since parameter.
+func (h *Handler) Events() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "SSE not supported", http.StatusInternalServerError)
+			return
+		}
+
+		query := r.URL.Query()
+
+		filter, err := internal.NewEventFilter(
+			splitQuery(query["id"]),
+			splitQuery(query["status"]),
+			splitQuery(query["type"]),
+		)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		lastEventId := r.Header.Get("Last-Event-ID")
+		if lastEventId == "" {
+			lastEventId = query.Get("since")
+		}
+
+		var since uint64
+		if lastEventId != "" {
+			since, err = strconv.ParseUint(lastEventId, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid event id", http.StatusBadRequest)
+				return
+			}
+		}
+
+		backlog, events := h.service.Events(r.Context(), filter, since)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		send := func(event internal.Event) error {
+			var b bytes.Buffer
+
+			fmt.Fprintf(&b, "id: %d\n", event.Seq)
+			fmt.Fprintf(&b, "event: %s\n", event.Type)
+			b.WriteString("data: ")
+
+			if err := json.NewEncoder(&b).Encode(event); err != nil {
+				return err
+			}
+
+			b.WriteRune('\n')
+
+			if _, err := io.Copy(w, &b); err != nil {
+				return err
+			}
+
+			flusher.Flush()
+			return nil
+		}
+
+		for _, event := range backlog {
+			if err := send(event); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+
+		for event := range events {
+			if err := send(event); err != nil {
+				return
+			}
+		}
+	}
+}
+
 func (h *Handler) GetCookies() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
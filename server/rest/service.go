@@ -7,14 +7,19 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal/livestream"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
 )
 
+var errTemplateNotFound = errors.New("template not found")
+
 type Service struct {
 	mdb *internal.MemoryDB
 	db  *sql.DB
@@ -22,28 +27,171 @@ type Service struct {
 	lm  *livestream.Monitor
 }
 
-func (s *Service) Exec(req internal.DownloadRequest) (string, error) {
+// Retrieves a process, users who aren't admins can only retrieve their own
+// ones.
+func (s *Service) get(ctx context.Context, id string) (*internal.Process, error) {
+	p, err := s.mdb.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if owner, ok := middlewares.RestrictedTo(ctx); ok && p.Owner != owner {
+		return nil, errors.New("no process found for the given key")
+	}
+	return p, nil
+}
+
+// Sets the owner of a download request, users who aren't admins are kept
+// within their download directory.
+func prepare(ctx context.Context, req *internal.DownloadRequest) error {
+	req.Owner = middlewares.Owner(ctx)
+
+	if _, ok := middlewares.RestrictedTo(ctx); ok {
+		return req.Confine()
+	}
+	return nil
+}
+
+func (s *Service) Exec(ctx context.Context, req internal.DownloadRequest) (string, error) {
+	if err := internal.ValidatePriority(req.Priority); err != nil {
+		return "", err
+	}
+
+	rateLimit, err := internal.ParseRate(req.RateLimit)
+	if err != nil {
+		return "", err
+	}
+
+	if err := prepare(ctx, &req); err != nil {
+		return "", err
+	}
+
+	if err := s.mdb.Reserve(ctx, req.Owner, 1); err != nil {
+		return "", err
+	}
+
 	p := &internal.Process{
 		Url:    req.URL,
+		Owner:  req.Owner,
 		Params: req.Params,
 		Output: internal.DownloadOutput{
 			Path:     req.Path,
 			Filename: req.Rename,
 		},
+		Priority:    req.Priority,
+		RateLimit:   rateLimit,
+		ScheduledAt: req.ScheduledAt(),
 	}
 
 	id := s.mdb.Set(p)
 	s.mq.Publish(p)
 
+	audit.Record(ctx, audit.ActionQueue, p.Url)
+
 	return id, nil
 }
 
-func (s *Service) ExecPlaylist(req internal.DownloadRequest) error {
-	return internal.PlaylistDetect(req, s.mq, s.mdb)
+func (s *Service) ExecPlaylist(ctx context.Context, req internal.DownloadRequest) error {
+	if err := prepare(ctx, &req); err != nil {
+		return err
+	}
+	if err := internal.PlaylistDetect(req, s.mq, s.mdb); err != nil {
+		return err
+	}
+
+	audit.Record(ctx, audit.ActionQueuePlaylist, req.URL)
+	return nil
+}
+
+func (s *Service) ExecLivestream(ctx context.Context, req internal.DownloadRequest) error {
+	if err := prepare(ctx, &req); err != nil {
+		return err
+	}
+	if err := s.mdb.Reserve(ctx, req.Owner, 1); err != nil {
+		return err
+	}
+
+	s.lm.Add(req.URL, req.Owner)
+
+	audit.Record(ctx, audit.ActionLivestreamAdd, req.URL)
+	return nil
+}
+
+func (s *Service) SetPriority(ctx context.Context, req internal.PriorityRequest) error {
+	if _, err := s.get(ctx, req.Id); err != nil {
+		return err
+	}
+	if err := s.mq.SetPriority(req.Id, req.Priority); err != nil {
+		return err
+	}
+
+	audit.Record(ctx, audit.ActionPriority, req.Id)
+	return nil
+}
+
+func (s *Service) Bandwidth(ctx context.Context) internal.BandwidthStatus {
+	return s.mq.Bandwidth()
+}
+
+func (s *Service) SetBandwidth(ctx context.Context, req internal.BandwidthRequest) (internal.BandwidthStatus, error) {
+	if err := s.mq.SetBandwidth(req); err != nil {
+		return internal.BandwidthStatus{}, err
+	}
+
+	status := s.mq.Bandwidth()
+	audit.Record(ctx, audit.ActionBandwidth, strconv.FormatInt(status.Limit, 10))
+
+	return status, nil
+}
+
+func (s *Service) Pause(ctx context.Context, id string) error {
+	p, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.mq.Pause(p); err != nil {
+		return err
+	}
+
+	audit.Record(ctx, audit.ActionPause, id)
+	return nil
+}
+
+func (s *Service) Resume(ctx context.Context, id string) error {
+	p, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.mq.Resume(p); err != nil {
+		return err
+	}
+
+	audit.Record(ctx, audit.ActionResume, id)
+	return nil
+}
+
+func (s *Service) ProcessLog(ctx context.Context, id string) ([]internal.LogLine, error) {
+	p, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return p.Log(), nil
+}
+
+func (s *Service) TailProcessLog(ctx context.Context, id string) ([]internal.LogLine, <-chan internal.LogLine, error) {
+	p, err := s.get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	backlog, lines := p.TailLog(ctx)
+	return backlog, lines, nil
 }
 
-func (s *Service) ExecLivestream(req internal.DownloadRequest) {
-	s.lm.Add(req.URL)
+func (s *Service) Events(ctx context.Context, filter internal.EventFilter, since uint64) ([]internal.Event, <-chan internal.Event) {
+	if owner, ok := middlewares.RestrictedTo(ctx); ok {
+		filter.Owner = owner
+	}
+	return s.mdb.Events(ctx, filter, since)
 }
 
 func (s *Service) Running(ctx context.Context) (*[]internal.ProcessResponse, error) {
@@ -51,6 +199,9 @@ func (s *Service) Running(ctx context.Context) (*[]internal.ProcessResponse, err
 	case <-ctx.Done():
 		return nil, context.Canceled
 	default:
+		if owner, ok := middlewares.RestrictedTo(ctx); ok {
+			return s.mdb.AllOf(owner), nil
+		}
 		return s.mdb.All(), nil
 	}
 }
@@ -68,6 +219,8 @@ func (s *Service) GetCookies(ctx context.Context) ([]byte, error) {
 		return nil, err
 	}
 
+	audit.Record(ctx, audit.ActionCookiesRead, "")
+
 	return cookies, nil
 }
 
@@ -80,6 +233,13 @@ func (s *Service) SetCookies(ctx context.Context, cookies string) error {
 	defer fd.Close()
 	fd.WriteString(cookies)
 
+	// the cookies are deleted by emptying them
+	if cookies == "" {
+		audit.Record(ctx, audit.ActionCookiesDelete, "")
+	} else {
+		audit.Record(ctx, audit.ActionCookiesUpdate, "")
+	}
+
 	return nil
 }
 
@@ -93,13 +253,18 @@ func (s *Service) SaveTemplate(ctx context.Context, template *internal.CustomTem
 
 	_, err = conn.ExecContext(
 		ctx,
-		"INSERT INTO templates (id, name, content) VALUES (?, ?, ?)",
+		"INSERT INTO templates (id, name, content, owner) VALUES (?, ?, ?, ?)",
 		uuid.NewString(),
 		template.Name,
 		template.Content,
+		middlewares.Owner(ctx),
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	audit.Record(ctx, audit.ActionTemplateCreate, template.Name)
+	return nil
 }
 
 func (s *Service) GetTemplates(ctx context.Context) (*[]internal.CustomTemplate, error) {
@@ -110,7 +275,15 @@ func (s *Service) GetTemplates(ctx context.Context) (*[]internal.CustomTemplate,
 
 	defer conn.Close()
 
-	rows, err := conn.QueryContext(ctx, "SELECT * FROM templates")
+	// users who aren't admins get the shared templates and their own ones
+	owner, restricted := middlewares.RestrictedTo(ctx)
+
+	rows, err := conn.QueryContext(
+		ctx,
+		"SELECT id, name, content, owner FROM templates WHERE ? OR owner = '' OR owner = ?",
+		!restricted,
+		owner,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +295,7 @@ func (s *Service) GetTemplates(ctx context.Context) (*[]internal.CustomTemplate,
 	for rows.Next() {
 		t := internal.CustomTemplate{}
 
-		err := rows.Scan(&t.Id, &t.Name, &t.Content)
+		err := rows.Scan(&t.Id, &t.Name, &t.Content, &t.Owner)
 		if err != nil {
 			return nil, err
 		}
@@ -141,10 +314,26 @@ func (s *Service) UpdateTemplate(ctx context.Context, t *internal.CustomTemplate
 
 	defer conn.Close()
 
-	_, err = conn.ExecContext(ctx, "UPDATE templates SET name = ?, content = ? WHERE id = ?", t.Name, t.Content, t.Id)
+	// users who aren't admins can only change their own templates
+	owner, restricted := middlewares.RestrictedTo(ctx)
+
+	res, err := conn.ExecContext(
+		ctx,
+		"UPDATE templates SET name = ?, content = ? WHERE id = ? AND (? OR owner = ?)",
+		t.Name,
+		t.Content,
+		t.Id,
+		!restricted,
+		owner,
+	)
 	if err != nil {
 		return nil, err
 	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil, errTemplateNotFound
+	}
+
+	audit.Record(ctx, audit.ActionTemplateUpdate, t.Id)
 
 	return t, nil
 }
@@ -157,9 +346,19 @@ func (s *Service) DeleteTemplate(ctx context.Context, id string) error {
 
 	defer conn.Close()
 
-	_, err = conn.ExecContext(ctx, "DELETE FROM templates WHERE id = ?", id)
+	owner, restricted := middlewares.RestrictedTo(ctx)
 
-	return err
+	res, err := conn.ExecContext(ctx, "DELETE FROM templates WHERE id = ? AND (? OR owner = ?)", id, !restricted, owner)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errTemplateNotFound
+	}
+
+	audit.Record(ctx, audit.ActionTemplateDelete, id)
+
+	return nil
 }
 
 func (s *Service) GetVersion(ctx context.Context) (string, string, error) {
@@ -27,20 +27,19 @@ func WebSocket(w http.ResponseWriter, r *http.Request) {
 	c.WriteJSON(struct{ Status string }{Status: "connected"})
 
 	for {
-		mtype, reader, err := c.NextReader()
+		mtype, body, err := c.ReadMessage()
 		if err != nil {
 			break
 		}
 
-		res := newRequest(reader).Call()
+		res := defaultDispatcher.handle(r.Context(), body)
+		if res == nil {
+			continue
+		}
 
-		writer, err := c.NextWriter(mtype)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := c.WriteMessage(mtype, res); err != nil {
 			break
 		}
-
-		io.Copy(writer, res)
 	}
 }
 
@@ -48,11 +47,18 @@ func WebSocket(w http.ResponseWriter, r *http.Request) {
 func Post(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	res := newRequest(r.Body).Call()
-	_, err := io.Copy(w, res)
-
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+
+	res := defaultDispatcher.handle(r.Context(), body)
+	if res == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
 }
@@ -0,0 +1,345 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"log/slog"
+	"reflect"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+)
+
+// JSON-RPC 2.0 dispatcher, supporting batches and notifications.
+// Requests without the jsonrpc member are treated as JSON-RPC 1.0 and get the
+// same responses net/rpc/jsonrpc used to send, so older clients keep working.
+
+// Standard error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	codeServerError    = -32000 // returned by a method
+	codeForbidden      = -32001 // the caller isn't allowed to call the method
+)
+
+// Max number of calls in a batch
+const maxBatchSize = 1000
+
+// Scope needed to call a method, the ones not listed need the enqueue scope.
+var methodScopes = map[string]middlewares.Scope{
+	"Service.Progess":            middlewares.ScopeRead,
+	"Service.ProgressLivestream": middlewares.ScopeRead,
+	"Service.Bandwidth":          middlewares.ScopeRead,
+	"Service.ProcessLog":         middlewares.ScopeRead,
+	"Service.Pending":            middlewares.ScopeRead,
+	"Service.Running":            middlewares.ScopeRead,
+	"Service.FreeSpace":          middlewares.ScopeRead,
+	"Service.DirectoryTree":      middlewares.ScopeRead,
+	"Service.Kill":               middlewares.ScopeDelete,
+	"Service.KillAll":            middlewares.ScopeDelete,
+	"Service.KillLivestream":     middlewares.ScopeDelete,
+	"Service.KillAllLivestream":  middlewares.ScopeDelete,
+	"Service.Clear":              middlewares.ScopeDelete,
+	"Service.ClearCompleted":     middlewares.ScopeDelete,
+	"Service.SetBandwidth":       middlewares.ScopeAdmin,
+	"Service.UpdateExecutable":   middlewares.ScopeAdmin,
+}
+
+func methodScope(name string) middlewares.Scope {
+	if scope, ok := methodScopes[name]; ok {
+		return scope
+	}
+	return middlewares.ScopeEnqueue
+}
+
+type request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Id      json.RawMessage `json:"id"`
+}
+
+// a request without id is a notification, an explicit null id isn't
+func (r *request) isNotification() bool { return r.Version == "2.0" && r.Id == nil }
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	Id      json.RawMessage `json:"id"`
+}
+
+// JSON-RPC 1.0 response as sent by net/rpc/jsonrpc
+type legacyResponse struct {
+	Id     json.RawMessage `json:"id"`
+	Result any             `json:"result"`
+	Error  any             `json:"error"`
+}
+
+var null = json.RawMessage("null")
+
+type method struct {
+	fn          reflect.Value
+	withContext bool // the method takes the context of the call first
+	argType     reflect.Type
+	replyType   reflect.Type
+}
+
+type dispatcher struct {
+	rcvr    reflect.Value
+	methods map[string]*method
+}
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+	defaultDispatcher *dispatcher
+)
+
+// Register the methods of the service, with the same rules of net/rpc:
+// exported methods with an argument, a pointer reply and an error result.
+// Methods can also take a context.Context first, carrying the identity of the
+// caller.
+func Register(svc *Service) {
+	defaultDispatcher = newDispatcher(svc)
+}
+
+func newDispatcher(rcvr any) *dispatcher {
+	d := &dispatcher{
+		rcvr:    reflect.ValueOf(rcvr),
+		methods: make(map[string]*method),
+	}
+
+	t := reflect.TypeOf(rcvr)
+	name := reflect.Indirect(d.rcvr).Type().Name()
+
+	for i := range t.NumMethod() {
+		m := t.Method(i)
+		mt := m.Type
+
+		if !m.IsExported() || mt.NumOut() != 1 || mt.Out(0) != errorType {
+			continue
+		}
+
+		// the first input is the receiver
+		in := 1
+		withContext := mt.NumIn() == 4 && mt.In(1) == contextType
+		if withContext {
+			in++
+		}
+
+		if mt.NumIn() != in+2 || mt.In(in+1).Kind() != reflect.Pointer {
+			continue
+		}
+		if !isExportedOrBuiltin(mt.In(in)) || !isExportedOrBuiltin(mt.In(in+1)) {
+			continue
+		}
+
+		d.methods[name+"."+m.Name] = &method{
+			fn:          m.Func,
+			withContext: withContext,
+			argType:     mt.In(in),
+			replyType:   mt.In(in + 1).Elem(),
+		}
+	}
+
+	return d
+}
+
+func isExportedOrBuiltin(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return token.IsExported(t.Name()) || t.PkgPath() == ""
+}
+
+// Handles a single request or a batch, returns nil if there's nothing to
+// send back, which is when every request is a notification.
+// ctx carries the identity of the caller.
+func (d *dispatcher) handle(ctx context.Context, body []byte) []byte {
+	body = bytes.TrimSpace(body)
+
+	if len(body) > 0 && body[0] == '[' {
+		return d.handleBatch(ctx, body)
+	}
+
+	res := d.call(ctx, body)
+	if res == nil {
+		return nil
+	}
+	return encode(res)
+}
+
+func (d *dispatcher) handleBatch(ctx context.Context, body []byte) []byte {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return encode(errorResponse(null, codeParseError, err.Error()))
+	}
+	if len(batch) == 0 {
+		return encode(errorResponse(null, codeInvalidRequest, "empty batch"))
+	}
+	if len(batch) > maxBatchSize {
+		return encode(errorResponse(null, codeInvalidRequest, fmt.Sprintf("batch larger than %d calls", maxBatchSize)))
+	}
+
+	responses := []any{}
+	for _, raw := range batch {
+		if res := d.call(ctx, raw); res != nil {
+			responses = append(responses, res)
+		}
+	}
+
+	if len(responses) == 0 {
+		return nil
+	}
+	return encode(responses)
+}
+
+func (d *dispatcher) call(ctx context.Context, raw []byte) any {
+	var req request
+
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return errorResponse(null, codeParseError, err.Error())
+		}
+		return errorResponse(null, codeInvalidRequest, err.Error())
+	}
+
+	id := req.Id
+	if id == nil {
+		id = null
+	}
+
+	legacy := req.Version == ""
+	if !legacy && req.Version != "2.0" {
+		return errorResponse(id, codeInvalidRequest, "unsupported jsonrpc version")
+	}
+	if req.Method == "" {
+		return errorResponse(id, codeInvalidRequest, "missing method")
+	}
+
+	result, rpcErr := d.invoke(ctx, req.Method, req.Params)
+
+	if req.isNotification() {
+		return nil
+	}
+
+	if legacy {
+		res := legacyResponse{Id: id, Result: result}
+		if rpcErr != nil {
+			res.Result = nil
+			res.Error = rpcErr.Message
+		}
+		return res
+	}
+
+	if rpcErr != nil {
+		return &response{Version: "2.0", Error: rpcErr, Id: id}
+	}
+	return &response{Version: "2.0", Result: result, Id: id}
+}
+
+func (d *dispatcher) invoke(ctx context.Context, name string, params json.RawMessage) (result any, rpcErr *rpcError) {
+	m, ok := d.methods[name]
+	if !ok {
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + name}
+	}
+
+	if err := middlewares.Authorize(ctx, methodScope(name)); err != nil {
+		return nil, &rpcError{Code: codeForbidden, Message: err.Error()}
+	}
+
+	arg := reflect.New(m.argType)
+	if err := decodeParams(params, arg.Interface()); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	reply := reflect.New(m.replyType)
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("rpc method panicked", slog.String("method", name), slog.Any("err", r))
+			result, rpcErr = nil, &rpcError{Code: codeInternalError, Message: fmt.Sprint(r)}
+		}
+	}()
+
+	in := []reflect.Value{d.rcvr, arg.Elem(), reply}
+	if m.withContext {
+		in = []reflect.Value{d.rcvr, reflect.ValueOf(ctx), arg.Elem(), reply}
+	}
+
+	out := m.fn.Call(in)
+
+	if err, _ := out[0].Interface().(error); err != nil {
+		rpcErr := &rpcError{Code: codeServerError, Message: err.Error()}
+
+		var ytdlpErr *internal.YtDlpError
+		if errors.As(err, &ytdlpErr) {
+			rpcErr.Data = ytdlpErr
+		}
+		return nil, rpcErr
+	}
+
+	return reply.Interface(), nil
+}
+
+// Params can be either by-position, with the argument as the only element,
+// or by-name, with the argument as the object itself.
+func decodeParams(params json.RawMessage, arg any) error {
+	params = bytes.TrimSpace(params)
+
+	if len(params) == 0 || bytes.Equal(params, null) {
+		return nil
+	}
+
+	switch params[0] {
+	case '[':
+		var positional []json.RawMessage
+		if err := json.Unmarshal(params, &positional); err != nil {
+			return err
+		}
+		switch len(positional) {
+		case 0:
+			return nil
+		case 1:
+			return json.Unmarshal(positional[0], arg)
+		default:
+			return errors.New("expected a single parameter")
+		}
+	case '{':
+		return json.Unmarshal(params, arg)
+	}
+
+	return errors.New("params must be an array or an object")
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *response {
+	return &response{
+		Version: "2.0",
+		Error:   &rpcError{Code: code, Message: message},
+		Id:      id,
+	}
+}
+
+func encode(v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(errorResponse(null, codeInternalError, err.Error()))
+	}
+	return b
+}
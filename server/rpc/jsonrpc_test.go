@@ -0,0 +1,255 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+)
+
+type Calculator struct{}
+
+type Operands struct {
+	A, B int
+}
+
+func (Calculator) Add(args Operands, sum *int) error {
+	*sum = args.A + args.B
+	return nil
+}
+
+func (Calculator) Divide(args Operands, quotient *int) error {
+	if args.B == 0 {
+		return errors.New("division by zero")
+	}
+	*quotient = args.A / args.B
+	return nil
+}
+
+func (Calculator) Caller(ctx context.Context, _ Operands, username *string) error {
+	*username = middlewares.Owner(ctx)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	tooLarge := "[" + strings.Repeat(`{"jsonrpc":"2.0","method":"Calculator.Add"},`, maxBatchSize) +
+		`{"jsonrpc":"2.0","method":"Calculator.Add"}]`
+
+	tests := []struct {
+		name string
+		body string
+		want string // empty when nothing is sent back
+	}{
+		{
+			name: "request",
+			body: `{"jsonrpc":"2.0","method":"Calculator.Add","params":[{"A":1,"B":2}],"id":1}`,
+			want: `{"jsonrpc":"2.0","result":3,"id":1}`,
+		},
+		{
+			name: "params by name",
+			body: `{"jsonrpc":"2.0","method":"Calculator.Add","params":{"A":1,"B":2},"id":"a"}`,
+			want: `{"jsonrpc":"2.0","result":3,"id":"a"}`,
+		},
+		{
+			name: "notification",
+			body: `{"jsonrpc":"2.0","method":"Calculator.Add","params":[{"A":1,"B":2}]}`,
+		},
+		{
+			name: "null id isn't a notification",
+			body: `{"jsonrpc":"2.0","method":"Calculator.Add","params":[{"A":1,"B":2}],"id":null}`,
+			want: `{"jsonrpc":"2.0","result":3,"id":null}`,
+		},
+		{
+			name: "method error",
+			body: `{"jsonrpc":"2.0","method":"Calculator.Divide","params":[{"A":1,"B":0}],"id":1}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32000,"message":"division by zero"},"id":1}`,
+		},
+		{
+			name: "unknown method",
+			body: `{"jsonrpc":"2.0","method":"Calculator.Pow","id":1}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found: Calculator.Pow"},"id":1}`,
+		},
+		{
+			name: "invalid params",
+			body: `{"jsonrpc":"2.0","method":"Calculator.Add","params":[{"A":1},{"B":2}],"id":1}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"expected a single parameter"},"id":1}`,
+		},
+		{
+			name: "unsupported version",
+			body: `{"jsonrpc":"3.0","method":"Calculator.Add","id":1}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"unsupported jsonrpc version"},"id":1}`,
+		},
+		{
+			name: "parse error",
+			body: `{"jsonrpc":"2.0","method"`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32700,"message":"unexpected end of JSON input"},"id":null}`,
+		},
+		{
+			name: "batch of requests and notifications",
+			body: `[
+				{"jsonrpc":"2.0","method":"Calculator.Add","params":[{"A":1,"B":2}],"id":1},
+				{"jsonrpc":"2.0","method":"Calculator.Add","params":[{"A":3,"B":4}]},
+				{"jsonrpc":"2.0","method":"Calculator.Divide","params":[{"A":1,"B":0}],"id":2},
+				{"jsonrpc":"2.0","method":"Calculator.Pow"},
+				{"jsonrpc":"2.0","method":"Calculator.Divide","params":[{"A":8,"B":2}],"id":3}
+			]`,
+			want: `[
+				{"jsonrpc":"2.0","result":3,"id":1},
+				{"jsonrpc":"2.0","error":{"code":-32000,"message":"division by zero"},"id":2},
+				{"jsonrpc":"2.0","result":4,"id":3}
+			]`,
+		},
+		{
+			name: "batch of notifications",
+			body: `[
+				{"jsonrpc":"2.0","method":"Calculator.Add","params":[{"A":1,"B":2}]},
+				{"jsonrpc":"2.0","method":"Calculator.Add","params":[{"A":3,"B":4}]}
+			]`,
+		},
+		{
+			name: "batch with invalid requests",
+			body: `[1, {"jsonrpc":"2.0","method":"Calculator.Add","params":[{"A":1,"B":2}],"id":1}]`,
+			want: `[
+				{"jsonrpc":"2.0","error":{"code":-32600,"message":"json: cannot unmarshal number into Go value of type rpc.request"},"id":null},
+				{"jsonrpc":"2.0","result":3,"id":1}
+			]`,
+		},
+		{
+			name: "empty batch",
+			body: `[]`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"empty batch"},"id":null}`,
+		},
+		{
+			name: "batch too large",
+			body: tooLarge,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"batch larger than 1000 calls"},"id":null}`,
+		},
+		{
+			name: "legacy request",
+			body: `{"method":"Calculator.Add","params":[{"A":1,"B":2}],"id":7}`,
+			want: `{"id":7,"result":3,"error":null}`,
+		},
+		{
+			name: "legacy error",
+			body: `{"method":"Calculator.Divide","params":[{"A":1,"B":0}],"id":7}`,
+			want: `{"id":7,"result":null,"error":"division by zero"}`,
+		},
+		{
+			name: "legacy request without id",
+			body: `{"method":"Calculator.Add","params":[{"A":1,"B":2}]}`,
+			want: `{"id":null,"result":3,"error":null}`,
+		},
+	}
+
+	d := newDispatcher(Calculator{})
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assertResponse(t, d.handle(context.Background(), []byte(tc.body)), tc.want)
+		})
+	}
+}
+
+func TestDispatcherScopes(t *testing.T) {
+	conf := config.Instance()
+	defer func(requireAuth bool) { conf.RequireAuth = requireAuth }(conf.RequireAuth)
+	conf.RequireAuth = true
+
+	tests := []struct {
+		name     string
+		identity *middlewares.Identity
+		body     string
+		want     string
+	}{
+		{
+			name:     "allowed",
+			identity: &middlewares.Identity{Username: "alice", Role: middlewares.RoleUser},
+			body:     `{"jsonrpc":"2.0","method":"Calculator.Caller","id":1}`,
+			want:     `{"jsonrpc":"2.0","result":"alice","id":1}`,
+		},
+		{
+			name: "not authenticated",
+			body: `{"jsonrpc":"2.0","method":"Calculator.Caller","id":1}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32001,"message":"not authenticated"},"id":1}`,
+		},
+		{
+			name:     "method scope",
+			identity: &middlewares.Identity{Username: "bob", Role: middlewares.RoleViewer},
+			body:     `{"jsonrpc":"2.0","method":"Service.Kill","params":["id"],"id":1}`,
+			want:     `{"jsonrpc":"2.0","error":{"code":-32001,"message":"insufficient permissions"},"id":1}`,
+		},
+		{
+			name:     "default scope",
+			identity: &middlewares.Identity{Username: "bob", Role: middlewares.RoleViewer},
+			body:     `{"jsonrpc":"2.0","method":"Calculator.Caller","id":1}`,
+			want:     `{"jsonrpc":"2.0","error":{"code":-32001,"message":"insufficient permissions"},"id":1}`,
+		},
+		{
+			name: "api token scopes",
+			identity: &middlewares.Identity{
+				Username: "alice",
+				Role:     middlewares.RoleAdmin,
+				Scopes:   []middlewares.Scope{middlewares.ScopeRead, middlewares.ScopeEnqueue},
+			},
+			body: `{"jsonrpc":"2.0","method":"Service.SetBandwidth","params":[{}],"id":1}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32001,"message":"insufficient permissions"},"id":1}`,
+		},
+		{
+			name:     "legacy request",
+			identity: &middlewares.Identity{Username: "bob", Role: middlewares.RoleViewer},
+			body:     `{"method":"Service.KillAll","params":[{}],"id":1}`,
+			want:     `{"id":1,"result":null,"error":"insufficient permissions"}`,
+		},
+	}
+
+	// the methods of the service are rejected before being called
+	var (
+		calculator = newDispatcher(Calculator{})
+		service    = newDispatcher(&Service{})
+	)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.identity != nil {
+				ctx = middlewares.WithIdentity(ctx, tc.identity)
+			}
+
+			d := calculator
+			if strings.Contains(tc.body, `"Service.`) {
+				d = service
+			}
+
+			assertResponse(t, d.handle(ctx, []byte(tc.body)), tc.want)
+		})
+	}
+}
+
+// Compares the responses as json values
+func assertResponse(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	if want == "" {
+		if got != nil {
+			t.Errorf("got %s, want no response", got)
+		}
+		return
+	}
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid response %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected response %s: %v", want, err)
+	}
+
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -1,13 +1,19 @@
 package rpc
 
 import (
+	"context"
 	"errors"
 	"log/slog"
+	"os"
+	"strconv"
 	"strings" // Added for sanitization
 
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/formats"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal/livestream"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/sys"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/updater"
 )
@@ -23,58 +29,97 @@ type Pending []string
 
 type NoArgs struct{}
 
+// Retrieves a process, users who aren't admins can only retrieve their own
+// ones.
+func (s *Service) get(ctx context.Context, id string) (*internal.Process, error) {
+	proc, err := s.db.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if owner, ok := middlewares.RestrictedTo(ctx); ok && proc.Owner != owner {
+		return nil, errors.New("no process found for the given key")
+	}
+	return proc, nil
+}
+
+// Sets the owner of a download request, users who aren't admins are kept
+// within their download directory.
+func prepare(ctx context.Context, args *internal.DownloadRequest) error {
+	args.Owner = middlewares.Owner(ctx)
+
+	if _, ok := middlewares.RestrictedTo(ctx); ok {
+		return args.Confine()
+	}
+	return nil
+}
+
+// Replaces the characters of a channel folder which could lead out of the
+// download directory or aren't allowed in a filename.
+func sanitizeChannelFolder(folder string) string {
+	if folder == "" {
+		return ""
+	}
+
+	sanitized := strings.NewReplacer(
+		"/", "_", "\\", "_", "..", "_",
+		"<", "_", ">", "_", ":", "_", "\"", "_", "|", "_", "?", "_", "*", "_",
+	).Replace(folder)
+
+	slog.Info("Channel folder requested for download", "original", folder, "sanitized", sanitized)
+	return sanitized
+}
+
 // Exec spawns a Process.
 // The result of the execution is the newly spawned process Id.
-func (s *Service) Exec(args internal.DownloadRequest, result *string) error {
-	// Sanitize ChannelFolder
-	var sanitizedChannelFolder string
-	if args.ChannelFolder != "" {
-		tempFolder := args.ChannelFolder
-		// Basic sanitization: replace potentially problematic characters or sequences
-		tempFolder = strings.ReplaceAll(tempFolder, "/", "_")
-		tempFolder = strings.ReplaceAll(tempFolder, "\\", "_") // For literal backslash
-		tempFolder = strings.ReplaceAll(tempFolder, "..", "_") // Prevent path traversal
-
-		// A more robust sanitization for common invalid filename characters.
-		// Note: This is a simplified list. OS-specific rules can be more complex.
-		// Consider a library for more thorough cross-platform sanitization if needed.
-		invalidChars := []string{"<", ">", ":", "\"", "|", "?", "*"} 
-		for _, char := range invalidChars {
-			tempFolder = strings.ReplaceAll(tempFolder, char, "_")
-		}
-		
-		// Ensure it's not empty after sanitization if it was originally non-empty.
-		// This handles cases like args.ChannelFolder being just ".." or "//".
-		if tempFolder == "" {
-			sanitizedChannelFolder = "_" // Or a more descriptive default like "default_subfolder"
-		} else {
-			sanitizedChannelFolder = tempFolder
-		}
-		slog.Info("Channel folder requested for download", "original", args.ChannelFolder, "sanitized", sanitizedChannelFolder)
+func (s *Service) Exec(ctx context.Context, args internal.DownloadRequest, result *string) error {
+	args.ChannelFolder = sanitizeChannelFolder(args.ChannelFolder)
+
+	if err := internal.ValidatePriority(args.Priority); err != nil {
+		return err
+	}
+
+	rateLimit, err := internal.ParseRate(args.RateLimit)
+	if err != nil {
+		return err
+	}
+
+	if err := prepare(ctx, &args); err != nil {
+		return err
+	}
+
+	if err := s.db.Reserve(ctx, args.Owner, 1); err != nil {
+		return err
 	}
 
 	p := &internal.Process{
 		Url:    args.URL,
+		Owner:  args.Owner,
 		Params: args.Params,
 		Output: internal.DownloadOutput{
 			Path:          args.Path,    // Base path from request
 			Filename:      args.Rename,  // Filename template from request
-			ChannelFolder: sanitizedChannelFolder, // Assign sanitized folder name
+			ChannelFolder: args.ChannelFolder,
 		},
 		PreferredFormats:   args.PreferredFormats,   // New
 		PreferredQualities: args.PreferredQualities, // New
+		Priority:           args.Priority,
+		RateLimit:          rateLimit,
+		ScheduledAt:        args.ScheduledAt(),
 	}
 
 	s.db.Set(p)
 	s.mq.Publish(p)
 
+	audit.Record(ctx, audit.ActionQueue, p.Url)
+
 	*result = p.Id
 	return nil
 }
 
 // ExecPlaylist spawns a Process for each item in a playlist.
 // The result of the execution is the newly spawned process Id. (This behavior might need adjustment for playlists)
-func (s *Service) ExecPlaylist(args internal.DownloadRequest, result *string) error {
+func (s *Service) ExecPlaylist(ctx context.Context, args internal.DownloadRequest, result *string) error {
 	// Note: The ChannelFolder from args would apply to all videos in this playlist.
 	// The internal.PlaylistDetect function will need to be aware of this or
 	// args passed to it should include the sanitizedChannelFolder.
@@ -91,76 +136,90 @@ func (s *Service) ExecPlaylist(args internal.DownloadRequest, result *string) er
 	// For now, we assume `PlaylistDetect` will be updated or this is a non-functional change for playlists
 	// until `PlaylistDetect` is refactored.
 
-	// Sanitize ChannelFolder for playlist (same logic as Exec)
-	var sanitizedChannelFolderForPlaylist string
-	if args.ChannelFolder != "" {
-		tempFolder := args.ChannelFolder
-		tempFolder = strings.ReplaceAll(tempFolder, "/", "_")
-		tempFolder = strings.ReplaceAll(tempFolder, "\\", "_")
-		tempFolder = strings.ReplaceAll(tempFolder, "..", "_")
-		invalidChars := []string{"<", ">", ":", "\"", "|", "?", "*"}
-		for _, char := range invalidChars {
-			tempFolder = strings.ReplaceAll(tempFolder, char, "_")
-		}
-		if tempFolder == "" {
-			sanitizedChannelFolderForPlaylist = "_"
-		} else {
-			sanitizedChannelFolderForPlaylist = tempFolder
-		}
-		slog.Info("Using channel folder for playlist download", "original", args.ChannelFolder, "sanitized", sanitizedChannelFolderForPlaylist)
-        args.ChannelFolder = sanitizedChannelFolderForPlaylist // Update args for PlaylistDetect
-	}
+	args.ChannelFolder = sanitizeChannelFolder(args.ChannelFolder) // Update args for PlaylistDetect
 
+	if err := prepare(ctx, &args); err != nil {
+		return err
+	}
 
 	err := internal.PlaylistDetect(args, s.mq, s.db) // PlaylistDetect needs to use args.ChannelFolder
 	if err != nil {
 		return err
 	}
 
+	audit.Record(ctx, audit.ActionQueuePlaylist, args.URL)
+
 	*result = "" // Typically for playlists, individual process IDs are handled, not one single ID.
 	return nil
 }
 
-// ExecLivestream handles livestream monitoring requests.
-func (s *Service) ExecLivestream(args internal.DownloadRequest, result *string) error {
+// ExecLivestream handles livestream monitoring requests, the livestream is
+// downloaded into the directory of the caller once it starts.
+func (s *Service) ExecLivestream(ctx context.Context, args internal.DownloadRequest, result *string) error {
 	// Livestreams typically don't use ChannelFolder in the same way as direct downloads,
 	// as their output path is usually fixed or handled differently by yt-dlp's live options.
 	// If ChannelFolder were to be used, similar sanitization and Process setup would be needed.
 	// For now, it's ignored for livestreams as per current structure.
 	slog.Info("ExecLivestream called", "url", args.URL)
-	s.lm.Add(args.URL)
+
+	if err := prepare(ctx, &args); err != nil {
+		return err
+	}
+
+	if err := s.db.Reserve(ctx, args.Owner, 1); err != nil {
+		return err
+	}
+
+	s.lm.Add(args.URL, args.Owner)
+
+	audit.Record(ctx, audit.ActionLivestreamAdd, args.URL)
 
 	*result = args.URL
 	return nil
 }
 
-// ProgressLivestream retrieves the status of monitored livestreams.
-func (s *Service) ProgressLivestream(args NoArgs, result *livestream.LiveStreamStatus) error {
-	*result = s.lm.Status()
+// ProgressLivestream retrieves the status of monitored livestreams, users who
+// aren't admins only get their own ones.
+func (s *Service) ProgressLivestream(ctx context.Context, args NoArgs, result *livestream.LiveStreamStatus) error {
+	owner, _ := middlewares.RestrictedTo(ctx)
+
+	*result = s.lm.Status(owner)
 	return nil
 }
 
-// KillLivestream stops monitoring a specific livestream.
-func (s *Service) KillLivestream(args string, result *struct{}) error {
+// KillLivestream stops monitoring a specific livestream, users who aren't
+// admins can only stop their own ones.
+func (s *Service) KillLivestream(ctx context.Context, args string, result *struct{}) error {
 	slog.Info("killing livestream", slog.String("url", args))
 
-	err := s.lm.Remove(args)
+	owner, _ := middlewares.RestrictedTo(ctx)
+
+	err := s.lm.Remove(args, owner)
 	if err != nil {
 		slog.Error("failed killing livestream", slog.String("url", args), slog.Any("err", err))
 		return err
 	}
 
+	audit.Record(ctx, audit.ActionLivestreamKill, args)
 	return nil
 }
 
-// KillAllLivestream stops monitoring all livestreams.
-func (s *Service) KillAllLivestream(args NoArgs, result *struct{}) error {
-	return s.lm.RemoveAll()
+// KillAllLivestream stops monitoring all livestreams, users who aren't admins
+// only stop their own ones.
+func (s *Service) KillAllLivestream(ctx context.Context, args NoArgs, result *struct{}) error {
+	owner, _ := middlewares.RestrictedTo(ctx)
+
+	if err := s.lm.RemoveAll(owner); err != nil {
+		return err
+	}
+
+	audit.Record(ctx, audit.ActionLivestreamKillAll, "")
+	return nil
 }
 
 // Progess retrieves the Progress of a specific Process given its Id
-func (s *Service) Progess(args internal.DownloadRequest, progress *internal.DownloadProgress) error {
-	proc, err := s.db.Get(args.Id)
+func (s *Service) Progess(ctx context.Context, args internal.DownloadRequest, progress *internal.DownloadProgress) error {
+	proc, err := s.get(ctx, args.Id)
 	if err != nil {
 		return err
 	}
@@ -170,7 +229,7 @@ func (s *Service) Progess(args internal.DownloadRequest, progress *internal.Down
 }
 
 // Formats retrieves available format for a given resource
-func (s *Service) Formats(args internal.DownloadRequest, meta *formats.Metadata) error {
+func (s *Service) Formats(ctx context.Context, args internal.DownloadRequest, meta *formats.Metadata) error {
 	var err error
 	// If ChannelFolder is relevant for format fetching (e.g., if it implies different cookies or auth),
 	// that would be a more advanced scenario. For now, it's not used here.
@@ -183,7 +242,9 @@ func (s *Service) Formats(args internal.DownloadRequest, meta *formats.Metadata)
         // If PlaylistDetect is called here, it also needs to be aware of ChannelFolder if it's to be used.
         // The current args for PlaylistDetect might not include it, or PlaylistDetect might not use it.
         // For now, just passing original args.
-		go internal.PlaylistDetect(args, s.mq, s.db)
+		if err := prepare(ctx, &args); err == nil {
+			go internal.PlaylistDetect(args, s.mq, s.db)
+		}
 	}
 
 	if metadata == nil { // If error occurred and metadata is nil
@@ -194,23 +255,88 @@ func (s *Service) Formats(args internal.DownloadRequest, meta *formats.Metadata)
 	return nil
 }
 
+// SetPriority changes the priority of a process still waiting in the download queue
+func (s *Service) SetPriority(ctx context.Context, args internal.PriorityRequest, result *string) error {
+	slog.Info("changing process priority", slog.String("id", args.Id), slog.Int("priority", args.Priority))
+
+	if _, err := s.get(ctx, args.Id); err != nil {
+		return err
+	}
+
+	if err := s.mq.SetPriority(args.Id, args.Priority); err != nil {
+		return err
+	}
+
+	audit.Record(ctx, audit.ActionPriority, args.Id)
+
+	*result = args.Id
+	return nil
+}
+
+// Bandwidth retrieves the global bandwidth limit currently in effect
+func (s *Service) Bandwidth(args NoArgs, status *internal.BandwidthStatus) error {
+	*status = s.mq.Bandwidth()
+	return nil
+}
+
+// SetBandwidth changes the global bandwidth limit at runtime, a null limit
+// restores the configured one
+func (s *Service) SetBandwidth(ctx context.Context, args internal.BandwidthRequest, status *internal.BandwidthStatus) error {
+	if err := s.mq.SetBandwidth(args); err != nil {
+		return err
+	}
+
+	*status = s.mq.Bandwidth()
+
+	audit.Record(ctx, audit.ActionBandwidth, strconv.FormatInt(status.Limit, 10))
+	return nil
+}
+
+// ProcessLog retrieves the buffered yt-dlp output of a process given its Id
+func (s *Service) ProcessLog(ctx context.Context, args string, log *[]internal.LogLine) error {
+	proc, err := s.get(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	*log = proc.Log()
+	return nil
+}
+
 // Pending retrieves a slice of all Pending/Running processes ids
-func (s *Service) Pending(args NoArgs, pending *Pending) error {
-	*pending = *s.db.Keys()
+func (s *Service) Pending(ctx context.Context, args NoArgs, pending *Pending) error {
+	*pending = s.keys(ctx)
 	return nil
 }
 
-// Running retrieves a slice of all Processes progress
-func (s *Service) Running(args NoArgs, running *Running) error {
+// Running retrieves a slice of all Processes progress, users who aren't
+// admins only get their own ones
+func (s *Service) Running(ctx context.Context, args NoArgs, running *Running) error {
+	if owner, ok := middlewares.RestrictedTo(ctx); ok {
+		*running = *s.db.AllOf(owner)
+		return nil
+	}
+
 	*running = *s.db.All()
 	return nil
 }
 
+// Ids of the processes the caller can access
+func (s *Service) keys(ctx context.Context) []string {
+	keys := []string{}
+	for _, key := range *s.db.Keys() {
+		if _, err := s.get(ctx, key); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 // Kill kills a process given its id and remove it from the memoryDB
-func (s *Service) Kill(args string, killed *string) error {
+func (s *Service) Kill(ctx context.Context, args string, killed *string) error {
 	slog.Info("Trying killing process with id", slog.String("id", args))
 
-	proc, err := s.db.Get(args)
+	proc, err := s.get(ctx, args)
 	if err != nil {
 		return err
 	}
@@ -219,6 +345,8 @@ func (s *Service) Kill(args string, killed *string) error {
 		return errors.New("nil process")
 	}
 
+	s.mq.Remove(proc.Id)
+
 	if err := proc.Kill(); err != nil {
 		slog.Info("failed killing process", slog.String("id", proc.Id), slog.Any("err", err))
 		return err
@@ -226,25 +354,67 @@ func (s *Service) Kill(args string, killed *string) error {
 
 	s.db.Delete(proc.Id)
 	slog.Info("succesfully killed process", slog.String("id", proc.Id))
+	audit.Record(ctx, audit.ActionKill, proc.Id)
 	*killed = proc.Id // Return the ID of the killed process
 	return nil
 }
 
+// Pause stops a process keeping its partially downloaded file
+func (s *Service) Pause(ctx context.Context, args string, paused *string) error {
+	slog.Info("pausing process", slog.String("id", args))
+
+	proc, err := s.get(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	if err := s.mq.Pause(proc); err != nil {
+		slog.Error("failed pausing process", slog.String("id", proc.Id), slog.Any("err", err))
+		return err
+	}
+
+	audit.Record(ctx, audit.ActionPause, proc.Id)
+
+	*paused = proc.Id
+	return nil
+}
+
+// Resume restarts a paused process with its original params
+func (s *Service) Resume(ctx context.Context, args string, resumed *string) error {
+	slog.Info("resuming process", slog.String("id", args))
+
+	proc, err := s.get(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	if err := s.mq.Resume(proc); err != nil {
+		slog.Error("failed resuming process", slog.String("id", proc.Id), slog.Any("err", err))
+		return err
+	}
+
+	audit.Record(ctx, audit.ActionResume, proc.Id)
+
+	*resumed = proc.Id
+	return nil
+}
+
 // KillAll kills all process unconditionally and removes them from
-// the memory db
-func (s *Service) KillAll(args NoArgs, killed *string) error { // result type change for consistency? (e.g. stream of IDs)
+// the memory db, users who aren't admins only kill their own ones
+func (s *Service) KillAll(ctx context.Context, args NoArgs, killed *string) error { // result type change for consistency? (e.g. stream of IDs)
 	slog.Info("Killing all spawned processes")
 
 	var (
-		keys       = s.db.Keys()
+		keys       = s.keys(ctx)
 		removeFunc = func(p *internal.Process) error {
 			defer s.db.Delete(p.Id)
+			s.mq.Remove(p.Id)
 			return p.Kill()
 		}
 	)
     var killedIDs []string // To store IDs of processes attempted to be killed
 
-	for _, key := range *keys {
+	for _, key := range keys {
 		proc, err := s.db.Get(key)
 		if err != nil {
 			// Log error but continue to try killing others
@@ -270,21 +440,27 @@ func (s *Service) KillAll(args NoArgs, killed *string) error { // result type ch
 		proc = nil // gc helper
 	}
     *killed = strings.Join(killedIDs, ", ") // Return comma-separated list of killed IDs
+	audit.Record(ctx, audit.ActionKillAll, *killed)
 	return nil
 }
 
 // Clear a process from the db rendering it unusable if active
-func (s *Service) Clear(args string, killed *string) error {
+func (s *Service) Clear(ctx context.Context, args string, killed *string) error {
 	slog.Info("Clearing process with id", slog.String("id", args))
+	if _, err := s.get(ctx, args); err != nil {
+		return err
+	}
+	s.mq.Remove(args)
 	s.db.Delete(args)
+	audit.Record(ctx, audit.ActionClear, args)
     *killed = args // Return the ID of the cleared process
 	return nil
 }
 
 // ClearCompleted removes completed processes
-func (s *Service) ClearCompleted(args NoArgs, clearedCount *int) error { // Changed result to count
+func (s *Service) ClearCompleted(ctx context.Context, args NoArgs, clearedCount *int) error { // Changed result to count
 	var (
-		keys       = s.db.Keys()
+		keys       = s.keys(ctx)
 		count      = 0
 		removeFunc = func(p *internal.Process) error {
 			if p.Progress.Status == internal.StatusCompleted {
@@ -295,7 +471,7 @@ func (s *Service) ClearCompleted(args NoArgs, clearedCount *int) error { // Chan
 		}
 	)
 
-	for _, key := range *keys {
+	for _, key := range keys {
 		proc, err := s.db.Get(key)
 		if err != nil {
             slog.Error("Failed to get process for ClearCompleted", "id", key, "error", err)
@@ -312,6 +488,7 @@ func (s *Service) ClearCompleted(args NoArgs, clearedCount *int) error { // Chan
 		}
 	}
     *clearedCount = count
+	audit.Record(ctx, audit.ActionClearCompleted, "")
 	slog.Info("ClearCompleted finished", "clearedCount", count)
 	return nil
 }
@@ -327,9 +504,20 @@ func (s *Service) FreeSpace(args NoArgs, free *uint64) error {
 	return nil
 }
 
-// DirectoryTree returns a flattned tree of the download directory
-func (s *Service) DirectoryTree(args NoArgs, tree *[]string) error {
-	dfsTree, err := sys.DirectoryTree()
+// DirectoryTree returns a flattned tree of the download directory, users who
+// aren't admins get the one of their own directory
+func (s *Service) DirectoryTree(ctx context.Context, args NoArgs, tree *[]string) error {
+	root := config.Instance().DownloadPath
+	if owner, ok := middlewares.RestrictedTo(ctx); ok {
+		root = config.Instance().UserDownloadPath(owner)
+
+		// it's created by the first download otherwise
+		if err := os.MkdirAll(root, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	dfsTree, err := sys.DirectoryTree(root)
 
 	if err != nil {
 		*tree = nil
@@ -346,7 +534,7 @@ func (s *Service) DirectoryTree(args NoArgs, tree *[]string) error {
 }
 
 // UpdateExecutable updates the yt-dlp binary using its builtin function
-func (s *Service) UpdateExecutable(args NoArgs, updated *bool) error {
+func (s *Service) UpdateExecutable(ctx context.Context, args NoArgs, updated *bool) error {
 	slog.Info("Updating yt-dlp executable to the latest release")
 
 	if err := updater.UpdateExecutable(); err != nil {
@@ -357,6 +545,7 @@ func (s *Service) UpdateExecutable(args NoArgs, updated *bool) error {
 
 	*updated = true
 	slog.Info("Succesfully updated yt-dlp")
+	audit.Record(ctx, audit.ActionUpdateYtdlp, "")
 
 	return nil
 }
@@ -10,7 +10,6 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
-	"net/rpc"
 	"os"
 	"os/signal"
 	"strings"
@@ -20,22 +19,24 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive"
-	archiveDomain "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/domain" // Added
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archiver"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/dbutil"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/filebrowser"
+	ytdlpGRPC "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/grpc"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal/livestream"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/logging"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/metrics"
 	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/openid"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/rest"
 	ytdlpRPC "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/rpc"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/status"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/task"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook"
 
 	_ "modernc.org/sqlite"
 )
@@ -58,8 +59,6 @@ type serverConfig struct {
 var observableLogger = logging.NewObservableLogger()
 
 func RunBlocking(rc *RunConfig) {
-	mdb := internal.NewMemoryDB()
-
 	// ---- LOGGING ---------------------------------------------------
 	logWriters := []io.Writer{
 		os.Stdout,
@@ -104,6 +103,8 @@ func RunBlocking(rc *RunConfig) {
 		slog.Error("failed to init database", slog.String("err", err.Error()))
 	}
 
+	mdb := internal.NewMemoryDB(internal.NewJobStore(db))
+
 	mq, err := internal.NewMessageQueue()
 	if err != nil {
 		panic(err)
@@ -125,8 +126,12 @@ func RunBlocking(rc *RunConfig) {
 		lm:       lm,
 	})
 
-	go gracefulShutdown(srv, mdb)
-	go autoPersist(time.Minute*5, mdb, lm)
+	go gracefulShutdown(srv)
+	go autoPersist(time.Minute*5, lm)
+
+	if conf.GRPCPort > 0 {
+		go serveGRPC(conf.Host, conf.GRPCPort, mdb, mq, lm)
+	}
 
 	var (
 		network = "tcp"
@@ -154,12 +159,15 @@ func RunBlocking(rc *RunConfig) {
 
 func newServer(c serverConfig) *http.Server {
 	archiver.Register(c.db)
+	audit.Register(c.db)
+	openid.Register(c.db)
 
-	cronTaskRunner := task.NewCronTaskRunner(c.mq, c.mdb)
+	cronTaskRunner := subscription.Runner(c.db, c.mq, c.mdb)
 	go cronTaskRunner.Spawner(context.TODO())
+	cronTaskRunner.Recoverer()
 
 	service := ytdlpRPC.Container(c.mdb, c.mq, c.lm)
-	rpc.Register(service)
+	ytdlpRPC.Register(service)
 
 	r := chi.NewRouter()
 
@@ -178,6 +186,7 @@ func newServer(c serverConfig) *http.Server {
 	})
 
 	r.Use(corsMiddleware.Handler)
+	r.Use(middlewares.ClientIP)
 	// use in dev
 	// r.Use(middleware.Logger)
 
@@ -189,17 +198,20 @@ func newServer(c serverConfig) *http.Server {
 
 	// Filebrowser routes
 	r.Route("/filebrowser", func(r chi.Router) {
-		if config.Instance().RequireAuth {
+		if middlewares.AuthEnabled() {
 			r.Use(middlewares.Authenticated)
 		}
-		if config.Instance().UseOpenId {
-			r.Use(openid.Middleware)
-		}
-		r.Post("/downloaded", filebrowser.ListDownloaded)
-		r.Post("/delete", filebrowser.DeleteFile)
-		r.Get("/d/{id}", filebrowser.DownloadFile)
-		r.Get("/v/{id}", filebrowser.SendFile)
-		r.Get("/bulk", filebrowser.BulkDownload(c.mdb))
+
+		var (
+			read   = middlewares.RequireScope(middlewares.ScopeRead)
+			remove = middlewares.RequireScope(middlewares.ScopeDelete)
+		)
+
+		r.With(read).Post("/downloaded", filebrowser.ListDownloaded)
+		r.With(remove).Post("/delete", filebrowser.DeleteFile)
+		r.With(read).Get("/d/{id}", filebrowser.DownloadFile)
+		r.With(read).Get("/v/{id}", filebrowser.SendFile)
+		r.With(read).Get("/bulk", filebrowser.BulkDownload(c.mdb))
 	})
 
 	// Archive routes
@@ -207,15 +219,33 @@ func newServer(c serverConfig) *http.Server {
 	// We might not need archiveService directly in server.go, so using _
 	r.Route("/archive", archiveHandler.ApplyRouter()) // Modified
 
+	// Users
+	userHandler, userService := user.Container(c.db)
+	if err := userService.Bootstrap(context.TODO()); err != nil {
+		slog.Error("failed to create the first admin", slog.String("err", err.Error()))
+	}
+	middlewares.RegisterStore(userService)
+	r.Route("/users", userHandler.ApplyRouter())
+
 	// Authentication routes
 	r.Route("/auth", func(r chi.Router) {
-		r.Post("/login", user.Login)
+		r.With(middlewares.LoginThrottle).Post("/login", userHandler.Login())
 		r.Get("/logout", user.Logout)
 
 		r.Route("/openid", func(r chi.Router) {
 			r.Get("/login", openid.Login)
 			r.Get("/signin", openid.SingIn)
 			r.Get("/logout", openid.Logout)
+
+			r.Group(func(r chi.Router) {
+				if middlewares.AuthEnabled() {
+					r.Use(middlewares.Authenticated)
+				}
+				r.Use(middlewares.RequireScope(middlewares.ScopeAdmin))
+
+				r.Get("/sessions", openid.ListSessions)
+				r.Delete("/sessions/{id}", openid.RevokeSession)
+			})
 		})
 	})
 
@@ -235,6 +265,20 @@ func newServer(c serverConfig) *http.Server {
 	// Status
 	r.Route("/status", status.ApplyRouter(c.mdb))
 
+	// Webhooks
+	webhookHandler, webhookService := webhook.Container(c.db, c.mdb)
+	go webhookService.Listen(context.TODO())
+	r.Route("/webhooks", webhookHandler.ApplyRouter())
+
+	// Audit log
+	auditHandler, _ := audit.Container(c.db)
+	r.Route("/audit", auditHandler.ApplyRouter())
+
+	// Metrics
+	internal.RegisterMetrics(c.mdb, c.mq)
+	archive.RegisterMetrics(c.db)
+	r.Route("/metrics", metrics.ApplyRouter())
+
 	// Subscriptions
 	// Passed archiveRepo to subscription.Container
 	r.Route("/subscriptions", subscription.Container(c.db, cronTaskRunner, archiveRepo).ApplyRouter()) 
@@ -242,7 +286,30 @@ func newServer(c serverConfig) *http.Server {
 	return &http.Server{Handler: r}
 }
 
-func gracefulShutdown(srv *http.Server, db *internal.MemoryDB) {
+func serveGRPC(host string, port int, mdb *internal.MemoryDB, mq *internal.MessageQueue, lm *livestream.Monitor) {
+	// the main listener might be a unix socket
+	if strings.HasPrefix(host, "/") {
+		host = ""
+	}
+
+	address := fmt.Sprintf("%s:%d", host, port)
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		slog.Error("failed to listen for gRPC", slog.String("err", err.Error()))
+		return
+	}
+
+	srv := ytdlpGRPC.NewServer(ytdlpRPC.Container(mdb, mq, lm), mdb)
+
+	slog.Info("gRPC server started", slog.String("address", address))
+
+	if err := srv.Serve(listener); err != nil {
+		slog.Warn("gRPC server stopped", slog.String("err", err.Error()))
+	}
+}
+
+func gracefulShutdown(srv *http.Server) {
 	ctx, stop := signal.NotifyContext(context.Background(),
 		os.Interrupt,
 		syscall.SIGTERM,
@@ -254,19 +321,16 @@ func gracefulShutdown(srv *http.Server, db *internal.MemoryDB) {
 		slog.Info("shutdown signal received")
 
 		defer func() {
-			db.Persist()
-
 			stop()
 			srv.Shutdown(context.Background())
 		}()
 	}()
 }
 
-func autoPersist(d time.Duration, db *internal.MemoryDB, lm *livestream.Monitor) {
+// Downloads are persisted on each state change, only the livestreams monitor
+// needs to be saved periodically.
+func autoPersist(d time.Duration, lm *livestream.Monitor) {
 	for {
-		if err := db.Persist(); err != nil {
-			slog.Warn("failed to persisted session", slog.Any("err", err))
-		}
 		if err := lm.Persist(); err != nil {
 			slog.Warn(
 				"failed to persisted livestreams monitor session", slog.Any("err", err.Error()))
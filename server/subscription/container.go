@@ -3,7 +3,9 @@ package subscription
 import (
 	"database/sql"
 
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive"
 	archiveDomain "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/domain" // Added import
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/domain"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/task"
 )
@@ -16,3 +18,9 @@ func Container(db *sql.DB, runner task.TaskRunner, archiveRepo archiveDomain.Rep
 	)
 	return h
 }
+
+// The runner of the subscriptions, storing their progress in the database
+func Runner(db *sql.DB, mq *internal.MessageQueue, mdb *internal.MemoryDB) task.TaskRunner {
+	_, archiveService, archiveRepo := archive.Container(db)
+	return provideRunner(provideRepository(db), archiveRepo, archiveService, mq, mdb)
+}
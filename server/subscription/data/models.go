@@ -1,8 +1,37 @@
 package data
 
+import (
+	"database/sql"
+	"time"
+)
+
 type Subscription struct {
 	Id       string
 	URL      string
 	Params   string
 	CronExpr string
+	Owner    string
+	Filters  string // json of domain.Filters
+	Backfill int
+
+	Output    string // json of domain.Output
+	Retention string // json of domain.Retention
+
+	LastVideoID    string
+	LastUploadDate string
+
+	LastRunAt           sql.NullTime
+	LastError           string
+	ConsecutiveFailures int
+}
+
+type Run struct {
+	Id             int64
+	SubscriptionId string
+	StartedAt      time.Time
+	EndedAt        time.Time
+	Outcome        string
+	Found          int
+	Enqueued       int
+	Error          string
 }
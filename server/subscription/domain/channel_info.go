@@ -24,6 +24,10 @@ type YtdlpVideoInfo struct {
 	LikeCount       int64  `json:"like_count,omitempty"`
 	AverageRating   float64 `json:"average_rating,omitempty"`
 	IsLive          bool   `json:"is_live,omitempty"`
+	LiveStatus      string `json:"live_status,omitempty"` // "is_live", "was_live", "is_upcoming", ...
+	URL             string `json:"url,omitempty"`         // set instead of webpage_url by some flat playlists
+	Timestamp       int64  `json:"timestamp,omitempty"`
+	ReleaseTimestamp int64 `json:"release_timestamp,omitempty"`
 	PlaylistIndex   int    `json:"playlist_index,omitempty"` // Useful for series
 	PlaylistID      string `json:"playlist_id,omitempty"`
 	PlaylistTitle   string `json:"playlist_title,omitempty"`
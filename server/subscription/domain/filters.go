@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Rules deciding which entries of a subscription are downloaded, the zero
+// value matches every entry.
+type Filters struct {
+	TitleInclude  string `json:"title_include,omitempty"`  // regex the title must match
+	TitleExclude  string `json:"title_exclude,omitempty"`  // regex the title must not match
+	MinDuration   int    `json:"min_duration,omitempty"`   // seconds
+	MaxDuration   int    `json:"max_duration,omitempty"`   // seconds
+	UploadedAfter string `json:"uploaded_after,omitempty"` // YYYY-MM-DD
+	SkipLive      bool   `json:"skip_live,omitempty"`      // ongoing and past livestreams
+	SkipPremieres bool   `json:"skip_premieres,omitempty"` // premieres and streams yet to start
+	SkipShorts    bool   `json:"skip_shorts,omitempty"`
+
+	titleInclude *regexp.Regexp
+	titleExclude *regexp.Regexp
+}
+
+// Compiles the title regexes once, an invalid one fails the parsing
+func (f *Filters) UnmarshalJSON(b []byte) error {
+	type filters Filters // without the method
+
+	if err := json.Unmarshal(b, (*filters)(f)); err != nil {
+		return err
+	}
+	return f.compile()
+}
+
+func (f *Filters) compile() error {
+	f.titleInclude, f.titleExclude = nil, nil
+
+	for _, title := range []struct {
+		expr string
+		re   **regexp.Regexp
+	}{
+		{f.TitleInclude, &f.titleInclude},
+		{f.TitleExclude, &f.titleExclude},
+	} {
+		if title.expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(title.expr)
+		if err != nil {
+			return fmt.Errorf("invalid title regex: %w", err)
+		}
+		*title.re = re
+	}
+
+	return nil
+}
+
+// Checks the rules can be evaluated
+func (f *Filters) Validate() error {
+	if err := f.compile(); err != nil {
+		return err
+	}
+
+	if f.MinDuration < 0 || f.MaxDuration < 0 {
+		return errors.New("durations can't be negative")
+	}
+	if f.MaxDuration > 0 && f.MinDuration > f.MaxDuration {
+		return errors.New("min_duration is greater than max_duration")
+	}
+
+	if f.UploadedAfter != "" {
+		if _, err := time.Parse(time.DateOnly, f.UploadedAfter); err != nil {
+			return errors.New("uploaded_after must be a YYYY-MM-DD date")
+		}
+	}
+
+	return nil
+}
+
+// Returns why an entry of the playlist doesn't match the rules, nil if it
+// does. The rules on properties the flat playlist lacks, like the duration on
+// some sites, let the entry through.
+func (f *Filters) Match(entry *YtdlpVideoInfo) error {
+	if f.titleInclude != nil && !f.titleInclude.MatchString(entry.Title) {
+		return errors.New("title not included")
+	}
+	if f.titleExclude != nil && f.titleExclude.MatchString(entry.Title) {
+		return errors.New("title excluded")
+	}
+
+	if entry.Duration > 0 {
+		if f.MinDuration > 0 && entry.Duration < float64(f.MinDuration) {
+			return errors.New("shorter than min_duration")
+		}
+		if f.MaxDuration > 0 && entry.Duration > float64(f.MaxDuration) {
+			return errors.New("longer than max_duration")
+		}
+	}
+
+	if f.UploadedAfter != "" {
+		after, err := time.Parse(time.DateOnly, f.UploadedAfter)
+		if uploaded, ok := entry.Uploaded(); err == nil && ok && uploaded.Before(after) {
+			return errors.New("uploaded before uploaded_after")
+		}
+	}
+
+	switch entry.LiveStatus {
+	case "is_live", "was_live", "post_live":
+		if f.SkipLive {
+			return errors.New("livestream")
+		}
+	case "is_upcoming":
+		if f.SkipPremieres {
+			return errors.New("premiere")
+		}
+	}
+	if f.SkipLive && entry.IsLive {
+		return errors.New("livestream")
+	}
+
+	if f.SkipShorts && entry.IsShort() {
+		return errors.New("short")
+	}
+
+	return nil
+}
+
+// Upload date of an entry, from the date or the timestamps yt-dlp provides
+func (e *YtdlpVideoInfo) Uploaded() (time.Time, bool) {
+	if t, err := time.Parse("20060102", e.UploadDate); err == nil {
+		return t, true
+	}
+	for _, ts := range []int64{e.Timestamp, e.ReleaseTimestamp} {
+		if ts > 0 {
+			return time.Unix(ts, 0).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// The flat playlists lack the upload date on most sites, the entries are
+// fully extracted when a rule needs it.
+func (f *Filters) NeedsFullEntries() bool {
+	return f.UploadedAfter != ""
+}
+
+// Reports whether an entry is a YouTube short, they're only told apart by
+// their URL in the flat playlists.
+func (e *YtdlpVideoInfo) IsShort() bool {
+	return strings.Contains(e.URL, "/shorts/") || strings.Contains(e.WebpageURL, "/shorts/")
+}
@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFiltersUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{name: "empty", body: `{}`},
+		{name: "valid regexes", body: `{"title_include": "(?i)review", "title_exclude": "\\[live\\]"}`},
+		{name: "invalid include", body: `{"title_include": "(unclosed"}`, wantErr: true},
+		{name: "invalid exclude", body: `{"title_exclude": "*"}`, wantErr: true},
+		{name: "invalid json", body: `{"min_duration": "long"}`, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var f Filters
+			if err := json.Unmarshal([]byte(tc.body), &f); (err != nil) != tc.wantErr {
+				t.Errorf("Unmarshal() error = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFiltersValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters Filters
+		wantErr bool
+	}{
+		{name: "zero value"},
+		{name: "valid", filters: Filters{TitleInclude: "review", MinDuration: 60, MaxDuration: 3600, UploadedAfter: "2024-01-01"}},
+		{name: "only a minimum", filters: Filters{MinDuration: 60}},
+		{name: "invalid regex", filters: Filters{TitleExclude: "(?<"}, wantErr: true},
+		{name: "negative duration", filters: Filters{MinDuration: -1}, wantErr: true},
+		{name: "min above max", filters: Filters{MinDuration: 600, MaxDuration: 60}, wantErr: true},
+		{name: "invalid date", filters: Filters{UploadedAfter: "20240101"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.filters.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() error = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFiltersMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters Filters
+		entry   YtdlpVideoInfo
+		want    bool
+	}{
+		{name: "no rules", entry: YtdlpVideoInfo{Title: "anything", IsLive: true}, want: true},
+		{name: "title included", filters: Filters{TitleInclude: "(?i)review"}, entry: YtdlpVideoInfo{Title: "Camera Review"}, want: true},
+		{name: "title not included", filters: Filters{TitleInclude: "(?i)review"}, entry: YtdlpVideoInfo{Title: "Unboxing"}},
+		{name: "title excluded", filters: Filters{TitleExclude: `\[live\]`}, entry: YtdlpVideoInfo{Title: "Q&A [live]"}},
+		{name: "too short", filters: Filters{MinDuration: 60}, entry: YtdlpVideoInfo{Duration: 59}},
+		{name: "too long", filters: Filters{MaxDuration: 600}, entry: YtdlpVideoInfo{Duration: 601}},
+		{name: "within the durations", filters: Filters{MinDuration: 60, MaxDuration: 600}, entry: YtdlpVideoInfo{Duration: 60}, want: true},
+		// the flat playlists of some sites lack it
+		{name: "unknown duration", filters: Filters{MinDuration: 60}, entry: YtdlpVideoInfo{}, want: true},
+		{name: "uploaded before", filters: Filters{UploadedAfter: "2024-01-01"}, entry: YtdlpVideoInfo{UploadDate: "20231231"}},
+		{name: "uploaded on the day", filters: Filters{UploadedAfter: "2024-01-01"}, entry: YtdlpVideoInfo{UploadDate: "20240101"}, want: true},
+		{name: "timestamp before", filters: Filters{UploadedAfter: "2024-01-01"}, entry: YtdlpVideoInfo{Timestamp: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC).Unix()}},
+		{name: "unknown upload date", filters: Filters{UploadedAfter: "2024-01-01"}, entry: YtdlpVideoInfo{}, want: true},
+		{name: "live", filters: Filters{SkipLive: true}, entry: YtdlpVideoInfo{LiveStatus: "is_live"}},
+		{name: "past livestream", filters: Filters{SkipLive: true}, entry: YtdlpVideoInfo{LiveStatus: "was_live"}},
+		{name: "live flag", filters: Filters{SkipLive: true}, entry: YtdlpVideoInfo{IsLive: true}},
+		{name: "live kept", filters: Filters{SkipPremieres: true}, entry: YtdlpVideoInfo{LiveStatus: "is_live"}, want: true},
+		{name: "premiere", filters: Filters{SkipPremieres: true}, entry: YtdlpVideoInfo{LiveStatus: "is_upcoming"}},
+		{name: "premiere kept", filters: Filters{SkipLive: true}, entry: YtdlpVideoInfo{LiveStatus: "is_upcoming"}, want: true},
+		{name: "short", filters: Filters{SkipShorts: true}, entry: YtdlpVideoInfo{URL: "https://www.youtube.com/shorts/abc"}},
+		{name: "not a short", filters: Filters{SkipShorts: true}, entry: YtdlpVideoInfo{URL: "https://www.youtube.com/watch?v=abc"}, want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.filters.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			err := tc.filters.Match(&tc.entry)
+			if (err == nil) != tc.want {
+				t.Errorf("Match() = %v, want a match %t", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestUploaded(t *testing.T) {
+	var (
+		date      = time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+		timestamp = time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+		release   = time.Date(2024, 1, 4, 12, 0, 0, 0, time.UTC)
+	)
+
+	tests := []struct {
+		name   string
+		entry  YtdlpVideoInfo
+		want   time.Time
+		wantOk bool
+	}{
+		{name: "upload date first", entry: YtdlpVideoInfo{UploadDate: "20240102", Timestamp: timestamp.Unix()}, want: date, wantOk: true},
+		{name: "timestamp", entry: YtdlpVideoInfo{Timestamp: timestamp.Unix(), ReleaseTimestamp: release.Unix()}, want: timestamp, wantOk: true},
+		{name: "release timestamp", entry: YtdlpVideoInfo{ReleaseTimestamp: release.Unix()}, want: release, wantOk: true},
+		{name: "invalid date", entry: YtdlpVideoInfo{UploadDate: "NA", Timestamp: timestamp.Unix()}, want: timestamp, wantOk: true},
+		{name: "unknown"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.entry.Uploaded()
+			if ok != tc.wantOk || !got.Equal(tc.want) {
+				t.Errorf("Uploaded() = %s, %t, want %s, %t", got, ok, tc.want, tc.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Where and how the videos of a subscription are downloaded, the zero value
+// uses the settings of any other download.
+type Output struct {
+	Path               string   `json:"path,omitempty"`           // download directory
+	Filename           string   `json:"filename,omitempty"`       // yt-dlp output template
+	ChannelFolder      string   `json:"channel_folder,omitempty"` // sub-folder of the download directory
+	PreferredFormats   []string `json:"preferred_formats,omitempty"`
+	PreferredQualities []string `json:"preferred_qualities,omitempty"`
+}
+
+// Checks the output can't be written outside of the download directory
+func (o *Output) Validate() error {
+	if o.ChannelFolder != "" && (strings.ContainsAny(o.ChannelFolder, `/\`) || o.ChannelFolder == "." || o.ChannelFolder == "..") {
+		return errors.New("the channel folder must be a single folder name")
+	}
+
+	if o.Filename != "" {
+		if filepath.IsAbs(o.Filename) || slices.Contains(strings.FieldsFunc(o.Filename, isSeparator), "..") {
+			return errors.New("the filename must be relative to the download directory")
+		}
+	}
+
+	return nil
+}
+
+func isSeparator(r rune) bool { return r == '/' || r == '\\' }
+
+// How long the videos downloaded by a subscription are kept, the zero value
+// keeps them forever. When both limits are set a video is deleted as soon as
+// it exceeds either of them.
+type Retention struct {
+	KeepLast   int `json:"keep_last,omitempty"`    // the newest videos kept
+	MaxAgeDays int `json:"max_age_days,omitempty"` // days after their download
+}
+
+func (r *Retention) Validate() error {
+	if r.KeepLast < 0 || r.MaxAgeDays < 0 {
+		return errors.New("the retention limits can't be negative")
+	}
+	return nil
+}
+
+// Reports whether the i-th newest video, downloaded at the given time, has
+// to be deleted.
+func (r *Retention) Expired(i int, downloadedAt, now time.Time) bool {
+	if r.KeepLast > 0 && i >= r.KeepLast {
+		return true
+	}
+	if r.MaxAgeDays > 0 && now.Sub(downloadedAt) > time.Duration(r.MaxAgeDays)*24*time.Hour {
+		return true
+	}
+	return false
+}
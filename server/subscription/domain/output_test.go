@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutputValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  Output
+		wantErr bool
+	}{
+		{name: "zero value"},
+		{name: "valid", output: Output{Path: "/downloads/music", Filename: "%(uploader)s/%(title)s.%(ext)s", ChannelFolder: "channel"}},
+		{name: "dots in a name", output: Output{Filename: "%(title)s..%(ext)s", ChannelFolder: "a..b"}},
+		{name: "nested channel folder", output: Output{ChannelFolder: "a/b"}, wantErr: true},
+		{name: "windows separator", output: Output{ChannelFolder: `a\b`}, wantErr: true},
+		{name: "parent channel folder", output: Output{ChannelFolder: ".."}, wantErr: true},
+		{name: "current channel folder", output: Output{ChannelFolder: "."}, wantErr: true},
+		{name: "absolute filename", output: Output{Filename: "/etc/%(title)s"}, wantErr: true},
+		{name: "filename escaping", output: Output{Filename: "../%(title)s.%(ext)s"}, wantErr: true},
+		{name: "filename escaping on windows", output: Output{Filename: `a\..\..\%(title)s`}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.output.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() error = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetentionValidate(t *testing.T) {
+	tests := []struct {
+		retention Retention
+		wantErr   bool
+	}{
+		{retention: Retention{}},
+		{retention: Retention{KeepLast: 10, MaxAgeDays: 30}},
+		{retention: Retention{KeepLast: -1}, wantErr: true},
+		{retention: Retention{MaxAgeDays: -1}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		if err := tc.retention.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("Validate(%+v) error = %v, want error %t", tc.retention, err, tc.wantErr)
+		}
+	}
+}
+
+func TestRetentionExpired(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		retention Retention
+		i         int // position from the newest
+		age       time.Duration
+		want      bool
+	}{
+		{name: "kept forever", i: 1000, age: time.Hour * 24 * 1000},
+		{name: "among the last", retention: Retention{KeepLast: 3}, i: 2},
+		{name: "past the last", retention: Retention{KeepLast: 3}, i: 3, want: true},
+		{name: "young enough", retention: Retention{MaxAgeDays: 7}, age: time.Hour * 24 * 7},
+		{name: "too old", retention: Retention{MaxAgeDays: 7}, age: time.Hour*24*7 + time.Minute, want: true},
+		// either limit deletes it
+		{name: "among the last but too old", retention: Retention{KeepLast: 3, MaxAgeDays: 7}, age: time.Hour * 24 * 8, want: true},
+		{name: "young but past the last", retention: Retention{KeepLast: 3, MaxAgeDays: 7}, i: 5, want: true},
+		{name: "within both", retention: Retention{KeepLast: 3, MaxAgeDays: 7}, i: 1, age: time.Hour},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.retention.Expired(tc.i, now.Add(-tc.age), now); got != tc.want {
+				t.Errorf("Expired(%d, %s ago) = %t, want %t", tc.i, tc.age, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Outcomes of the runs of a subscription
+const (
+	OutcomeQueued   = "queued"     // new videos were queued
+	OutcomeUpToDate = "up_to_date" // no new videos
+	OutcomeFiltered = "filtered"   // the new videos were filtered out
+	OutcomeSkipped  = "skipped"    // over quota, or the channel is unreachable
+	OutcomeFailed   = "failed"
+	OutcomePanicked = "panicked"
+)
+
+// A run of a subscription
+type Run struct {
+	Id             int64     `json:"id"`
+	SubscriptionId string    `json:"subscription_id"`
+	StartedAt      time.Time `json:"started_at"`
+	EndedAt        time.Time `json:"ended_at"`
+	Outcome        string    `json:"outcome"`
+	Found          int       `json:"found"`    // videos uploaded since the previous run
+	Enqueued       int       `json:"enqueued"` // videos queued for download
+	Error          string    `json:"error,omitempty"`
+}
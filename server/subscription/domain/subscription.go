@@ -2,17 +2,102 @@ package domain
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/data"
+	"github.com/robfig/cron/v3"
 )
 
+// Returned when the owner is already subscribed to the url
+var ErrDuplicate = errors.New("already subscribed to this url")
+
 type Subscription struct {
-	Id       string `json:"id"`
-	URL      string `json:"url"`
-	Params   string `json:"params"`
-	CronExpr string `json:"cron_expression"`
+	Id       string  `json:"id"`
+	URL      string  `json:"url"`
+	Params   string  `json:"params"`
+	CronExpr string  `json:"cron_expression"`
+	Owner    string  `json:"owner,omitempty"`
+	Filters  Filters `json:"filters"`
+	Backfill int     `json:"backfill,omitempty"` // videos downloaded by the first run, 0 for the newest one only
+
+	Output    Output    `json:"output"`
+	Retention Retention `json:"retention"`
+
+	// the newest video seen by the last run, the next one stops there
+	LastVideoID    string `json:"last_video_id,omitempty"`
+	LastUploadDate string `json:"last_upload_date,omitempty"` // YYYYMMDD
+
+	// health of the subscription, set by the runs
+	LastRunAt           *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt           *time.Time `json:"next_run_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+}
+
+// Checks the settings of a subscription sent by a client
+func (s *Subscription) Validate() error {
+	if _, err := cron.ParseStandard(s.CronExpr); err != nil {
+		return fmt.Errorf("invalid cron expression: %w", err)
+	}
+	if s.Backfill < 0 {
+		return errors.New("backfill can't be negative")
+	}
+	return errors.Join(
+		s.Filters.Validate(),
+		s.Output.Validate(),
+		s.Retention.Validate(),
+	)
+}
+
+// Converts a stored subscription
+func FromData(s *data.Subscription) *Subscription {
+	sub := &Subscription{
+		Id:       s.Id,
+		URL:      s.URL,
+		Params:   s.Params,
+		CronExpr: s.CronExpr,
+		Owner:    s.Owner,
+		Backfill: s.Backfill,
+
+		LastVideoID:    s.LastVideoID,
+		LastUploadDate: s.LastUploadDate,
+
+		LastError:           s.LastError,
+		ConsecutiveFailures: s.ConsecutiveFailures,
+	}
+
+	if s.LastRunAt.Valid {
+		sub.LastRunAt = &s.LastRunAt.Time
+	}
+
+	// the settings are stored as json, an empty string for their zero value
+	for _, setting := range []struct {
+		name, value string
+		target      any
+	}{
+		{"filters", s.Filters, &sub.Filters},
+		{"output", s.Output, &sub.Output},
+		{"retention", s.Retention, &sub.Retention},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(setting.value), setting.target); err != nil {
+			slog.Warn(
+				"invalid subscription "+setting.name,
+				slog.String("id", s.Id),
+				slog.String("err", err.Error()),
+			)
+		}
+	}
+
+	return sub
 }
 
 type PaginatedResponse[T any] struct {
@@ -23,11 +108,15 @@ type PaginatedResponse[T any] struct {
 
 type Repository interface {
 	Submit(ctx context.Context, sub *data.Subscription) (*data.Subscription, error)
-	List(ctx context.Context, start int64, limit int) (*[]data.Subscription, error)
-	Get(ctx context.Context, id string) (*data.Subscription, error) // New method
-	UpdateByExample(ctx context.Context, example *data.Subscription) error
+	List(ctx context.Context, start int64, limit int, owner string) (*[]data.Subscription, error) // an empty owner lists everyone's
+	Get(ctx context.Context, id string) (*data.Subscription, error)
+	GetByURL(ctx context.Context, owner, url string) (*data.Subscription, error)
+	UpdateByExample(ctx context.Context, example *data.Subscription) error // an owner restricts it to their subscription
 	Delete(ctx context.Context, id string) error
 	GetCursor(ctx context.Context, id string) (int64, error)
+	SetMark(ctx context.Context, id, videoID, uploadDate string) error // also clears the backfill
+	AddRun(ctx context.Context, run *data.Run) error                   // also updates the health of the subscription
+	ListRuns(ctx context.Context, id string, limit int) (*[]data.Run, error)
 }
 
 type Service interface {
@@ -37,6 +126,7 @@ type Service interface {
 	Delete(ctx context.Context, id string) error
 	GetCursor(ctx context.Context, id string) (int64, error)
 	GetChannelVideos(ctx context.Context, subscriptionID string) (*YtdlpChannelDump, error) // New method
+	ListRuns(ctx context.Context, id string, limit int) ([]Run, error)                      // from the newest
 }
 
 type RestHandler interface {
@@ -45,5 +135,6 @@ type RestHandler interface {
 	UpdateByExample() http.HandlerFunc
 	Delete() http.HandlerFunc
 	GetCursor() http.HandlerFunc
+	ListRuns() http.HandlerFunc
 	ApplyRouter() func(chi.Router)
 }
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	archiveDomain "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/domain" // Added import
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/domain"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/repository"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/rest"
@@ -16,10 +17,12 @@ var (
 	repo domain.Repository
 	svc  domain.Service
 	hand domain.RestHandler
+	run  task.TaskRunner
 
 	repoOnce sync.Once
 	svcOnce  sync.Once
 	handOnce sync.Once
+	runOnce  sync.Once
 )
 
 func provideRepository(db *sql.DB) domain.Repository {
@@ -32,7 +35,7 @@ func provideRepository(db *sql.DB) domain.Repository {
 func provideService(r domain.Repository, runner task.TaskRunner, archiveRepo archiveDomain.Repository) domain.Service { // Signature changed
 	svcOnce.Do(func() {
 		// Order of args for service.New: subscriptionRepo, archiveRepo, taskRunner
-		svc = service.New(r, archiveRepo, runner) // archiveRepo passed here
+		svc = service.NewService(r, archiveRepo, runner) // archiveRepo passed here
 	})
 	return svc
 }
@@ -43,3 +46,10 @@ func provideHandler(s domain.Service) domain.RestHandler {
 	})
 	return hand
 }
+
+func provideRunner(r domain.Repository, archiveRepo archiveDomain.Repository, archiveService archiveDomain.Service, mq *internal.MessageQueue, mdb *internal.MemoryDB) task.TaskRunner {
+	runOnce.Do(func() {
+		run = task.NewCronTaskRunner(mq, mdb, r, archiveRepo, archiveService)
+	})
+	return run
+}
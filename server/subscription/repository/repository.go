@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 
-	"github.com/google/uuid"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/data"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/domain"
 )
@@ -23,6 +22,11 @@ func (r *Repository) Delete(ctx context.Context, id string) error {
 	defer conn.Close()
 
 	_, err = conn.ExecContext(ctx, "DELETE FROM subscriptions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "DELETE FROM subscription_runs WHERE subscription_id = ?", id)
 
 	return err
 }
@@ -48,7 +52,7 @@ func (r *Repository) GetCursor(ctx context.Context, id string) (int64, error) {
 }
 
 // List implements domain.Repository.
-func (r *Repository) List(ctx context.Context, start int64, limit int) (*[]data.Subscription, error) {
+func (r *Repository) List(ctx context.Context, start int64, limit int, owner string) (*[]data.Subscription, error) {
 	conn, err := r.db.Conn(ctx)
 	if err != nil {
 		return nil, err
@@ -58,10 +62,18 @@ func (r *Repository) List(ctx context.Context, start int64, limit int) (*[]data.
 
 	var elements []data.Subscription
 
-	rows, err := conn.QueryContext(ctx, "SELECT rowid, * FROM subscriptions WHERE rowid > ? LIMIT ?", start, limit)
+	rows, err := conn.QueryContext(
+		ctx,
+		"SELECT rowid, id, url, params, cron, owner, filters, backfill, last_video_id, last_upload_date, output, retention, last_run_at, last_error, consecutive_failures FROM subscriptions WHERE rowid > ? AND (? = '' OR owner = ?) LIMIT ?",
+		start,
+		owner,
+		owner,
+		limit,
+	)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var rowId int64
@@ -73,6 +85,16 @@ func (r *Repository) List(ctx context.Context, start int64, limit int) (*[]data.
 			&element.URL,
 			&element.Params,
 			&element.CronExpr,
+			&element.Owner,
+			&element.Filters,
+			&element.Backfill,
+			&element.LastVideoID,
+			&element.LastUploadDate,
+			&element.Output,
+			&element.Retention,
+			&element.LastRunAt,
+			&element.LastError,
+			&element.ConsecutiveFailures,
 		); err != nil {
 			return &elements, err
 		}
@@ -91,10 +113,31 @@ func (r *Repository) Get(ctx context.Context, id string) (*data.Subscription, er
 	}
 	defer conn.Close()
 
-	row := conn.QueryRowContext(ctx, "SELECT id, url, params, cron FROM subscriptions WHERE id = ?", id)
+	row := conn.QueryRowContext(
+		ctx,
+		`SELECT id, url, params, cron, owner, filters, backfill, last_video_id, last_upload_date,
+			output, retention, last_run_at, last_error, consecutive_failures
+		FROM subscriptions WHERE id = ?`,
+		id,
+	)
 
 	var sub data.Subscription
-	err = row.Scan(&sub.Id, &sub.URL, &sub.Params, &sub.CronExpr)
+	err = row.Scan(
+		&sub.Id,
+		&sub.URL,
+		&sub.Params,
+		&sub.CronExpr,
+		&sub.Owner,
+		&sub.Filters,
+		&sub.Backfill,
+		&sub.LastVideoID,
+		&sub.LastUploadDate,
+		&sub.Output,
+		&sub.Retention,
+		&sub.LastRunAt,
+		&sub.LastError,
+		&sub.ConsecutiveFailures,
+	)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Standard way to indicate "not found" without it being an application error yet
@@ -104,6 +147,32 @@ func (r *Repository) Get(ctx context.Context, id string) (*data.Subscription, er
 	return &sub, nil
 }
 
+// GetByURL implements domain.Repository.
+func (r *Repository) GetByURL(ctx context.Context, owner, url string) (*data.Subscription, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var id string
+
+	err = conn.QueryRowContext(
+		ctx,
+		"SELECT id FROM subscriptions WHERE owner = ? AND url = ?",
+		owner,
+		url,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Get(ctx, id)
+}
+
 // Submit implements domain.Repository.
 func (r *Repository) Submit(ctx context.Context, sub *data.Subscription) (*data.Subscription, error) {
 	conn, err := r.db.Conn(ctx)
@@ -115,11 +184,16 @@ func (r *Repository) Submit(ctx context.Context, sub *data.Subscription) (*data.
 
 	_, err = conn.ExecContext(
 		ctx,
-		"INSERT INTO subscriptions (id, url, params, cron) VALUES (?, ?, ?, ?)",
-		uuid.NewString(),
+		"INSERT INTO subscriptions (id, url, params, cron, owner, filters, backfill, output, retention) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		sub.Id,
 		sub.URL,
 		sub.Params,
 		sub.CronExpr,
+		sub.Owner,
+		sub.Filters,
+		sub.Backfill,
+		sub.Output,
+		sub.Retention,
 	)
 
 	return sub, err
@@ -136,17 +210,155 @@ func (r *Repository) UpdateByExample(ctx context.Context, example *data.Subscrip
 
 	_, err = conn.ExecContext(
 		ctx,
-		"UPDATE subscriptions SET url = ?, params = ?, cron = ? WHERE id = ? OR url = ?",
+		// the mark of another channel is meaningless, sqlite evaluates the
+		// assignments against the row before the update.
+		`UPDATE subscriptions SET
+			last_video_id = CASE WHEN url = ? THEN last_video_id ELSE '' END,
+			last_upload_date = CASE WHEN url = ? THEN last_upload_date ELSE '' END,
+			url = ?, params = ?, cron = ?, filters = ?, output = ?, retention = ?
+		WHERE id = ? AND (? = '' OR owner = ?)`,
+		example.URL,
+		example.URL,
 		example.URL,
 		example.Params,
 		example.CronExpr,
+		example.Filters,
+		example.Output,
+		example.Retention,
 		example.Id,
-		example.URL,
+		example.Owner,
+		example.Owner,
+	)
+
+	return err
+}
+
+// SetMark implements domain.Repository.
+func (r *Repository) SetMark(ctx context.Context, id, videoID, uploadDate string) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(
+		ctx,
+		"UPDATE subscriptions SET last_video_id = ?, last_upload_date = ?, backfill = 0 WHERE id = ?",
+		videoID,
+		uploadDate,
+		id,
 	)
 
 	return err
 }
 
+// Runs kept in the history of each subscription
+const maxRuns = 200
+
+// AddRun implements domain.Repository.
+func (r *Repository) AddRun(ctx context.Context, run *data.Run) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO subscription_runs (subscription_id, started_at, ended_at, outcome, found, enqueued, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		run.SubscriptionId,
+		run.StartedAt,
+		run.EndedAt,
+		run.Outcome,
+		run.Found,
+		run.Enqueued,
+		run.Error,
+	)
+	if err != nil {
+		return err
+	}
+
+	// a run failed when it ended with an error
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE subscriptions SET
+			last_run_at = ?,
+			last_error = ?,
+			consecutive_failures = CASE WHEN ? = '' THEN 0 ELSE consecutive_failures + 1 END
+		WHERE id = ?`,
+		run.EndedAt,
+		run.Error,
+		run.Error,
+		run.SubscriptionId,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`DELETE FROM subscription_runs WHERE subscription_id = ? AND id <= (
+			SELECT id FROM subscription_runs WHERE subscription_id = ?
+			ORDER BY id DESC LIMIT 1 OFFSET ?
+		)`,
+		run.SubscriptionId,
+		run.SubscriptionId,
+		maxRuns,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListRuns implements domain.Repository.
+func (r *Repository) ListRuns(ctx context.Context, id string, limit int) (*[]data.Run, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(
+		ctx,
+		`SELECT id, subscription_id, started_at, ended_at, outcome, found, enqueued, error
+		FROM subscription_runs WHERE subscription_id = ? ORDER BY id DESC LIMIT ?`,
+		id,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []data.Run{}
+
+	for rows.Next() {
+		var run data.Run
+
+		if err := rows.Scan(
+			&run.Id,
+			&run.SubscriptionId,
+			&run.StartedAt,
+			&run.EndedAt,
+			&run.Outcome,
+			&run.Found,
+			&run.Enqueued,
+			&run.Error,
+		); err != nil {
+			return &runs, err
+		}
+
+		runs = append(runs, run)
+	}
+
+	return &runs, rows.Err()
+}
+
 func New(db *sql.DB) domain.Repository {
 	return &Repository{
 		db: db,
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/dbutil"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/data"
+	_ "modernc.org/sqlite"
+)
+
+func TestUpdateByExampleMarks(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		owner    string // of the update, empty for an admin
+		wantURL  string
+		wantMark bool
+	}{
+		{name: "same url", url: "https://example.com/a", wantURL: "https://example.com/a", wantMark: true},
+		{name: "url changed", url: "https://example.com/b", wantURL: "https://example.com/b"},
+		{name: "by the owner", url: "https://example.com/b", owner: "alice", wantURL: "https://example.com/b"},
+		{name: "by another user", url: "https://example.com/b", owner: "bob", wantURL: "https://example.com/a", wantMark: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// the migration leaves a lock file in the working directory
+			dir := t.TempDir()
+			t.Chdir(dir)
+
+			db, err := sql.Open("sqlite", filepath.Join(dir, "local.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			if err := dbutil.Migrate(ctx, db); err != nil {
+				t.Fatal(err)
+			}
+
+			r := New(db)
+
+			sub := &data.Subscription{Id: "sub", URL: "https://example.com/a", CronExpr: "* * * * *", Owner: "alice"}
+			if _, err := r.Submit(ctx, sub); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.SetMark(ctx, sub.Id, "v1", "20240101"); err != nil {
+				t.Fatal(err)
+			}
+
+			update := *sub
+			update.URL = tc.url
+			update.Owner = tc.owner
+			update.Params = "-x"
+
+			if err := r.UpdateByExample(ctx, &update); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := r.Get(ctx, sub.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.URL != tc.wantURL {
+				t.Errorf("url = %s, want %s", got.URL, tc.wantURL)
+			}
+			if got.Owner != "alice" {
+				t.Errorf("owner = %s, want alice", got.Owner)
+			}
+
+			hasMark := got.LastVideoID == "v1" && got.LastUploadDate == "20240101"
+			reset := got.LastVideoID == "" && got.LastUploadDate == ""
+
+			switch {
+			case tc.wantMark && !hasMark:
+				t.Errorf("mark = %q %q, want it kept", got.LastVideoID, got.LastUploadDate)
+			case !tc.wantMark && !reset:
+				t.Errorf("mark = %q %q, want it reset", got.LastVideoID, got.LastUploadDate)
+			}
+		})
+	}
+}
+
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	// the migration leaves a lock file in the working directory
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "local.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := dbutil.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return New(db).(*Repository)
+}
+
+func TestAddRunPrunes(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		r     = newTestRepository(t)
+		start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	for _, id := range []string{"sub", "other"} {
+		if _, err := r.Submit(ctx, &data.Subscription{Id: id, URL: "https://example.com/" + id, CronExpr: "* * * * *"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.AddRun(ctx, &data.Run{SubscriptionId: "other", StartedAt: start, EndedAt: start, Outcome: "up_to_date"}); err != nil {
+		t.Fatal(err)
+	}
+
+	total := maxRuns + 5
+	for i := range total {
+		at := start.Add(time.Minute * time.Duration(i))
+		if err := r.AddRun(ctx, &data.Run{SubscriptionId: "sub", StartedAt: at, EndedAt: at, Outcome: "queued", Found: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := r.ListRuns(ctx, "sub", total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*runs) != maxRuns {
+		t.Fatalf("%d runs kept, want %d", len(*runs), maxRuns)
+	}
+
+	// the newest first, the oldest ones pruned
+	if newest, oldest := (*runs)[0], (*runs)[maxRuns-1]; newest.Found != total-1 || oldest.Found != total-maxRuns {
+		t.Errorf("kept the runs from %d to %d, want from %d to %d", oldest.Found, newest.Found, total-maxRuns, total-1)
+	}
+
+	// the history of the other subscriptions is left alone
+	if runs, err := r.ListRuns(ctx, "other", total); err != nil || len(*runs) != 1 {
+		t.Errorf("other subscription runs = %v, %v, want 1", runs, err)
+	}
+}
+
+func TestAddRunHealth(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		r     = newTestRepository(t)
+		start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	if _, err := r.Submit(ctx, &data.Subscription{Id: "sub", URL: "https://example.com/a", CronExpr: "* * * * *"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// each step builds on the previous ones
+	tests := []struct {
+		name         string
+		err          string
+		wantFailures int
+	}{
+		{name: "failed", err: "HTTP Error 503", wantFailures: 1},
+		{name: "failed again", err: "HTTP Error 503", wantFailures: 2},
+		{name: "succeeded", wantFailures: 0},
+		{name: "failed after succeeding", err: "timed out", wantFailures: 1},
+	}
+
+	for i, tc := range tests {
+		endedAt := start.Add(time.Hour * time.Duration(i))
+
+		run := &data.Run{SubscriptionId: "sub", StartedAt: endedAt.Add(-time.Minute), EndedAt: endedAt, Outcome: "failed", Error: tc.err}
+		if err := r.AddRun(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := r.Get(ctx, "sub")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.ConsecutiveFailures != tc.wantFailures || got.LastError != tc.err {
+			t.Errorf("%s: %d failures, last error %q, want %d, %q", tc.name, got.ConsecutiveFailures, got.LastError, tc.wantFailures, tc.err)
+		}
+		if !got.LastRunAt.Valid || !got.LastRunAt.Time.Equal(endedAt) {
+			t.Errorf("%s: last run at %v, want %s", tc.name, got.LastRunAt, endedAt)
+		}
+	}
+}
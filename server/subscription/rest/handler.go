@@ -2,13 +2,13 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
 	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
-	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/openid"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/domain"
 	"log/slog" // Added for logging
 )
@@ -26,19 +26,23 @@ func New(svc domain.Service) domain.RestHandler { // Ensure New returns domain.R
 // ApplyRouter implements domain.RestHandler.
 func (h *RestHandler) ApplyRouter() func(chi.Router) {
 	return func(r chi.Router) {
-		if config.Instance().RequireAuth {
+		if middlewares.AuthEnabled() {
 			r.Use(middlewares.Authenticated)
 		}
-		if config.Instance().UseOpenId {
-			r.Use(openid.Middleware)
-		}
 
-		r.Delete("/{id}", h.Delete())
-		r.Get("/cursor", h.GetCursor())
-		r.Get("/", h.List())
-		r.Post("/", h.Submit())
-		r.Patch("/", h.UpdateByExample())
-		r.Get("/{id}/videos", h.GetChannelVideos()) // New route
+		var (
+			read    = middlewares.RequireScope(middlewares.ScopeRead)
+			enqueue = middlewares.RequireScope(middlewares.ScopeEnqueue)
+			remove  = middlewares.RequireScope(middlewares.ScopeDelete)
+		)
+
+		r.With(remove).Delete("/{id}", h.Delete())
+		r.With(read).Get("/cursor", h.GetCursor())
+		r.With(read).Get("/", h.List())
+		r.With(enqueue).Post("/", h.Submit())
+		r.With(enqueue).Patch("/", h.UpdateByExample())
+		r.With(read).Get("/{id}/videos", h.GetChannelVideos()) // New route
+		r.With(read).Get("/{id}/runs", h.ListRuns())
 	}
 }
 
@@ -60,8 +64,13 @@ func (h *RestHandler) GetChannelVideos() http.HandlerFunc {
 		channelDump, err := h.svc.GetChannelVideos(r.Context(), subscriptionID)
 		if err != nil {
 			slog.Error("Error from GetChannelVideos service", "subscriptionID", subscriptionID, "error", err)
-			// Distinguish between "not found" and other errors if possible
-			// For now, generic internal server error.
+			// yt-dlp failures carry an error code the client can act on
+			var ytdlpErr *internal.YtDlpError
+			if errors.As(err, &ytdlpErr) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(ytdlpErr)
+				return
+			}
 			http.Error(w, "Failed to get channel videos: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -74,6 +83,32 @@ func (h *RestHandler) GetChannelVideos() http.HandlerFunc {
 	}
 }
 
+// ListRuns implements domain.RestHandler.
+func (h *RestHandler) ListRuns() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+
+		id := chi.URLParam(r, "id")
+
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = 50
+		}
+
+		res, err := h.svc.ListRuns(r.Context(), id, min(limit, 200))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
 // Delete implements domain.RestHandler.
 func (h *RestHandler) Delete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -163,7 +198,16 @@ func (h *RestHandler) Submit() http.HandlerFunc {
 			return
 		}
 
+		if err := req.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		res, err := h.svc.Submit(r.Context(), &req)
+		if errors.Is(err, domain.ErrDuplicate) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -189,7 +233,22 @@ func (h *RestHandler) UpdateByExample() http.HandlerFunc {
 			return
 		}
 
-		if err := h.svc.UpdateByExample(r.Context(), &req); err != nil {
+		if req.Id == "" {
+			http.Error(w, "the id of the subscription is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := req.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := h.svc.UpdateByExample(r.Context(), &req)
+		if errors.Is(err, domain.ErrDuplicate) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -10,10 +10,14 @@ import (
 	"fmt"
 	"log/slog" // For logging
 	"os/exec"
+	"reflect"
+	"strings"
 
 	"github.com/google/uuid"                                                      // For temporary ID generation in Submit (used in stub)
 	archiveDomain "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/domain" 
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/data" // For data.Subscription
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/domain"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/task" // Added task import
@@ -38,7 +42,7 @@ func NewService(repo domain.Repository, archiveRepo archiveDomain.Repository, ru
 // GetChannelVideos implements domain.Service.
 func (s *service) GetChannelVideos(ctx context.Context, subscriptionID string) (*domain.YtdlpChannelDump, error) {
 	slog.Info("Fetching subscription details", "subscriptionID", subscriptionID)
-	subData, err := s.repo.Get(ctx, subscriptionID) // Use the new Get method
+	subData, err := s.get(ctx, subscriptionID)
 
 	if err != nil {
 		// Check if it's a 'not found' error specifically if your repo.Get returns sql.ErrNoRows
@@ -75,7 +79,7 @@ func (s *service) GetChannelVideos(ctx context.Context, subscriptionID string) (
 	runErr := cmd.Run() // Changed variable name to avoid conflict with 'err' from repo.Get
 	if runErr != nil {
 		slog.Error("yt-dlp command failed", "error", runErr, "stderr", stderr.String())
-		return nil, fmt.Errorf("yt-dlp command failed: %w; Stderr: %s", runErr, stderr.String())
+		return nil, internal.NewYtDlpError(stderr.String())
 	}
 
 	var channelDump domain.YtdlpChannelDump
@@ -115,11 +119,17 @@ func (s *service) GetChannelVideos(ctx context.Context, subscriptionID string) (
 
 func (s *service) Submit(ctx context.Context, sub *domain.Subscription) (*domain.Subscription, error) {
 	slog.Info("Service.Submit called (stub)", "subscriptionURL", sub.URL)
-	dataSub := &data.Subscription{
-		URL:      sub.URL,
-		Params:   sub.Params,
-		CronExpr: sub.CronExpr,
+	dataSub, err := toData(ctx, sub)
+	if err != nil {
+		return nil, err
 	}
+	dataSub.Owner = middlewares.Owner(ctx)
+	dataSub.Backfill = sub.Backfill
+
+	if err := s.ensureUnique(ctx, dataSub.Owner, dataSub.URL, ""); err != nil {
+		return nil, err
+	}
+
 	if sub.Id == "" {
 		dataSub.Id = uuid.NewString() 
 	} else {
@@ -132,17 +142,20 @@ func (s *service) Submit(ctx context.Context, sub *domain.Subscription) (*domain
 		return nil, fmt.Errorf("repo.Submit failed: %w", err)
 	}
 
-	return &domain.Subscription{
-		Id:       savedDataSub.Id,
-		URL:      savedDataSub.URL,
-		Params:   savedDataSub.Params,
-		CronExpr: savedDataSub.CronExpr,
-	}, nil
+	created := domain.FromData(savedDataSub)
+
+	if err := s.runner.Submit(created); err != nil {
+		return nil, err
+	}
+
+	return created, nil
 }
 
 func (s *service) List(ctx context.Context, start int64, limit int) (*domain.PaginatedResponse[[]domain.Subscription], error) {
 	slog.Info("Service.List called (stub)", "start", start, "limit", limit)
-	dataSubs, err := s.repo.List(ctx, start, limit)
+	owner, _ := middlewares.RestrictedTo(ctx)
+
+	dataSubs, err := s.repo.List(ctx, start, limit, owner)
 	if err != nil {
 		slog.Error("repo.List failed in service stub", "error", err)
 		return nil, fmt.Errorf("repo.List failed: %w", err)
@@ -153,11 +166,10 @@ func (s *service) List(ctx context.Context, start int64, limit int) (*domain.Pag
 
 	domainSubs := make([]domain.Subscription, len(*dataSubs))
 	for i, ds := range *dataSubs {
-		domainSubs[i] = domain.Subscription{
-			Id:       ds.Id,
-			URL:      ds.URL,
-			Params:   ds.Params,
-			CronExpr: ds.CronExpr,
+		domainSubs[i] = *domain.FromData(&ds)
+
+		if next, ok := s.runner.NextRun(ds.Id); ok {
+			domainSubs[i].NextRunAt = &next
 		}
 	}
 
@@ -173,21 +185,179 @@ func (s *service) List(ctx context.Context, start int64, limit int) (*domain.Pag
 
 func (s *service) UpdateByExample(ctx context.Context, example *domain.Subscription) error {
 	slog.Info("Service.UpdateByExample called (stub)", "subscriptionID", example.Id)
-	dataSub := &data.Subscription{
-		Id:       example.Id,
-		URL:      example.URL,
-		Params:   example.Params,
-		CronExpr: example.CronExpr,
+	current, err := s.get(ctx, example.Id)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("subscription with ID %s not found", example.Id)
+	}
+	dataSub, err := toData(ctx, example)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureUnique(ctx, current.Owner, dataSub.URL, current.Id); err != nil {
+		return err
 	}
-	return s.repo.UpdateByExample(ctx, dataSub)
+	dataSub.Owner, _ = middlewares.RestrictedTo(ctx)
+
+	if err := s.repo.UpdateByExample(ctx, dataSub); err != nil {
+		return err
+	}
+
+	// restart the subscription with its new settings
+	updated, err := s.repo.Get(ctx, example.Id)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return s.runner.StopTask(example.Id)
+	}
+	return s.runner.Submit(domain.FromData(updated))
 }
 
 func (s *service) Delete(ctx context.Context, id string) error {
 	slog.Info("Service.Delete called (stub)", "subscriptionID", id)
-	return s.repo.Delete(ctx, id)
+	if err := s.authorize(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.runner.StopTask(id)
 }
 
 func (s *service) GetCursor(ctx context.Context, id string) (int64, error) {
 	slog.Info("Service.GetCursor called (stub)", "subscriptionID", id)
+	if err := s.authorize(ctx, id); err != nil {
+		return -1, err
+	}
 	return s.repo.GetCursor(ctx, id)
 }
+
+func (s *service) ListRuns(ctx context.Context, id string, limit int) ([]domain.Run, error) {
+	sub, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, fmt.Errorf("subscription with ID %s not found", id)
+	}
+
+	dataRuns, err := s.repo.ListRuns(ctx, id, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]domain.Run, len(*dataRuns))
+	for i, r := range *dataRuns {
+		runs[i] = domain.Run{
+			Id:             r.Id,
+			SubscriptionId: r.SubscriptionId,
+			StartedAt:      r.StartedAt,
+			EndedAt:        r.EndedAt,
+			Outcome:        r.Outcome,
+			Found:          r.Found,
+			Enqueued:       r.Enqueued,
+			Error:          r.Error,
+		}
+	}
+	return runs, nil
+}
+
+// Retrieves a subscription, users who aren't admins can only retrieve their
+// own ones.
+func (s *service) get(ctx context.Context, id string) (*data.Subscription, error) {
+	sub, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if owner, ok := middlewares.RestrictedTo(ctx); ok && sub != nil && sub.Owner != owner {
+		return nil, nil
+	}
+	return sub, nil
+}
+
+// Users can subscribe to a url only once, another user's subscription to the
+// same url doesn't matter. except is the subscription being updated.
+func (s *service) ensureUnique(ctx context.Context, owner, url, except string) error {
+	existing, err := s.repo.GetByURL(ctx, owner, url)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Id != except {
+		return domain.ErrDuplicate
+	}
+	return nil
+}
+
+// Users who aren't admins can only manage their own subscriptions
+func (s *service) authorize(ctx context.Context, id string) error {
+	if _, ok := middlewares.RestrictedTo(ctx); !ok {
+		return nil
+	}
+
+	sub, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if sub == nil {
+		return fmt.Errorf("subscription with ID %s not found", id)
+	}
+	return nil
+}
+
+// Converts the settings of a subscription sent by a client to be stored,
+// users who aren't admins keep its downloads within their download directory.
+func toData(ctx context.Context, sub *domain.Subscription) (*data.Subscription, error) {
+	if err := sub.Validate(); err != nil {
+		return nil, err
+	}
+
+	// the downloads of a subscription are tracked through their archive entries
+	if sub.Retention != (domain.Retention{}) && !config.Instance().AutoArchive {
+		return nil, errors.New("the retention of subscriptions requires auto_archive")
+	}
+
+	if _, ok := middlewares.RestrictedTo(ctx); ok {
+		req := internal.DownloadRequest{
+			Owner:  middlewares.Owner(ctx),
+			Path:   sub.Output.Path,
+			Params: strings.Fields(sub.Params),
+		}
+		if err := req.Confine(); err != nil {
+			return nil, err
+		}
+		sub.Output.Path = req.Path
+	}
+
+	dataSub := &data.Subscription{
+		Id:       sub.Id,
+		URL:      sub.URL,
+		Params:   sub.Params,
+		CronExpr: sub.CronExpr,
+	}
+
+	// the settings are stored as json, an empty string for their zero value
+	for _, setting := range []struct {
+		value  any
+		target *string
+	}{
+		{sub.Filters, &dataSub.Filters},
+		{sub.Output, &dataSub.Output},
+		{sub.Retention, &dataSub.Retention},
+	} {
+		if reflect.ValueOf(setting.value).IsZero() {
+			continue
+		}
+
+		b, err := json.Marshal(setting.value)
+		if err != nil {
+			return nil, err
+		}
+		*setting.target = string(b)
+	}
+
+	return dataSub, nil
+}
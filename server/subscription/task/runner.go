@@ -3,15 +3,24 @@ package task
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
 	"os/exec"
-	"path/filepath"
 	"regexp"
+	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive"
+	archiveDomain "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/domain"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/metrics"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/data"
 	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/domain"
 	"github.com/robfig/cron/v3"
 )
@@ -20,49 +29,78 @@ type TaskRunner interface {
 	Submit(subcription *domain.Subscription) error
 	Spawner(ctx context.Context)
 	StopTask(id string) error
+	NextRun(id string) (time.Time, bool)
 	Recoverer()
 }
 
 type monitorTask struct {
-	Done         chan struct{}
 	Schedule     cron.Schedule
 	Subscription *domain.Subscription
+
+	delay  time.Duration // before the first run
+	cancel context.CancelFunc
+	next   time.Time // guarded by the mutex of the runner
 }
 
 type CronTaskRunner struct {
-	mq *internal.MessageQueue
-	db *internal.MemoryDB
+	mq      *internal.MessageQueue
+	db      *internal.MemoryDB
+	repo    domain.Repository
+	archive archiveDomain.Repository
+
+	// deletes the expired downloads, recording them in the audit log
+	archiveService archiveDomain.Service
 
-	tasks  chan monitorTask
-	errors chan error
+	tasks chan *monitorTask
 
+	mu      sync.Mutex
 	running map[string]*monitorTask
 }
 
-func NewCronTaskRunner(mq *internal.MessageQueue, db *internal.MemoryDB) TaskRunner {
+func NewCronTaskRunner(mq *internal.MessageQueue, db *internal.MemoryDB, repo domain.Repository, archive archiveDomain.Repository, archiveService archiveDomain.Service) TaskRunner {
 	return &CronTaskRunner{
 		mq:      mq,
 		db:      db,
-		tasks:   make(chan monitorTask),
-		errors:  make(chan error),
+		repo:    repo,
+		archive: archive,
+
+		archiveService: archiveService,
+
+		tasks:   make(chan *monitorTask),
 		running: make(map[string]*monitorTask),
 	}
 }
 
 var argsSplitterRe = regexp.MustCompile(`(?mi)[^\s"']+|"([^"]*)"|'([^']*)'`)
 
+// Delays of the retries of a failed run, doubled at each consecutive failure
+// but never past the next schedule.
+const (
+	retryBase = time.Minute
+	retryMax  = time.Hour
+)
+
+// Schedules a subscription, running it right away. A subscription already
+// scheduled is replaced.
 func (t *CronTaskRunner) Submit(subcription *domain.Subscription) error {
+	return t.submit(subcription, false)
+}
+
+func (t *CronTaskRunner) submit(subcription *domain.Subscription, waitSchedule bool) error {
 	schedule, err := cron.ParseStandard(subcription.CronExpr)
 	if err != nil {
 		return err
 	}
 
-	job := monitorTask{
-		Done:         make(chan struct{}),
+	job := &monitorTask{
 		Schedule:     schedule,
 		Subscription: subcription,
 	}
 
+	if waitSchedule {
+		job.delay = time.Until(schedule.Next(time.Now()))
+	}
+
 	t.tasks <- job
 
 	return nil
@@ -71,107 +109,464 @@ func (t *CronTaskRunner) Submit(subcription *domain.Subscription) error {
 // Handles the entire lifecylce of a monitor job.
 func (t *CronTaskRunner) Spawner(ctx context.Context) {
 	for req := range t.tasks {
-		t.running[req.Subscription.Id] = &req // keep track of the current job
-
-		go func() {
-			ctx, cancel := context.WithCancel(ctx) // inject into the job's context a cancellation singal
-			fetcherEvents := t.doFetch(ctx, &req)  // retrieve the channel of events of the job
-
-			for {
-				select {
-				case <-req.Done:
-					slog.Info("stopping cron job and removing schedule", slog.String("url", req.Subscription.URL))
-					cancel()
-					return
-				case <-fetcherEvents:
-					slog.Info("finished monitoring channel", slog.String("url", req.Subscription.URL))
-				}
-			}
-		}()
+		ctx, cancel := context.WithCancel(ctx) // inject into the job's context a cancellation singal
+		req.cancel = cancel
+		req.next = time.Now().Add(req.delay)
+
+		t.mu.Lock()
+		if previous, ok := t.running[req.Subscription.Id]; ok {
+			previous.cancel()
+		}
+		t.running[req.Subscription.Id] = req // keep track of the current job
+		t.mu.Unlock()
+
+		go t.supervise(ctx, req)
 	}
 }
 
 // Stop a currently scheduled job
 func (t *CronTaskRunner) StopTask(id string) error {
-	task := t.running[id]
-	if task != nil {
-		t.running[id].Done <- struct{}{}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if task, ok := t.running[id]; ok {
+		task.cancel()
 		delete(t.running, id)
 	}
 	return nil
 }
 
-// Start a fetcher and notify on a channel when a fetcher has completed
-func (t *CronTaskRunner) doFetch(ctx context.Context, req *monitorTask) <-chan struct{} {
-	completed := make(chan struct{})
+// When a scheduled job runs next
+func (t *CronTaskRunner) NextRun(id string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if task, ok := t.running[id]; ok {
+		return task.next, true
+	}
+	return time.Time{}, false
+}
+
+// Runs a job on its schedule until it's stopped. The failed runs, panics
+// included, are retried with an exponential backoff, unless the channel is
+// unreachable. Every run is recorded in the history of the subscription.
+func (t *CronTaskRunner) supervise(ctx context.Context, req *monitorTask) {
+	timer := time.NewTimer(req.delay)
+	defer timer.Stop()
+
+	var failures int
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("stopping cron job and removing schedule", slog.String("url", req.Subscription.URL))
+			return
+		case <-timer.C:
+		}
+
+		run := &data.Run{
+			SubscriptionId: req.Subscription.Id,
+			StartedAt:      time.Now().UTC(),
+		}
+
+		next, err := t.run(ctx, req, run)
+		if ctx.Err() != nil {
+			continue // stopped while running
+		}
+
+		run.EndedAt = time.Now().UTC()
+		metrics.SubscriptionRuns.Inc(run.Outcome)
+
+		switch {
+		case err == nil:
+			failures = 0
+		case permanent(err):
+			// there's no point in fetching again right away a channel which
+			// is private, removed or otherwise unreachable.
+			failures = 0
+			run.Error = err.Error()
+			slog.Warn(
+				"subscription fetch failed permanently",
+				slog.String("url", req.Subscription.URL),
+				slog.String("err", err.Error()),
+			)
+		default:
+			failures++
+			run.Error = err.Error()
+
+			retry := retryDelay(failures, next)
+			slog.Error(
+				"subscription run failed",
+				slog.String("url", req.Subscription.URL),
+				slog.Int("failures", failures),
+				slog.Any("retry_in", retry),
+				slog.String("err", err.Error()),
+			)
+			next = retry
+		}
+
+		if err := t.repo.AddRun(context.WithoutCancel(ctx), run); err != nil {
+			slog.Error(
+				"failed to record subscription run",
+				slog.String("url", req.Subscription.URL),
+				slog.String("err", err.Error()),
+			)
+		}
+
+		t.enforceRetention(ctx, req.Subscription)
+
+		t.mu.Lock()
+		req.next = time.Now().Add(next)
+		t.mu.Unlock()
+
+		timer.Reset(next)
+	}
+}
+
+// Delay before retrying after consecutive failed runs, the next schedule
+// comes first if it's sooner.
+func retryDelay(failures int, next time.Duration) time.Duration {
+	return min(retryBase<<min(failures-1, 10), retryMax, next)
+}
 
-	// generator func
-	go func() {
-		for {
-			sleepFor := t.fetcher(ctx, req)
-			completed <- struct{}{}
+// Performs a run of a job, turning a panic into an error
+func (t *CronTaskRunner) run(ctx context.Context, req *monitorTask, run *data.Run) (next time.Duration, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("subscription run panicked", slog.Any("panic", r), slog.String("stack", string(debug.Stack())))
 
-			time.Sleep(sleepFor)
+			run.Outcome = domain.OutcomePanicked
+			next = time.Until(req.Schedule.Next(time.Now()))
+			err = fmt.Errorf("panic: %v", r)
 		}
 	}()
 
-	return completed
+	return t.fetcher(ctx, req, run)
+}
+
+// Reports whether a run failed because the channel is private, removed or
+// otherwise unreachable.
+func permanent(err error) bool {
+	var ytdlpErr *internal.YtDlpError
+	return errors.As(err, &ytdlpErr) && ytdlpErr.Class.Permanent()
 }
 
-// Perform the retrieval of the latest video of the channel.
-// Returns a time.Duration containing the amount of time to the next schedule.
-func (t *CronTaskRunner) fetcher(ctx context.Context, req *monitorTask) time.Duration {
-	slog.Info("fetching latest video for channel", slog.String("channel", req.Subscription.URL))
+// Perform the retrieval of the videos uploaded since the last run, the first
+// run retrieves the latest video or, with a backfill, the latest N ones.
+// Returns a time.Duration containing the amount of time to the next schedule,
+// the outcome and the counts of videos are set in the run.
+func (t *CronTaskRunner) fetcher(ctx context.Context, req *monitorTask, run *data.Run) (time.Duration, error) {
+	slog.Info("fetching latest videos for channel", slog.String("channel", req.Subscription.URL))
 
 	nextSchedule := time.Until(req.Schedule.Next(time.Now()))
 
-	cmd := exec.CommandContext(
-		ctx,
-		config.Instance().DownloaderPath,
-		"-I1",
-		"--flat-playlist",
-		"--print", "webpage_url",
-		req.Subscription.URL,
+	var (
+		sub  = req.Subscription
+		conf = config.Instance().Subscriptions
 	)
 
+	depth, limit := runBounds(sub, conf)
+
+	args := []string{"-I", fmt.Sprintf("1:%d", depth), "--dump-json"}
+	if !sub.Filters.NeedsFullEntries() {
+		args = append(args, "--flat-playlist")
+	}
+
+	cmd := exec.CommandContext(ctx, config.Instance().DownloaderPath, append(args, sub.URL)...)
+
 	stdout, err := cmd.Output()
 	if err != nil {
-		t.errors <- err
-		return time.Duration(0)
+		err = internal.ClassifyExecError(err)
+
+		run.Outcome = domain.OutcomeFailed
+		if permanent(err) {
+			run.Outcome = domain.OutcomeSkipped
+		}
+		return nextSchedule, err
+	}
+
+	entries, err := parseEntries(stdout)
+	if err != nil {
+		run.Outcome = domain.OutcomeFailed
+		return nextSchedule, err
 	}
 
-	latestVideoURL := string(bytes.Trim(stdout, "\n"))
+	// the entries are listed from the newest, they're queued from the oldest
+	// so that the mark can stop at the last one queued when the run is capped.
+	var (
+		fresh    = newEntries(entries, sub)
+		queued   int
+		filtered int
+		mark     *domain.YtdlpVideoInfo
+	)
+
+	for i := len(fresh) - 1; i >= 0; i-- {
+		entry := &fresh[i]
+
+		if limit > 0 && queued == limit {
+			slog.Info(
+				"subscription run capped, the remaining videos are queued by the next one",
+				slog.String("url", sub.URL),
+				slog.Int("remaining", i+1),
+			)
+			break
+		}
+
+		if err := sub.Filters.Match(entry); err != nil {
+			slog.Info(
+				"video filtered out",
+				slog.String("url", sub.URL),
+				slog.String("video", entry.ID),
+				slog.String("reason", err.Error()),
+			)
+			filtered++
+			mark = entry
+			continue
+		}
+
+		url := entry.WebpageURL
+		if url == "" {
+			url = entry.URL
+		}
+
+		// if the download exists there's not point in sending it into the message queue.
+		if exists, err := archive.DownloadExists(ctx, sub.Owner, url); exists && err == nil {
+			mark = entry
+			continue
+		}
+
+		if err := t.db.Reserve(ctx, sub.Owner, 1); err != nil {
+			slog.Warn(
+				"subscription download not queued",
+				slog.String("url", sub.URL),
+				slog.String("owner", sub.Owner),
+				slog.String("err", err.Error()),
+			)
+			break // retried by the next run
+		}
+
+		p := &internal.Process{
+			Url:          url,
+			Owner:        sub.Owner,
+			Subscription: sub.Id,
+			Output: internal.DownloadOutput{
+				Path:          sub.Output.Path,
+				Filename:      sub.Output.Filename,
+				ChannelFolder: sub.Output.ChannelFolder,
+			},
+			PreferredFormats:   sub.Output.PreferredFormats,
+			PreferredQualities: sub.Output.PreferredQualities,
+			Params: append(
+				argsSplitterRe.FindAllString(sub.Params, -1),
+				[]string{
+					"--break-on-existing",
+					"--download-archive",
+					config.Instance().UserArchivePath(sub.Owner),
+				}...),
+			AutoRemove: true,
+		}
 
-	// if the download exists there's not point in sending it into the message queue.
-	exists, err := archive.DownloadExists(ctx, latestVideoURL)
-	if exists && err == nil {
-		return nextSchedule
+		t.db.Set(p)     // give it an id
+		t.mq.Publish(p) // send it to the message queue waiting to be processed
+
+		queued++
+		mark = entry
 	}
 
-	p := &internal.Process{
-		Url: latestVideoURL,
-		Params: append(
-			argsSplitterRe.FindAllString(req.Subscription.Params, 1),
-			[]string{
-				"--break-on-existing",
-				"--download-archive",
-				filepath.Join(config.Instance().Dir(), "archive.txt"),
-			}...),
-		AutoRemove: true,
+	if mark != nil {
+		t.setMark(ctx, sub, mark)
 	}
 
-	t.db.Set(p)     // give it an id
-	t.mq.Publish(p) // send it to the message queue waiting to be processed
+	run.Found = len(fresh)
+	run.Enqueued = queued
+
+	switch {
+	case queued > 0:
+		run.Outcome = domain.OutcomeQueued
+	case len(fresh) > 0 && mark == nil:
+		run.Outcome = domain.OutcomeSkipped
+	case filtered > 0:
+		run.Outcome = domain.OutcomeFiltered
+	default:
+		run.Outcome = domain.OutcomeUpToDate
+	}
 
 	slog.Info(
 		"cron task runner next schedule",
-		slog.String("url", req.Subscription.URL),
+		slog.String("url", sub.URL),
+		slog.Int("queued", queued),
 		slog.Any("duration", nextSchedule),
 	)
 
-	return nextSchedule
+	return nextSchedule, nil
+}
+
+// How many of the newest entries a run looks through and how many of them it
+// queues at most, 0 meaning no limit. The first run looks through the newest
+// entry only, or the backfill which isn't capped.
+func runBounds(sub *domain.Subscription, conf config.Subscriptions) (depth, limit int) {
+	switch {
+	case sub.LastVideoID != "":
+		return max(conf.ScanDepth, 1), conf.MaxPerRun
+	case sub.Backfill > 0:
+		return sub.Backfill, 0
+	default:
+		return 1, conf.MaxPerRun
+	}
+}
+
+// The entries of the flat playlist, one json object per line
+func parseEntries(stdout []byte) ([]domain.YtdlpVideoInfo, error) {
+	var entries []domain.YtdlpVideoInfo
+
+	for line := range bytes.Lines(stdout) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry domain.YtdlpVideoInfo
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// The entries newer than the mark of the subscription. When the mark isn't
+// among them, because the video was removed, the ones uploaded before its
+// date are left out; without a date every entry is new.
+func newEntries(entries []domain.YtdlpVideoInfo, sub *domain.Subscription) []domain.YtdlpVideoInfo {
+	if sub.LastVideoID == "" {
+		return entries
+	}
+
+	for i, entry := range entries {
+		if entry.ID == sub.LastVideoID {
+			return entries[:i]
+		}
+	}
+
+	if sub.LastUploadDate == "" {
+		return entries
+	}
+
+	var fresh []domain.YtdlpVideoInfo
+	for _, entry := range entries {
+		if uploaded, ok := entry.Uploaded(); ok && uploaded.Format("20060102") <= sub.LastUploadDate {
+			continue
+		}
+		fresh = append(fresh, entry)
+	}
+	return fresh
+}
+
+// Moves the mark of the subscription to an entry, keeping the previous upload
+// date if the entry lacks one.
+func (t *CronTaskRunner) setMark(ctx context.Context, sub *domain.Subscription, entry *domain.YtdlpVideoInfo) {
+	sub.LastVideoID = entry.ID
+	sub.Backfill = 0
+
+	if uploaded, ok := entry.Uploaded(); ok {
+		sub.LastUploadDate = uploaded.Format("20060102")
+	}
+
+	if err := t.repo.SetMark(ctx, sub.Id, sub.LastVideoID, sub.LastUploadDate); err != nil {
+		slog.Error(
+			"failed to store the subscription mark",
+			slog.String("url", sub.URL),
+			slog.String("err", err.Error()),
+		)
+	}
 }
 
+// Deletes the downloads of a subscription exceeding its retention, both their
+// archive entries and their files.
+func (t *CronTaskRunner) enforceRetention(ctx context.Context, sub *domain.Subscription) {
+	if sub.Retention == (domain.Retention{}) {
+		return
+	}
+
+	entries, err := t.archive.ListBySubscription(ctx, sub.Id)
+	if err != nil {
+		slog.Error("failed to list subscription downloads", slog.String("url", sub.URL), slog.String("err", err.Error()))
+		return
+	}
+
+	var (
+		now = time.Now()
+		// the deletions are recorded in the audit log as the server's
+		system = middlewares.WithIdentity(ctx, middlewares.SystemIdentity("retention"))
+	)
+
+	for i, entry := range *entries {
+		if !sub.Retention.Expired(i, entry.CreatedAt, now) {
+			continue
+		}
+
+		// the file may have already been deleted by hand
+		if _, err := t.archiveService.HardDelete(system, entry.Id); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn(
+				"failed to delete expired subscription download",
+				slog.String("path", entry.Path),
+				slog.String("err", err.Error()),
+			)
+			continue
+		}
+
+		slog.Info(
+			"deleted expired subscription download",
+			slog.String("url", sub.URL),
+			slog.String("path", entry.Path),
+		)
+	}
+}
+
+// Schedules again the stored subscriptions, their first run waits for the
+// next schedule.
 func (t *CronTaskRunner) Recoverer() {
-	panic("unimplemented")
+	const pageSize = 100
+
+	ctx := context.Background()
+
+	var (
+		start     int64
+		recovered int
+	)
+
+	for {
+		page, err := t.repo.List(ctx, start, pageSize, "")
+		if err != nil {
+			slog.Error("failed to load subscriptions", slog.String("err", err.Error()))
+			return
+		}
+
+		for i := range *page {
+			sub := domain.FromData(&(*page)[i])
+
+			if err := t.submit(sub, true); err != nil {
+				slog.Error(
+					"failed to restore subscription",
+					slog.String("url", sub.URL),
+					slog.String("err", err.Error()),
+				)
+				continue
+			}
+			recovered++
+		}
+
+		if len(*page) < pageSize {
+			break
+		}
+
+		start, err = t.repo.GetCursor(ctx, (*page)[len(*page)-1].Id)
+		if err != nil {
+			slog.Error("failed to load subscriptions", slog.String("err", err.Error()))
+			return
+		}
+	}
+
+	slog.Info("restored subscriptions", slog.Int("count", recovered))
 }
@@ -0,0 +1,451 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	archiveData "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/data"
+	archiveDomain "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archive/domain"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/subscription/domain"
+	"github.com/robfig/cron/v3"
+)
+
+func ids(entries []domain.YtdlpVideoInfo) []string {
+	var s []string
+	for _, e := range entries {
+		s = append(s, e.ID)
+	}
+	return s
+}
+
+func TestNewEntries(t *testing.T) {
+	// listed from the newest, like yt-dlp does
+	entries := []domain.YtdlpVideoInfo{
+		{ID: "v5", UploadDate: "20240105"},
+		{ID: "v4", Timestamp: time.Date(2024, 1, 4, 12, 0, 0, 0, time.UTC).Unix()},
+		{ID: "v3", UploadDate: "20240103"},
+		{ID: "v2"},
+		{ID: "v1", UploadDate: "20240101"},
+	}
+
+	tests := []struct {
+		name           string
+		lastVideoID    string
+		lastUploadDate string
+		want           []string
+	}{
+		{
+			name: "first run",
+			want: []string{"v5", "v4", "v3", "v2", "v1"},
+		},
+		{
+			name:           "cut at the last video",
+			lastVideoID:    "v3",
+			lastUploadDate: "20240103",
+			want:           []string{"v5", "v4"},
+		},
+		{
+			name:           "up to date",
+			lastVideoID:    "v5",
+			lastUploadDate: "20240105",
+		},
+		{
+			name:           "last video gone, cut at its upload date",
+			lastVideoID:    "removed",
+			lastUploadDate: "20240103",
+			want:           []string{"v5", "v4", "v2"},
+		},
+		{
+			name:           "last video gone on the day of the newest one",
+			lastVideoID:    "removed",
+			lastUploadDate: "20240105",
+			want:           []string{"v2"},
+		},
+		{
+			name:        "last video gone without an upload date",
+			lastVideoID: "removed",
+			want:        []string{"v5", "v4", "v3", "v2", "v1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sub := &domain.Subscription{LastVideoID: tc.lastVideoID, LastUploadDate: tc.lastUploadDate}
+
+			if got := ids(newEntries(entries, sub)); !slices.Equal(got, tc.want) {
+				t.Errorf("newEntries() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunBounds(t *testing.T) {
+	conf := config.Subscriptions{MaxPerRun: 10, ScanDepth: 50}
+
+	tests := []struct {
+		name      string
+		sub       domain.Subscription
+		conf      config.Subscriptions
+		wantDepth int
+		wantLimit int
+	}{
+		{
+			name:      "first run",
+			conf:      conf,
+			wantDepth: 1,
+			wantLimit: 10,
+		},
+		{
+			name:      "first run with a backfill",
+			sub:       domain.Subscription{Backfill: 20},
+			conf:      conf,
+			wantDepth: 20,
+			wantLimit: 0,
+		},
+		{
+			name:      "following runs",
+			sub:       domain.Subscription{LastVideoID: "v1"},
+			conf:      conf,
+			wantDepth: 50,
+			wantLimit: 10,
+		},
+		{
+			name:      "backfill done",
+			sub:       domain.Subscription{LastVideoID: "v1", Backfill: 20},
+			conf:      conf,
+			wantDepth: 50,
+			wantLimit: 10,
+		},
+		{
+			name:      "no scan depth",
+			sub:       domain.Subscription{LastVideoID: "v1"},
+			wantDepth: 1,
+			wantLimit: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			depth, limit := runBounds(&tc.sub, tc.conf)
+			if depth != tc.wantDepth || limit != tc.wantLimit {
+				t.Errorf("runBounds() = %d, %d, want %d, %d", depth, limit, tc.wantDepth, tc.wantLimit)
+			}
+		})
+	}
+}
+
+// Keeps the marks in memory, the runner doesn't use the rest
+type marks struct {
+	domain.Repository
+
+	videoID, uploadDate string
+}
+
+func (r *marks) SetMark(ctx context.Context, id, videoID, uploadDate string) error {
+	r.videoID, r.uploadDate = videoID, uploadDate
+	return nil
+}
+
+func TestSetMark(t *testing.T) {
+	var (
+		repo = &marks{}
+		tr   = &CronTaskRunner{repo: repo}
+		sub  = &domain.Subscription{Id: "sub", Backfill: 20}
+	)
+
+	tr.setMark(context.Background(), sub, &domain.YtdlpVideoInfo{ID: "v1", UploadDate: "20240101"})
+
+	if sub.LastVideoID != "v1" || sub.LastUploadDate != "20240101" || sub.Backfill != 0 {
+		t.Errorf("mark = %s %s with a backfill of %d, want v1 20240101 without one", sub.LastVideoID, sub.LastUploadDate, sub.Backfill)
+	}
+	if repo.videoID != "v1" || repo.uploadDate != "20240101" {
+		t.Errorf("stored mark = %s %s, want v1 20240101", repo.videoID, repo.uploadDate)
+	}
+
+	// an entry without an upload date keeps the previous one
+	tr.setMark(context.Background(), sub, &domain.YtdlpVideoInfo{ID: "v2"})
+
+	if repo.videoID != "v2" || repo.uploadDate != "20240101" {
+		t.Errorf("stored mark = %s %s, want v2 20240101", repo.videoID, repo.uploadDate)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		next     time.Duration
+		want     time.Duration
+	}{
+		{failures: 1, next: time.Hour * 24, want: time.Minute},
+		{failures: 2, next: time.Hour * 24, want: time.Minute * 2},
+		{failures: 6, next: time.Hour * 24, want: time.Minute * 32},
+		{failures: 7, next: time.Hour * 24, want: time.Hour},
+		{failures: 100, next: time.Hour * 24, want: time.Hour},
+		// never past the next schedule
+		{failures: 3, next: time.Minute * 3, want: time.Minute * 3},
+	}
+
+	for _, tc := range tests {
+		if got := retryDelay(tc.failures, tc.next); got != tc.want {
+			t.Errorf("retryDelay(%d, %s) = %s, want %s", tc.failures, tc.next, got, tc.want)
+		}
+	}
+}
+
+// Hands the recorded runs over to the test
+type runs struct {
+	domain.Repository
+
+	recorded chan *data.Run
+}
+
+func (r *runs) AddRun(ctx context.Context, run *data.Run) error {
+	r.recorded <- run
+	return nil
+}
+
+func TestSupervise(t *testing.T) {
+	conf := config.Instance()
+	defer func(path string) { conf.DownloaderPath = path }(conf.DownloaderPath)
+
+	tests := []struct {
+		name        string
+		script      string // standing in for yt-dlp
+		wantOutcome string
+		wantErr     bool
+		wantRetry   bool // rather than waiting for the next schedule
+	}{
+		{
+			name:        "up to date",
+			script:      "exit 0",
+			wantOutcome: domain.OutcomeUpToDate,
+		},
+		{
+			name:        "failed",
+			script:      "echo 'ERROR: Unable to download webpage: HTTP Error 503' >&2; exit 1",
+			wantOutcome: domain.OutcomeFailed,
+			wantErr:     true,
+			wantRetry:   true,
+		},
+		{
+			name:        "channel unreachable",
+			script:      "echo 'ERROR: [youtube] abc: Private video' >&2; exit 1",
+			wantOutcome: domain.OutcomeSkipped,
+			wantErr:     true,
+		},
+		{
+			name:        "invalid output",
+			script:      "echo 'not json'",
+			wantOutcome: domain.OutcomeFailed,
+			wantErr:     true,
+			wantRetry:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conf.DownloaderPath = filepath.Join(t.TempDir(), "yt-dlp")
+			if err := os.WriteFile(conf.DownloaderPath, []byte("#!/bin/sh\n"+tc.script+"\n"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				repo = &runs{recorded: make(chan *data.Run)}
+				tr   = &CronTaskRunner{repo: repo}
+				sub  = &domain.Subscription{Id: "sub", URL: "https://example.com/channel", CronExpr: "0 0 1 1 *"}
+			)
+
+			schedule, err := cron.ParseStandard(sub.CronExpr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := &monitorTask{Schedule: schedule, Subscription: sub}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go tr.supervise(ctx, req)
+
+			var run *data.Run
+			select {
+			case run = <-repo.recorded:
+			case <-time.After(time.Second * 10):
+				t.Fatal("no run recorded")
+			}
+
+			if run.SubscriptionId != sub.Id || run.Outcome != tc.wantOutcome || (run.Error != "") != tc.wantErr {
+				t.Errorf("recorded %s run of %s with error %q, want %s with error %t", run.Outcome, run.SubscriptionId, run.Error, tc.wantOutcome, tc.wantErr)
+			}
+			if run.EndedAt.Before(run.StartedAt) {
+				t.Errorf("run ended at %s before starting at %s", run.EndedAt, run.StartedAt)
+			}
+
+			// the next run is set right after recording this one
+			var next time.Time
+			for deadline := time.Now().Add(time.Second * 10); next.IsZero() && time.Now().Before(deadline); {
+				tr.mu.Lock()
+				next = req.next
+				tr.mu.Unlock()
+				time.Sleep(time.Millisecond * 10)
+			}
+
+			retrying := time.Until(next) <= retryBase
+			if retrying != tc.wantRetry {
+				t.Errorf("next run in %s, want a retry %t", time.Until(next).Round(time.Second), tc.wantRetry)
+			}
+		})
+	}
+}
+
+// Lists the subscriptions by pages, their cursor is their position
+type stored struct {
+	domain.Repository
+
+	subscriptions []data.Subscription
+}
+
+func (r *stored) List(ctx context.Context, start int64, limit int, owner string) (*[]data.Subscription, error) {
+	page := r.subscriptions[min(int(start), len(r.subscriptions)):]
+	page = page[:min(limit, len(page))]
+	return &page, nil
+}
+
+func (r *stored) GetCursor(ctx context.Context, id string) (int64, error) {
+	i := slices.IndexFunc(r.subscriptions, func(s data.Subscription) bool { return s.Id == id })
+	return int64(i + 1), nil
+}
+
+func TestRecoverer(t *testing.T) {
+	repo := &stored{}
+	// more than a page
+	for i := range 250 {
+		sub := data.Subscription{Id: strconv.Itoa(i), CronExpr: "0 0 1 1 *"}
+		if i == 42 {
+			sub.CronExpr = "never"
+		}
+		repo.subscriptions = append(repo.subscriptions, sub)
+	}
+
+	tr := &CronTaskRunner{repo: repo, tasks: make(chan *monitorTask)}
+
+	done := make(chan struct{})
+	go func() {
+		tr.Recoverer()
+		close(done)
+	}()
+
+	var restored []string
+	for {
+		select {
+		case req := <-tr.tasks:
+			// the first run waits for the schedule
+			if req.delay <= time.Hour {
+				t.Errorf("subscription %s runs in %s, want at its schedule", req.Subscription.Id, req.delay)
+			}
+			restored = append(restored, req.Subscription.Id)
+			continue
+		case <-done:
+		}
+		break
+	}
+
+	if len(restored) != 249 || slices.Contains(restored, "42") {
+		t.Errorf("restored %d subscriptions, want 249 without the invalid one", len(restored))
+	}
+	if !slices.Contains(restored, "249") {
+		t.Error("the last page wasn't restored")
+	}
+}
+
+// Lists the downloads of a subscription, the runner doesn't use the rest
+type downloads struct {
+	archiveDomain.Repository
+
+	entries []archiveData.ArchiveEntry
+}
+
+func (r *downloads) ListBySubscription(ctx context.Context, id string) (*[]archiveData.ArchiveEntry, error) {
+	return &r.entries, nil
+}
+
+// Records the deletions and who asked for them
+type deletions struct {
+	archiveDomain.Service
+
+	failing map[string]error
+	deleted []string
+	callers []string
+}
+
+func (s *deletions) HardDelete(ctx context.Context, id string) (*archiveDomain.ArchiveEntry, error) {
+	s.callers = append(s.callers, middlewares.Owner(ctx))
+	if err := s.failing[id]; err != nil {
+		return nil, err
+	}
+	s.deleted = append(s.deleted, id)
+	return &archiveDomain.ArchiveEntry{Id: id}, nil
+}
+
+func TestEnforceRetention(t *testing.T) {
+	now := time.Now()
+
+	// from the newest, a day apart from each other
+	var entries []archiveData.ArchiveEntry
+	for i := range 5 {
+		entries = append(entries, archiveData.ArchiveEntry{
+			Id:        "v" + strconv.Itoa(5-i),
+			CreatedAt: now.Add(-time.Hour*24*time.Duration(i) - time.Hour),
+		})
+	}
+
+	tests := []struct {
+		name      string
+		retention domain.Retention
+		failing   map[string]error
+		want      []string
+	}{
+		{name: "kept forever"},
+		{name: "keep last", retention: domain.Retention{KeepLast: 2}, want: []string{"v3", "v2", "v1"}},
+		{name: "max age", retention: domain.Retention{MaxAgeDays: 3}, want: []string{"v2", "v1"}},
+		{name: "both", retention: domain.Retention{KeepLast: 4, MaxAgeDays: 2}, want: []string{"v3", "v2", "v1"}},
+		{
+			// a failure doesn't stop the others from being deleted
+			name:      "failed deletion",
+			retention: domain.Retention{KeepLast: 3},
+			failing:   map[string]error{"v2": errors.New("permission denied")},
+			want:      []string{"v1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				service = &deletions{failing: tc.failing}
+				tr      = &CronTaskRunner{archive: &downloads{entries: entries}, archiveService: service}
+				sub     = &domain.Subscription{Id: "sub", Owner: "alice", Retention: tc.retention}
+			)
+
+			tr.enforceRetention(context.Background(), sub)
+
+			if !slices.Equal(service.deleted, tc.want) {
+				t.Errorf("deleted %v, want %v", service.deleted, tc.want)
+			}
+
+			// recorded in the audit log as the server's, not the owner's
+			for _, caller := range service.callers {
+				if caller != middlewares.SystemUsernamePrefix+"retention" {
+					t.Errorf("deleted by %q, want the retention task", caller)
+				}
+			}
+		})
+	}
+}
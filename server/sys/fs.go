@@ -20,15 +20,13 @@ func FreeSpace() (uint64, error) {
 
 // Build a directory tree started from the specified path using DFS.
 // Then return the flattened tree represented as a list.
-func DirectoryTree() (*[]string, error) {
+func DirectoryTree(rootPath string) (*[]string, error) {
 	type Node struct {
 		path     string
 		children []Node
 	}
 
 	var (
-		rootPath = config.Instance().DownloadPath
-
 		stack     = internal.NewStack[Node]()
 		flattened = make([]string, 0)
 	)
@@ -0,0 +1,24 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+)
+
+type User struct {
+	Id           string
+	Username     string
+	PasswordHash string
+	Role         string
+	CreatedAt    time.Time
+}
+
+type Token struct {
+	Id         string
+	UserId     string
+	Name       string
+	TokenHash  string
+	Scopes     string // comma separated
+	LastUsedAt sql.NullTime
+	CreatedAt  time.Time
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/data"
+)
+
+// Returned by a login with an unknown username or a wrong password
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+type User struct {
+	Id        string           `json:"id"`
+	Username  string           `json:"username"`
+	Password  string           `json:"password,omitempty"` // only accepted, never returned
+	Role      middlewares.Role `json:"role"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// Personal api token, sent as "Authorization: Bearer <token>"
+type Token struct {
+	Id         string              `json:"id"`
+	Name       string              `json:"name"`
+	Token      string              `json:"token,omitempty"` // only returned when the token is created
+	Scopes     []middlewares.Scope `json:"scopes"`
+	LastUsedAt *time.Time          `json:"last_used_at"`
+	CreatedAt  time.Time           `json:"created_at"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type Repository interface {
+	Submit(ctx context.Context, user *data.User) (*data.User, error)
+	List(ctx context.Context) (*[]data.User, error)
+	Get(ctx context.Context, id string) (*data.User, error)
+	GetByUsername(ctx context.Context, username string) (*data.User, error)
+	Update(ctx context.Context, user *data.User) error
+	Delete(ctx context.Context, id string) error
+	CountByRole(ctx context.Context, role string) (int, error)
+	Count(ctx context.Context) (int, error)
+	SubmitToken(ctx context.Context, token *data.Token) (*data.Token, error)
+	ListTokens(ctx context.Context, userId string) (*[]data.Token, error)
+	GetTokenByHash(ctx context.Context, hash string) (*data.Token, error)
+	TouchToken(ctx context.Context, id string, usedAt time.Time) error
+	DeleteToken(ctx context.Context, userId, id string) error
+}
+
+type Service interface {
+	Submit(ctx context.Context, user *User) (*User, error)
+	List(ctx context.Context) (*[]User, error)
+	Update(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id string) error
+	Login(ctx context.Context, req *LoginRequest) (*User, error)
+	Resolve(ctx context.Context, username string) (*middlewares.Identity, error)
+	Bootstrap(ctx context.Context) error
+	SubmitToken(ctx context.Context, owner *middlewares.Identity, token *Token) (*Token, error)
+	ListTokens(ctx context.Context, owner *middlewares.Identity) (*[]Token, error)
+	DeleteToken(ctx context.Context, owner *middlewares.Identity, id string) error
+	ResolveToken(ctx context.Context, token string) (*middlewares.Identity, error)
+}
+
+type RestHandler interface {
+	Login() http.HandlerFunc
+	Me() http.HandlerFunc
+	Submit() http.HandlerFunc
+	List() http.HandlerFunc
+	Update() http.HandlerFunc
+	Delete() http.HandlerFunc
+	SubmitToken() http.HandlerFunc
+	ListTokens() http.HandlerFunc
+	DeleteToken() http.HandlerFunc
+	ApplyRouter() func(chi.Router)
+}
@@ -0,0 +1,344 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/domain"
+)
+
+var ErrNotFound = errors.New("user not found")
+
+type Repository struct {
+	db *sql.DB
+}
+
+// Submit implements domain.Repository.
+func (r *Repository) Submit(ctx context.Context, user *data.User) (*data.User, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	user.Id = uuid.NewString()
+
+	_, err = conn.ExecContext(
+		ctx,
+		"INSERT INTO users (id, username, password_hash, role, created_at) VALUES (?, ?, ?, ?, ?)",
+		user.Id,
+		user.Username,
+		user.PasswordHash,
+		user.Role,
+		user.CreatedAt,
+	)
+
+	return user, err
+}
+
+// List implements domain.Repository.
+func (r *Repository) List(ctx context.Context) (*[]data.User, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, "SELECT id, username, password_hash, role, created_at FROM users ORDER BY username")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	elements := []data.User{}
+
+	for rows.Next() {
+		var element data.User
+
+		if err := rows.Scan(
+			&element.Id,
+			&element.Username,
+			&element.PasswordHash,
+			&element.Role,
+			&element.CreatedAt,
+		); err != nil {
+			return &elements, err
+		}
+
+		elements = append(elements, element)
+	}
+
+	return &elements, rows.Err()
+}
+
+// Get implements domain.Repository.
+func (r *Repository) Get(ctx context.Context, id string) (*data.User, error) {
+	return r.getBy(ctx, "id", id)
+}
+
+// GetByUsername implements domain.Repository.
+func (r *Repository) GetByUsername(ctx context.Context, username string) (*data.User, error) {
+	return r.getBy(ctx, "username", username)
+}
+
+func (r *Repository) getBy(ctx context.Context, column, value string) (*data.User, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	row := conn.QueryRowContext(
+		ctx,
+		"SELECT id, username, password_hash, role, created_at FROM users WHERE "+column+" = ?",
+		value,
+	)
+
+	var user data.User
+
+	if err := row.Scan(
+		&user.Id,
+		&user.Username,
+		&user.PasswordHash,
+		&user.Role,
+		&user.CreatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Update implements domain.Repository.
+func (r *Repository) Update(ctx context.Context, user *data.User) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(
+		ctx,
+		"UPDATE users SET username = ?, password_hash = ?, role = ? WHERE id = ?",
+		user.Username,
+		user.PasswordHash,
+		user.Role,
+		user.Id,
+	)
+
+	return err
+}
+
+// Delete implements domain.Repository.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM api_tokens WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CountByRole implements domain.Repository.
+func (r *Repository) CountByRole(ctx context.Context, role string) (int, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	var count int
+	err = conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE role = ?", role).Scan(&count)
+
+	return count, err
+}
+
+// Count implements domain.Repository.
+func (r *Repository) Count(ctx context.Context) (int, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	var count int
+	err = conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+
+	return count, err
+}
+
+// SubmitToken implements domain.Repository.
+func (r *Repository) SubmitToken(ctx context.Context, token *data.Token) (*data.Token, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	token.Id = uuid.NewString()
+
+	_, err = conn.ExecContext(
+		ctx,
+		"INSERT INTO api_tokens (id, user_id, name, token_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		token.Id,
+		token.UserId,
+		token.Name,
+		token.TokenHash,
+		token.Scopes,
+		token.CreatedAt,
+	)
+
+	return token, err
+}
+
+// ListTokens implements domain.Repository.
+func (r *Repository) ListTokens(ctx context.Context, userId string) (*[]data.Token, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(
+		ctx,
+		`SELECT id, user_id, name, token_hash, scopes, last_used_at, created_at
+		FROM api_tokens WHERE user_id = ? ORDER BY created_at`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	elements := []data.Token{}
+
+	for rows.Next() {
+		var element data.Token
+
+		if err := rows.Scan(
+			&element.Id,
+			&element.UserId,
+			&element.Name,
+			&element.TokenHash,
+			&element.Scopes,
+			&element.LastUsedAt,
+			&element.CreatedAt,
+		); err != nil {
+			return &elements, err
+		}
+
+		elements = append(elements, element)
+	}
+
+	return &elements, rows.Err()
+}
+
+// GetTokenByHash implements domain.Repository.
+func (r *Repository) GetTokenByHash(ctx context.Context, hash string) (*data.Token, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	row := conn.QueryRowContext(
+		ctx,
+		`SELECT id, user_id, name, token_hash, scopes, last_used_at, created_at
+		FROM api_tokens WHERE token_hash = ?`,
+		hash,
+	)
+
+	var token data.Token
+
+	if err := row.Scan(
+		&token.Id,
+		&token.UserId,
+		&token.Name,
+		&token.TokenHash,
+		&token.Scopes,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("invalid token")
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// TouchToken implements domain.Repository.
+func (r *Repository) TouchToken(ctx context.Context, id string, usedAt time.Time) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "UPDATE api_tokens SET last_used_at = ? WHERE id = ?", usedAt, id)
+
+	return err
+}
+
+// DeleteToken implements domain.Repository.
+func (r *Repository) DeleteToken(ctx context.Context, userId, id string) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	res, err := conn.ExecContext(ctx, "DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userId)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("token not found")
+	}
+
+	return nil
+}
+
+func New(db *sql.DB) domain.Repository {
+	return &Repository{
+		db: db,
+	}
+}
@@ -0,0 +1,295 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/audit"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/domain"
+)
+
+type RestHandler struct {
+	svc domain.Service
+}
+
+func New(svc domain.Service) domain.RestHandler {
+	return &RestHandler{
+		svc: svc,
+	}
+}
+
+// ApplyRouter implements domain.RestHandler.
+func (h *RestHandler) ApplyRouter() func(chi.Router) {
+	return func(r chi.Router) {
+		if middlewares.AuthEnabled() {
+			r.Use(middlewares.Authenticated)
+		}
+
+		r.Get("/me", h.Me())
+		r.Get("/me/tokens", h.ListTokens())
+		r.Post("/me/tokens", h.SubmitToken())
+		r.Delete("/me/tokens/{id}", h.DeleteToken())
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RequireScope(middlewares.ScopeAdmin))
+
+			r.Get("/", h.List())
+			r.Post("/", h.Submit())
+			r.Patch("/{id}", h.Update())
+			r.Delete("/{id}", h.Delete())
+		})
+	}
+}
+
+// Login implements domain.RestHandler.
+func (h *RestHandler) Login() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		var req domain.LoginRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		user, err := h.svc.Login(r.Context(), &req)
+		if err != nil {
+			audit.Record(r.Context(), audit.ActionLoginFailed, req.Username)
+			if errors.Is(err, domain.ErrInvalidCredentials) {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// the actor of a login is the user logging in
+		actor := &middlewares.Identity{Username: user.Username, Role: user.Role}
+		audit.Record(middlewares.WithIdentity(r.Context(), actor), audit.ActionLogin, user.Username)
+
+		expiresAt := time.Now().Add(time.Hour * 24 * 30)
+
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"expiresAt": expiresAt,
+			"username":  user.Username,
+		})
+
+		tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(tokenString); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// Me implements domain.RestHandler.
+func (h *RestHandler) Me() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+
+		identity, ok := middlewares.IdentityFrom(r.Context())
+		if !ok {
+			http.Error(w, "authentication is disabled", http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(identity); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// Submit implements domain.RestHandler.
+func (h *RestHandler) Submit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+
+		req := domain.User{Role: middlewares.RoleUser}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		res, err := h.svc.Submit(r.Context(), &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		audit.Record(r.Context(), audit.ActionUserCreate, res.Username)
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// List implements domain.RestHandler.
+func (h *RestHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+
+		res, err := h.svc.List(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// Update implements domain.RestHandler.
+func (h *RestHandler) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+
+		var req domain.User
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req.Id = chi.URLParam(r, "id")
+
+		if err := h.svc.Update(r.Context(), &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		audit.Record(r.Context(), audit.ActionUserUpdate, req.Id)
+
+		if err := json.NewEncoder(w).Encode("ok"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// Delete implements domain.RestHandler.
+func (h *RestHandler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := h.svc.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		audit.Record(r.Context(), audit.ActionUserDelete, chi.URLParam(r, "id"))
+
+		if err := json.NewEncoder(w).Encode("ok"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// SubmitToken implements domain.RestHandler.
+func (h *RestHandler) SubmitToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+
+		identity, ok := middlewares.IdentityFrom(r.Context())
+		if !ok {
+			http.Error(w, "authentication is disabled", http.StatusNotFound)
+			return
+		}
+
+		var req domain.Token
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		res, err := h.svc.SubmitToken(r.Context(), identity, &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		audit.Record(r.Context(), audit.ActionTokenCreate, res.Name)
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ListTokens implements domain.RestHandler.
+func (h *RestHandler) ListTokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+
+		identity, ok := middlewares.IdentityFrom(r.Context())
+		if !ok {
+			http.Error(w, "authentication is disabled", http.StatusNotFound)
+			return
+		}
+
+		res, err := h.svc.ListTokens(r.Context(), identity)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DeleteToken implements domain.RestHandler.
+func (h *RestHandler) DeleteToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+
+		identity, ok := middlewares.IdentityFrom(r.Context())
+		if !ok {
+			http.Error(w, "authentication is disabled", http.StatusNotFound)
+			return
+		}
+
+		if err := h.svc.DeleteToken(r.Context(), identity, chi.URLParam(r, "id")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		audit.Record(r.Context(), audit.ActionTokenRevoke, chi.URLParam(r, "id"))
+
+		if err := json.NewEncoder(w).Encode("ok"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
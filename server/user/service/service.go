@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/domain"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Compared against when the username doesn't exist, so that the response
+// time doesn't tell which usernames are taken.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("yt-dlp-webui"), bcrypt.DefaultCost)
+
+type Service struct {
+	r domain.Repository
+}
+
+func New(r domain.Repository) domain.Service {
+	return &Service{
+		r: r,
+	}
+}
+
+// Submit implements domain.Service.
+func (s *Service) Submit(ctx context.Context, user *domain.User) (*domain.User, error) {
+	if err := validate(user); err != nil {
+		return nil, err
+	}
+	if user.Password == "" {
+		return nil, errors.New("missing password")
+	}
+
+	if _, err := s.r.GetByUsername(ctx, user.Username); err == nil {
+		return nil, errors.New("username already taken")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.r.Submit(ctx, &data.User{
+		Username:     user.Username,
+		PasswordHash: string(hash),
+		Role:         string(user.Role),
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fromData(res), nil
+}
+
+// List implements domain.Service.
+func (s *Service) List(ctx context.Context) (*[]domain.User, error) {
+	users, err := s.r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := make([]domain.User, len(*users))
+	for i, u := range *users {
+		entities[i] = *fromData(&u)
+	}
+
+	return &entities, nil
+}
+
+// Update implements domain.Service.
+// Empty fields keep their current value.
+func (s *Service) Update(ctx context.Context, user *domain.User) error {
+	current, err := s.r.Get(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+
+	if user.Username == "" {
+		user.Username = current.Username
+	}
+	if user.Role == "" {
+		user.Role = middlewares.Role(current.Role)
+	}
+
+	if err := validate(user); err != nil {
+		return err
+	}
+
+	// the downloads, archive entries, subscriptions and quotas of a user are
+	// owned through their username, a rename would leave them behind for
+	// whoever takes the old one.
+	if current.Username != user.Username {
+		return errors.New("the username can't be changed")
+	}
+
+	if current.Role == string(middlewares.RoleAdmin) && user.Role != middlewares.RoleAdmin {
+		if err := s.ensureAnotherAdmin(ctx); err != nil {
+			return err
+		}
+	}
+
+	current.Role = string(user.Role)
+
+	if user.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		current.PasswordHash = string(hash)
+	}
+
+	return s.r.Update(ctx, current)
+}
+
+// Delete implements domain.Service.
+func (s *Service) Delete(ctx context.Context, id string) error {
+	current, err := s.r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if current.Role == string(middlewares.RoleAdmin) {
+		if err := s.ensureAnotherAdmin(ctx); err != nil {
+			return err
+		}
+	}
+
+	return s.r.Delete(ctx, id)
+}
+
+func (s *Service) ensureAnotherAdmin(ctx context.Context) error {
+	admins, err := s.r.CountByRole(ctx, string(middlewares.RoleAdmin))
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return errors.New("there must be at least one admin")
+	}
+	return nil
+}
+
+// Login implements domain.Service.
+func (s *Service) Login(ctx context.Context, req *domain.LoginRequest) (*domain.User, error) {
+	user, err := s.r.GetByUsername(ctx, req.Username)
+	if errors.Is(err, repository.ErrNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+		return nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	return fromData(user), nil
+}
+
+// Resolve implements domain.Service.
+func (s *Service) Resolve(ctx context.Context, username string) (*middlewares.Identity, error) {
+	user, err := s.r.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &middlewares.Identity{
+		Username: user.Username,
+		Role:     middlewares.Role(user.Role),
+	}, nil
+}
+
+// Bootstrap implements domain.Service.
+// When there are no users yet the first admin is created from the username
+// and password of the config.
+func (s *Service) Bootstrap(ctx context.Context) error {
+	count, err := s.r.Count(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var (
+		username = config.Instance().Username
+		password = config.Instance().Password
+	)
+
+	if username == "" || password == "" {
+		if config.Instance().RequireAuth {
+			slog.Warn("authentication is required but there are no users, set username and password to create the first admin")
+		}
+		return nil
+	}
+
+	_, err = s.Submit(ctx, &domain.User{
+		Username: username,
+		Password: password,
+		Role:     middlewares.RoleAdmin,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("created the first admin from the config", slog.String("username", username))
+
+	return nil
+}
+
+func validate(user *domain.User) error {
+	user.Username = strings.TrimSpace(user.Username)
+
+	if user.Username == "" {
+		return errors.New("missing username")
+	}
+	for _, prefix := range []string{middlewares.OpenIdUsernamePrefix, middlewares.SystemUsernamePrefix} {
+		if strings.HasPrefix(user.Username, prefix) {
+			return errors.New("the username can't start with " + prefix)
+		}
+	}
+	if !user.Role.Valid() {
+		return errors.New("role must be one of admin, user, viewer")
+	}
+	return nil
+}
+
+func fromData(u *data.User) *domain.User {
+	return &domain.User{
+		Id:        u.Id,
+		Username:  u.Username,
+		Role:      middlewares.Role(u.Role),
+		CreatedAt: u.CreatedAt,
+	}
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/config"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/dbutil"
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/domain"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/repository"
+	_ "modernc.org/sqlite"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+
+	// the migration leaves a lock file in the working directory
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "local.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := dbutil.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return New(repository.New(db)).(*Service)
+}
+
+// Creates the users with the given roles, named after their position
+func submitUsers(t *testing.T, s *Service, roles ...middlewares.Role) []*domain.User {
+	t.Helper()
+
+	users := make([]*domain.User, len(roles))
+	for i, role := range roles {
+		user, err := s.Submit(context.Background(), &domain.User{
+			Username: string(rune('a' + i)),
+			Password: "password",
+			Role:     role,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[i] = user
+	}
+	return users
+}
+
+func TestDeleteLastAdmin(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []middlewares.Role
+		deleted int
+		wantErr bool
+	}{
+		{name: "last admin", roles: []middlewares.Role{middlewares.RoleAdmin, middlewares.RoleUser}, deleted: 0, wantErr: true},
+		{name: "another admin left", roles: []middlewares.Role{middlewares.RoleAdmin, middlewares.RoleAdmin}, deleted: 0},
+		{name: "not an admin", roles: []middlewares.Role{middlewares.RoleAdmin, middlewares.RoleViewer}, deleted: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				ctx   = context.Background()
+				s     = newTestService(t)
+				users = submitUsers(t, s, tc.roles...)
+			)
+
+			err := s.Delete(ctx, users[tc.deleted].Id)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Delete() error = %v, want error %t", err, tc.wantErr)
+			}
+
+			left, err := s.List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := len(tc.roles) - 1
+			if tc.wantErr {
+				want = len(tc.roles)
+			}
+			if len(*left) != want {
+				t.Errorf("%d users left, want %d", len(*left), want)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		roles    []middlewares.Role
+		updated  int
+		update   domain.User
+		wantErr  bool
+		wantRole middlewares.Role
+	}{
+		{
+			name:    "demoting the last admin",
+			roles:   []middlewares.Role{middlewares.RoleAdmin, middlewares.RoleUser},
+			update:  domain.User{Role: middlewares.RoleUser},
+			wantErr: true,
+		},
+		{
+			name:     "demoting with another admin left",
+			roles:    []middlewares.Role{middlewares.RoleAdmin, middlewares.RoleAdmin},
+			update:   domain.User{Role: middlewares.RoleViewer},
+			wantRole: middlewares.RoleViewer,
+		},
+		{
+			name:     "promoting",
+			roles:    []middlewares.Role{middlewares.RoleAdmin, middlewares.RoleUser},
+			updated:  1,
+			update:   domain.User{Role: middlewares.RoleAdmin},
+			wantRole: middlewares.RoleAdmin,
+		},
+		{
+			name:     "password only",
+			roles:    []middlewares.Role{middlewares.RoleAdmin},
+			update:   domain.User{Password: "changed"},
+			wantRole: middlewares.RoleAdmin,
+		},
+		{
+			name:    "invalid role",
+			roles:   []middlewares.Role{middlewares.RoleAdmin, middlewares.RoleUser},
+			updated: 1,
+			update:  domain.User{Role: "owner"},
+			wantErr: true,
+		},
+		{
+			name:    "renaming",
+			roles:   []middlewares.Role{middlewares.RoleAdmin, middlewares.RoleUser},
+			updated: 1,
+			update:  domain.User{Username: "z"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				ctx   = context.Background()
+				s     = newTestService(t)
+				users = submitUsers(t, s, tc.roles...)
+				user  = users[tc.updated]
+			)
+
+			update := tc.update
+			update.Id = user.Id
+
+			err := s.Update(ctx, &update)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Update() error = %v, want error %t", err, tc.wantErr)
+			}
+
+			identity, err := s.Resolve(ctx, user.Username)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantRole := tc.wantRole
+			if tc.wantErr {
+				wantRole = user.Role
+			}
+			if identity.Role != wantRole {
+				t.Errorf("role = %s, want %s", identity.Role, wantRole)
+			}
+
+			if tc.update.Password != "" {
+				if _, err := s.Login(ctx, &domain.LoginRequest{Username: user.Username, Password: tc.update.Password}); err != nil {
+					t.Errorf("login with the new password: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    domain.User
+		wantErr bool
+	}{
+		{name: "valid", user: domain.User{Username: " alice ", Password: "password", Role: middlewares.RoleUser}},
+		{name: "missing username", user: domain.User{Username: " ", Password: "password", Role: middlewares.RoleUser}, wantErr: true},
+		{name: "missing password", user: domain.User{Username: "alice", Role: middlewares.RoleUser}, wantErr: true},
+		{name: "invalid role", user: domain.User{Username: "alice", Password: "password", Role: "root"}, wantErr: true},
+		{name: "taken username", user: domain.User{Username: "taken", Password: "password", Role: middlewares.RoleUser}, wantErr: true},
+		{name: "openid prefix", user: domain.User{Username: middlewares.OpenIdUsernamePrefix + "sub", Password: "password", Role: middlewares.RoleUser}, wantErr: true},
+		{name: "system prefix", user: domain.User{Username: middlewares.SystemUsernamePrefix + "retention", Password: "password", Role: middlewares.RoleAdmin}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				ctx = context.Background()
+				s   = newTestService(t)
+			)
+
+			if _, err := s.Submit(ctx, &domain.User{Username: "taken", Password: "password", Role: middlewares.RoleAdmin}); err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := s.Submit(ctx, &tc.user)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Submit() error = %v, want error %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if user.Username != "alice" || user.Password != "" {
+				t.Errorf("submitted %+v, want alice without the password", user)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	var (
+		ctx = context.Background()
+		s   = newTestService(t)
+	)
+	submitUsers(t, s, middlewares.RoleAdmin)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  error
+	}{
+		{name: "valid", username: "a", password: "password"},
+		{name: "wrong password", username: "a", password: "wrong", wantErr: domain.ErrInvalidCredentials},
+		// indistinguishable from a wrong password
+		{name: "unknown user", username: "b", password: "password", wantErr: domain.ErrInvalidCredentials},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.Login(ctx, &domain.LoginRequest{Username: tc.username, Password: tc.password})
+			if err != tc.wantErr {
+				t.Errorf("Login() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	conf := config.Instance()
+	defer func(username, password string) {
+		conf.Username, conf.Password = username, password
+	}(conf.Username, conf.Password)
+
+	tests := []struct {
+		name      string
+		existing  []middlewares.Role
+		username  string
+		password  string
+		wantUsers int
+		wantAdmin string
+	}{
+		{name: "first start", username: "admin", password: "password", wantUsers: 1, wantAdmin: "admin"},
+		{name: "without credentials", username: "admin", wantUsers: 0},
+		// the config is only used for the first admin
+		{name: "users already there", existing: []middlewares.Role{middlewares.RoleUser}, username: "admin", password: "password", wantUsers: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				ctx = context.Background()
+				s   = newTestService(t)
+			)
+			submitUsers(t, s, tc.existing...)
+
+			conf.Username, conf.Password = tc.username, tc.password
+
+			if err := s.Bootstrap(ctx); err != nil {
+				t.Fatal(err)
+			}
+			// nothing more on the following starts
+			if err := s.Bootstrap(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			users, err := s.List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(*users) != tc.wantUsers {
+				t.Fatalf("%d users, want %d", len(*users), tc.wantUsers)
+			}
+
+			if tc.wantAdmin == "" {
+				return
+			}
+			if u := (*users)[0]; u.Username != tc.wantAdmin || u.Role != middlewares.RoleAdmin {
+				t.Errorf("bootstrapped %s %s, want admin %s", u.Role, u.Username, tc.wantAdmin)
+			}
+		})
+	}
+}
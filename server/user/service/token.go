@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/domain"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/repository"
+)
+
+// The last use of a token is recorded at most once in this interval
+const tokenTouchInterval = time.Minute
+
+// SubmitToken implements domain.Service.
+// The token can't be granted scopes its owner doesn't have, when the owner is
+// itself authenticated with a token the scopes of that one apply.
+func (s *Service) SubmitToken(ctx context.Context, owner *middlewares.Identity, token *domain.Token) (*domain.Token, error) {
+	user, err := s.localUser(ctx, owner.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Name = strings.TrimSpace(token.Name)
+	if token.Name == "" {
+		return nil, errors.New("missing token name")
+	}
+	if len(token.Scopes) == 0 {
+		return nil, errors.New("missing token scopes")
+	}
+
+	for _, scope := range token.Scopes {
+		if !scope.Valid() {
+			return nil, errors.New("scopes must be among read, enqueue, delete, admin")
+		}
+		if !owner.Can(scope) {
+			return nil, errors.New("the " + string(scope) + " scope isn't granted to you")
+		}
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]string, len(token.Scopes))
+	for i, scope := range token.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	res, err := s.r.SubmitToken(ctx, &data.Token{
+		UserId:    user.Id,
+		Name:      token.Name,
+		TokenHash: hashToken(secret),
+		Scopes:    strings.Join(slices.Compact(slices.Sorted(slices.Values(scopes))), ","),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the token is returned only once
+	entity := fromTokenData(res)
+	entity.Token = secret
+
+	return entity, nil
+}
+
+// ListTokens implements domain.Service.
+func (s *Service) ListTokens(ctx context.Context, owner *middlewares.Identity) (*[]domain.Token, error) {
+	user, err := s.localUser(ctx, owner.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.r.ListTokens(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := make([]domain.Token, len(*tokens))
+	for i, t := range *tokens {
+		entities[i] = *fromTokenData(&t)
+	}
+
+	return &entities, nil
+}
+
+// DeleteToken implements domain.Service.
+func (s *Service) DeleteToken(ctx context.Context, owner *middlewares.Identity, id string) error {
+	user, err := s.localUser(ctx, owner.Username)
+	if err != nil {
+		return err
+	}
+
+	return s.r.DeleteToken(ctx, user.Id, id)
+}
+
+// ResolveToken implements domain.Service.
+// The role is the current one of the owner, so demoting a user restricts its
+// tokens too.
+func (s *Service) ResolveToken(ctx context.Context, token string) (*middlewares.Identity, error) {
+	t, err := s.r.GetTokenByHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.r.Get(ctx, t.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if now := time.Now(); !t.LastUsedAt.Valid || now.Sub(t.LastUsedAt.Time) > tokenTouchInterval {
+		if err := s.r.TouchToken(ctx, t.Id, now); err != nil {
+			slog.Warn("failed to record token use", slog.String("id", t.Id), slog.String("err", err.Error()))
+		}
+	}
+
+	return &middlewares.Identity{
+		Username: user.Username,
+		Role:     middlewares.Role(user.Role),
+		Scopes:   fromTokenData(t).Scopes,
+		TokenId:  t.Id,
+	}, nil
+}
+
+// api tokens belong to the local accounts
+func (s *Service) localUser(ctx context.Context, username string) (*data.User, error) {
+	user, err := s.r.GetByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errors.New("api tokens require a local account")
+	}
+	return user, err
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return middlewares.APITokenPrefix + hex.EncodeToString(b), nil
+}
+
+// The tokens are random, a plain hash is enough to not store them in clear
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func fromTokenData(t *data.Token) *domain.Token {
+	token := &domain.Token{
+		Id:        t.Id,
+		Name:      t.Name,
+		Scopes:    []middlewares.Scope{},
+		CreatedAt: t.CreatedAt,
+	}
+
+	for _, scope := range strings.Split(t.Scopes, ",") {
+		if scope != "" {
+			token.Scopes = append(token.Scopes, middlewares.Scope(scope))
+		}
+	}
+
+	if t.LastUsedAt.Valid {
+		token.LastUsedAt = &t.LastUsedAt.Time
+	}
+
+	return token
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	middlewares "github.com/marcopiovanello/yt-dlp-web-ui/v3/server/middleware"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/user/domain"
+)
+
+func TestSubmitToken(t *testing.T) {
+	var (
+		read    = middlewares.ScopeRead
+		enqueue = middlewares.ScopeEnqueue
+		del     = middlewares.ScopeDelete
+	)
+
+	tests := []struct {
+		name       string
+		owner      middlewares.Identity // the username is set to the local user
+		token      domain.Token
+		wantErr    bool
+		wantScopes []middlewares.Scope
+	}{
+		{
+			name:       "valid",
+			owner:      middlewares.Identity{Role: middlewares.RoleUser},
+			token:      domain.Token{Name: "script", Scopes: []middlewares.Scope{enqueue, read, read}},
+			wantScopes: []middlewares.Scope{enqueue, read},
+		},
+		{
+			name:    "missing name",
+			owner:   middlewares.Identity{Role: middlewares.RoleUser},
+			token:   domain.Token{Name: " ", Scopes: []middlewares.Scope{read}},
+			wantErr: true,
+		},
+		{
+			name:    "missing scopes",
+			owner:   middlewares.Identity{Role: middlewares.RoleUser},
+			token:   domain.Token{Name: "script"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid scope",
+			owner:   middlewares.Identity{Role: middlewares.RoleAdmin},
+			token:   domain.Token{Name: "script", Scopes: []middlewares.Scope{"write"}},
+			wantErr: true,
+		},
+		{
+			name:    "scope not granted to the role",
+			owner:   middlewares.Identity{Role: middlewares.RoleViewer},
+			token:   domain.Token{Name: "script", Scopes: []middlewares.Scope{read, enqueue}},
+			wantErr: true,
+		},
+		{
+			// a token can't create a token which can do more than itself
+			name:    "scope not granted to the token",
+			owner:   middlewares.Identity{Role: middlewares.RoleUser, Scopes: []middlewares.Scope{read, enqueue}},
+			token:   domain.Token{Name: "script", Scopes: []middlewares.Scope{del}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				ctx   = context.Background()
+				s     = newTestService(t)
+				owner = submitUsers(t, s, middlewares.RoleAdmin)[0]
+			)
+
+			identity := tc.owner
+			identity.Username = owner.Username
+
+			token, err := s.SubmitToken(ctx, &identity, &tc.token)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("SubmitToken() error = %v, want error %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !strings.HasPrefix(token.Token, middlewares.APITokenPrefix) {
+				t.Errorf("token = %q, want the %s prefix", token.Token, middlewares.APITokenPrefix)
+			}
+			if !slices.Equal(token.Scopes, tc.wantScopes) {
+				t.Errorf("scopes = %v, want %v", token.Scopes, tc.wantScopes)
+			}
+
+			// the secret is only returned once
+			tokens, err := s.ListTokens(ctx, &identity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(*tokens) != 1 || (*tokens)[0].Token != "" {
+				t.Errorf("listed %+v, want the token without its secret", *tokens)
+			}
+		})
+	}
+}
+
+func TestSubmitTokenOpenId(t *testing.T) {
+	s := newTestService(t)
+
+	owner := &middlewares.Identity{Username: middlewares.OpenIdUsernamePrefix + "sub", Role: middlewares.RoleUser}
+	token := &domain.Token{Name: "script", Scopes: []middlewares.Scope{middlewares.ScopeRead}}
+
+	if _, err := s.SubmitToken(context.Background(), owner, token); err == nil {
+		t.Error("created a token for an openid user")
+	}
+}
+
+func TestResolveToken(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		s     = newTestService(t)
+		users = submitUsers(t, s, middlewares.RoleAdmin, middlewares.RoleUser)
+		owner = &middlewares.Identity{Username: users[1].Username, Role: users[1].Role}
+	)
+
+	token, err := s.SubmitToken(ctx, owner, &domain.Token{
+		Name:   "script",
+		Scopes: []middlewares.Scope{middlewares.ScopeRead, middlewares.ScopeEnqueue},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := s.ResolveToken(ctx, token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Username != owner.Username || identity.TokenId != token.Id {
+		t.Errorf("resolved %+v, want the token %s of %s", identity, token.Id, owner.Username)
+	}
+	if !identity.Can(middlewares.ScopeEnqueue) || identity.Can(middlewares.ScopeDelete) {
+		t.Errorf("resolved scopes %v, want read and enqueue only", identity.Scopes)
+	}
+
+	// the use is recorded
+	tokens, err := s.ListTokens(ctx, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*tokens)[0].LastUsedAt == nil {
+		t.Error("the use of the token wasn't recorded")
+	}
+
+	// demoting the owner restricts the token too
+	if err := s.Update(ctx, &domain.User{Id: users[1].Id, Role: middlewares.RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	identity, err = s.ResolveToken(ctx, token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Can(middlewares.ScopeEnqueue) || !identity.Can(middlewares.ScopeRead) {
+		t.Errorf("a viewer's token can enqueue: %+v", identity)
+	}
+
+	if err := s.DeleteToken(ctx, owner, token.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ResolveToken(ctx, token.Token); err == nil {
+		t.Error("resolved a deleted token")
+	}
+	if _, err := s.ResolveToken(ctx, middlewares.APITokenPrefix+"unknown"); err == nil {
+		t.Error("resolved an unknown token")
+	}
+}
@@ -0,0 +1,17 @@
+package webhook
+
+import (
+	"database/sql"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/domain"
+)
+
+func Container(db *sql.DB, mdb *internal.MemoryDB) (domain.RestHandler, domain.Service) {
+	var (
+		r = provideRepository(db)
+		s = provideService(r, mdb)
+		h = provideHandler(s)
+	)
+	return h, s
+}
@@ -0,0 +1,22 @@
+package data
+
+import "time"
+
+type Webhook struct {
+	Id        string
+	URL       string
+	Secret    string
+	Events    string // comma separated, empty means every event
+	Enabled   bool
+	CreatedAt time.Time
+}
+
+type DeadLetter struct {
+	Id        string
+	WebhookId string
+	Event     string
+	Payload   string
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+}
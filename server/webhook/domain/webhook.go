@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/archiver"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/data"
+)
+
+type Webhook struct {
+	Id        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"` // only returned when the webhook is created
+	Events    []string  `json:"events"`           // empty means every event
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery which has failed every attempt
+type DeadLetter struct {
+	Id        string          `json:"id"`
+	WebhookId string          `json:"webhook_id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Body of the request sent to the webhook endpoints
+type Payload struct {
+	Id      string                    `json:"id"` // delivery id
+	Event   internal.EventType        `json:"event"`
+	Time    time.Time                 `json:"time"`
+	Process *internal.ProcessResponse `json:"process"`
+	Archive *archiver.Message         `json:"archive,omitempty"`
+}
+
+type Repository interface {
+	Submit(ctx context.Context, webhook *data.Webhook) (*data.Webhook, error)
+	List(ctx context.Context) (*[]data.Webhook, error)
+	Get(ctx context.Context, id string) (*data.Webhook, error)
+	Update(ctx context.Context, webhook *data.Webhook) error
+	Delete(ctx context.Context, id string) error
+	AddDeadLetter(ctx context.Context, letter *data.DeadLetter) error
+	ListDeadLetters(ctx context.Context) (*[]data.DeadLetter, error)
+	GetDeadLetter(ctx context.Context, id string) (*data.DeadLetter, error)
+	DeleteDeadLetter(ctx context.Context, id string) error
+}
+
+type Service interface {
+	Submit(ctx context.Context, webhook *Webhook) (*Webhook, error)
+	List(ctx context.Context) (*[]Webhook, error)
+	Update(ctx context.Context, webhook *Webhook) error
+	Delete(ctx context.Context, id string) error
+	ListDeadLetters(ctx context.Context) (*[]DeadLetter, error)
+	Redeliver(ctx context.Context, id string) error
+	DeleteDeadLetter(ctx context.Context, id string) error
+	Listen(ctx context.Context)
+}
+
+type RestHandler interface {
+	Submit() http.HandlerFunc
+	List() http.HandlerFunc
+	Update() http.HandlerFunc
+	Delete() http.HandlerFunc
+	ListDeadLetters() http.HandlerFunc
+	Redeliver() http.HandlerFunc
+	DeleteDeadLetter() http.HandlerFunc
+	ApplyRouter() func(chi.Router)
+}
@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/domain"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/repository"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/rest"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/service"
+)
+
+var (
+	repo domain.Repository
+	svc  domain.Service
+	hand domain.RestHandler
+
+	repoOnce sync.Once
+	svcOnce  sync.Once
+	handOnce sync.Once
+)
+
+func provideRepository(db *sql.DB) domain.Repository {
+	repoOnce.Do(func() {
+		repo = repository.New(db)
+	})
+	return repo
+}
+
+func provideService(r domain.Repository, mdb *internal.MemoryDB) domain.Service {
+	svcOnce.Do(func() {
+		svc = service.New(r, mdb)
+	})
+	return svc
+}
+
+func provideHandler(s domain.Service) domain.RestHandler {
+	handOnce.Do(func() {
+		hand = rest.New(s)
+	})
+	return hand
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/domain"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+// Submit implements domain.Repository.
+func (r *Repository) Submit(ctx context.Context, webhook *data.Webhook) (*data.Webhook, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	webhook.Id = uuid.NewString()
+
+	_, err = conn.ExecContext(
+		ctx,
+		"INSERT INTO webhooks (id, url, secret, events, enabled, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		webhook.Id,
+		webhook.URL,
+		webhook.Secret,
+		webhook.Events,
+		webhook.Enabled,
+		webhook.CreatedAt,
+	)
+
+	return webhook, err
+}
+
+// List implements domain.Repository.
+func (r *Repository) List(ctx context.Context) (*[]data.Webhook, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, "SELECT id, url, secret, events, enabled, created_at FROM webhooks ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	elements := []data.Webhook{}
+
+	for rows.Next() {
+		var element data.Webhook
+
+		if err := rows.Scan(
+			&element.Id,
+			&element.URL,
+			&element.Secret,
+			&element.Events,
+			&element.Enabled,
+			&element.CreatedAt,
+		); err != nil {
+			return &elements, err
+		}
+
+		elements = append(elements, element)
+	}
+
+	return &elements, rows.Err()
+}
+
+// Get implements domain.Repository.
+func (r *Repository) Get(ctx context.Context, id string) (*data.Webhook, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	row := conn.QueryRowContext(ctx, "SELECT id, url, secret, events, enabled, created_at FROM webhooks WHERE id = ?", id)
+
+	var webhook data.Webhook
+
+	if err := row.Scan(
+		&webhook.Id,
+		&webhook.URL,
+		&webhook.Secret,
+		&webhook.Events,
+		&webhook.Enabled,
+		&webhook.CreatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("webhook not found")
+		}
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// Update implements domain.Repository.
+func (r *Repository) Update(ctx context.Context, webhook *data.Webhook) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(
+		ctx,
+		"UPDATE webhooks SET url = ?, secret = ?, events = ?, enabled = ? WHERE id = ?",
+		webhook.URL,
+		webhook.Secret,
+		webhook.Events,
+		webhook.Enabled,
+		webhook.Id,
+	)
+
+	return err
+}
+
+// Delete implements domain.Repository.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+
+	return err
+}
+
+// AddDeadLetter implements domain.Repository.
+func (r *Repository) AddDeadLetter(ctx context.Context, letter *data.DeadLetter) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(
+		ctx,
+		`INSERT INTO webhook_dead_letters (id, webhook_id, event, payload, attempts, last_error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		letter.Id,
+		letter.WebhookId,
+		letter.Event,
+		letter.Payload,
+		letter.Attempts,
+		letter.LastError,
+		letter.CreatedAt,
+	)
+
+	return err
+}
+
+// ListDeadLetters implements domain.Repository.
+func (r *Repository) ListDeadLetters(ctx context.Context) (*[]data.DeadLetter, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(
+		ctx,
+		`SELECT id, webhook_id, event, payload, attempts, last_error, created_at
+		FROM webhook_dead_letters ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	elements := []data.DeadLetter{}
+
+	for rows.Next() {
+		var element data.DeadLetter
+
+		if err := rows.Scan(
+			&element.Id,
+			&element.WebhookId,
+			&element.Event,
+			&element.Payload,
+			&element.Attempts,
+			&element.LastError,
+			&element.CreatedAt,
+		); err != nil {
+			return &elements, err
+		}
+
+		elements = append(elements, element)
+	}
+
+	return &elements, rows.Err()
+}
+
+// GetDeadLetter implements domain.Repository.
+func (r *Repository) GetDeadLetter(ctx context.Context, id string) (*data.DeadLetter, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	row := conn.QueryRowContext(
+		ctx,
+		`SELECT id, webhook_id, event, payload, attempts, last_error, created_at
+		FROM webhook_dead_letters WHERE id = ?`,
+		id,
+	)
+
+	var letter data.DeadLetter
+
+	if err := row.Scan(
+		&letter.Id,
+		&letter.WebhookId,
+		&letter.Event,
+		&letter.Payload,
+		&letter.Attempts,
+		&letter.LastError,
+		&letter.CreatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("dead letter not found")
+		}
+		return nil, err
+	}
+
+	return &letter, nil
+}
+
+// DeleteDeadLetter implements domain.Repository.
+func (r *Repository) DeleteDeadLetter(ctx context.Context, id string) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "DELETE FROM webhook_dead_letters WHERE id = ?", id)
+
+	return err
+}
+
+func New(db *sql.DB) domain.Repository {
+	return &Repository{
+		db: db,
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/domain"
+)
+
+const (
+	maxConcurrentDeliveries = 8
+	maxDeliveryAttempts     = 5
+	deliveryTimeout         = 10 * time.Second
+	deliveryBackoff         = 5 * time.Second // doubled after every failed attempt
+)
+
+// Events which can be sent to a webhook, progress events are too frequent.
+var webhookEvents = []internal.EventType{
+	internal.EventCreated,
+	internal.EventMetadata,
+	internal.EventCompleted,
+	internal.EventErrored,
+	internal.EventRemoved,
+}
+
+func isWebhookEvent(t internal.EventType) bool {
+	return slices.Contains(webhookEvents, t)
+}
+
+// Listen implements domain.Service.
+// Forwards the process events to the webhooks until ctx is done. If the
+// listener falls behind it resumes from the last event it has handled.
+func (s *Service) Listen(ctx context.Context) {
+	filter := internal.EventFilter{Types: webhookEvents}
+
+	var since uint64
+
+	for {
+		backlog, events := s.mdb.Events(ctx, filter, since)
+
+		for _, event := range backlog {
+			since = s.handle(ctx, event)
+		}
+		for event := range events {
+			since = s.handle(ctx, event)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// Starts the deliveries of an event, returns its sequence number.
+func (s *Service) handle(ctx context.Context, event internal.Event) uint64 {
+	if event.Type == internal.EventResync {
+		slog.Warn("webhook listener missed some events", slog.Uint64("seq", event.Seq))
+		return event.Seq
+	}
+
+	webhooks, err := s.r.List(ctx)
+	if err != nil {
+		slog.Error("failed to list webhooks", slog.String("err", err.Error()))
+		return event.Seq
+	}
+
+	for _, webhook := range *webhooks {
+		if !webhook.Enabled || !subscribed(&webhook, event.Type) {
+			continue
+		}
+
+		payload := newPayload(event)
+		body, err := json.Marshal(payload)
+		if err != nil {
+			slog.Error("failed to encode webhook payload", slog.String("err", err.Error()))
+			continue
+		}
+
+		go s.deliver(ctx, &webhook, payload.Id, string(event.Type), body)
+	}
+
+	return event.Seq
+}
+
+func subscribed(webhook *data.Webhook, t internal.EventType) bool {
+	return webhook.Events == "" || slices.Contains(strings.Split(webhook.Events, ","), string(t))
+}
+
+// Posts the payload to the webhook retrying with exponential backoff, when
+// every attempt fails the delivery is recorded as dead letter.
+func (s *Service) deliver(ctx context.Context, webhook *data.Webhook, id, event string, body []byte) {
+	var (
+		backoff = s.backoff
+		err     error
+	)
+
+	for attempt := 1; attempt <= maxDeliveryAttempts; attempt++ {
+		s.semaphore <- struct{}{}
+		err = s.post(ctx, webhook, id, event, body)
+		<-s.semaphore
+
+		if err == nil {
+			slog.Info("webhook delivered",
+				slog.String("webhook", webhook.Id),
+				slog.String("event", event),
+				slog.String("delivery", id),
+			)
+			return
+		}
+
+		slog.Warn("webhook delivery failed",
+			slog.String("webhook", webhook.Id),
+			slog.String("delivery", id),
+			slog.Int("attempt", attempt),
+			slog.String("err", err.Error()),
+		)
+
+		if attempt == maxDeliveryAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+
+	letter := &data.DeadLetter{
+		Id:        id,
+		WebhookId: webhook.Id,
+		Event:     event,
+		Payload:   string(body),
+		Attempts:  maxDeliveryAttempts,
+		LastError: err.Error(),
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.r.AddDeadLetter(context.Background(), letter); err != nil {
+		slog.Error("failed to record webhook dead letter",
+			slog.String("delivery", id),
+			slog.String("err", err.Error()),
+		)
+	}
+}
+
+func (s *Service) post(ctx context.Context, webhook *data.Webhook, id, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "yt-dlp-webui")
+	req.Header.Set("X-Webhook-Id", webhook.Id)
+	req.Header.Set("X-Webhook-Event", event)
+	req.Header.Set("X-Webhook-Delivery", id)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(webhook.Secret, body))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return nil
+}
+
+// Hex encoded HMAC-SHA256 of the body, sent in the X-Webhook-Signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newPayload(event internal.Event) domain.Payload {
+	return domain.Payload{
+		Id:      uuid.NewString(),
+		Event:   event.Type,
+		Time:    event.Time,
+		Process: event.Process,
+		Archive: event.Archive,
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/domain"
+)
+
+// Keeps the dead letters in memory, the deliveries don't use the rest
+type deadLetters struct {
+	domain.Repository
+
+	mu      sync.Mutex
+	letters []data.DeadLetter
+}
+
+func (r *deadLetters) AddDeadLetter(ctx context.Context, letter *data.DeadLetter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.letters = append(r.letters, *letter)
+	return nil
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int // attempts answered with a 5xx before a 204
+		wantLetter bool
+	}{
+		{name: "delivered", failures: 0},
+		{name: "retried on 5xx", failures: 2},
+		{name: "last attempt", failures: maxDeliveryAttempts - 1},
+		{name: "dead letter", failures: maxDeliveryAttempts, wantLetter: true},
+	}
+
+	const (
+		secret = "s3cret"
+		body   = `{"event":"completed"}`
+	)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ := io.ReadAll(r.Body)
+
+				if string(got) != body {
+					t.Errorf("body = %s, want %s", got, body)
+				}
+				if sig := r.Header.Get("X-Webhook-Signature"); sig != "sha256="+Sign(secret, got) {
+					t.Errorf("signature = %s, want the hmac of the body", sig)
+				}
+				for header, want := range map[string]string{
+					"X-Webhook-Id":       "hook",
+					"X-Webhook-Event":    "completed",
+					"X-Webhook-Delivery": "delivery",
+					"Content-Type":       "application/json",
+				} {
+					if got := r.Header.Get(header); got != want {
+						t.Errorf("%s = %s, want %s", header, got, want)
+					}
+				}
+
+				if int(attempts.Add(1)) <= tc.failures {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			var (
+				repo = &deadLetters{}
+				s    = &Service{
+					r:         repo,
+					client:    server.Client(),
+					semaphore: make(chan struct{}, 1),
+					backoff:   time.Millisecond,
+				}
+				webhook = &data.Webhook{Id: "hook", URL: server.URL, Secret: secret}
+			)
+
+			s.deliver(context.Background(), webhook, "delivery", "completed", []byte(body))
+
+			wantAttempts := min(tc.failures+1, maxDeliveryAttempts)
+			if got := int(attempts.Load()); got != wantAttempts {
+				t.Errorf("%d attempts, want %d", got, wantAttempts)
+			}
+
+			if !tc.wantLetter {
+				if len(repo.letters) > 0 {
+					t.Errorf("dead letter recorded for a delivered event: %+v", repo.letters)
+				}
+				return
+			}
+
+			if len(repo.letters) != 1 {
+				t.Fatalf("%d dead letters, want 1", len(repo.letters))
+			}
+
+			letter := repo.letters[0]
+			if letter.Id != "delivery" || letter.WebhookId != "hook" || letter.Event != "completed" {
+				t.Errorf("unexpected dead letter %+v", letter)
+			}
+			if letter.Payload != body {
+				t.Errorf("payload = %s, want %s", letter.Payload, body)
+			}
+			if letter.Attempts != maxDeliveryAttempts {
+				t.Errorf("attempts = %d, want %d", letter.Attempts, maxDeliveryAttempts)
+			}
+			if letter.LastError != "unexpected status 502 Bad Gateway" {
+				t.Errorf("last error = %q", letter.LastError)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n 'The quick brown fox jumps over the lazy dog' | openssl dgst -sha256 -hmac key
+	const want = "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+
+	if got := Sign("key", []byte("The quick brown fox jumps over the lazy dog")); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/internal"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/data"
+	"github.com/marcopiovanello/yt-dlp-web-ui/v3/server/webhook/domain"
+)
+
+type Service struct {
+	r         domain.Repository
+	mdb       *internal.MemoryDB
+	client    *http.Client
+	semaphore chan struct{}
+	backoff   time.Duration // before the second attempt of a delivery
+}
+
+func New(r domain.Repository, mdb *internal.MemoryDB) domain.Service {
+	return &Service{
+		r:         r,
+		mdb:       mdb,
+		client:    &http.Client{Timeout: deliveryTimeout},
+		semaphore: make(chan struct{}, maxConcurrentDeliveries),
+		backoff:   deliveryBackoff,
+	}
+}
+
+// Submit implements domain.Service.
+func (s *Service) Submit(ctx context.Context, webhook *domain.Webhook) (*domain.Webhook, error) {
+	if err := validate(webhook); err != nil {
+		return nil, err
+	}
+
+	if webhook.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, err
+		}
+		webhook.Secret = secret
+	}
+
+	webhook.CreatedAt = time.Now()
+
+	res, err := s.r.Submit(ctx, toData(webhook))
+	if err != nil {
+		return nil, err
+	}
+
+	// the secret is returned only once
+	return fromData(res, true), nil
+}
+
+// List implements domain.Service.
+func (s *Service) List(ctx context.Context) (*[]domain.Webhook, error) {
+	webhooks, err := s.r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := make([]domain.Webhook, len(*webhooks))
+	for i, w := range *webhooks {
+		entities[i] = *fromData(&w, false)
+	}
+
+	return &entities, nil
+}
+
+// Update implements domain.Service.
+// An empty secret keeps the current one.
+func (s *Service) Update(ctx context.Context, webhook *domain.Webhook) error {
+	if err := validate(webhook); err != nil {
+		return err
+	}
+
+	current, err := s.r.Get(ctx, webhook.Id)
+	if err != nil {
+		return err
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret = current.Secret
+	}
+
+	return s.r.Update(ctx, toData(webhook))
+}
+
+// Delete implements domain.Service.
+func (s *Service) Delete(ctx context.Context, id string) error {
+	return s.r.Delete(ctx, id)
+}
+
+// ListDeadLetters implements domain.Service.
+func (s *Service) ListDeadLetters(ctx context.Context) (*[]domain.DeadLetter, error) {
+	letters, err := s.r.ListDeadLetters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := make([]domain.DeadLetter, len(*letters))
+	for i, l := range *letters {
+		entities[i] = domain.DeadLetter{
+			Id:        l.Id,
+			WebhookId: l.WebhookId,
+			Event:     l.Event,
+			Payload:   []byte(l.Payload),
+			Attempts:  l.Attempts,
+			LastError: l.LastError,
+			CreatedAt: l.CreatedAt,
+		}
+	}
+
+	return &entities, nil
+}
+
+// Redeliver implements domain.Service.
+// The dead letter is removed and its payload delivered again, if every
+// attempt fails once more a new dead letter is recorded.
+func (s *Service) Redeliver(ctx context.Context, id string) error {
+	letter, err := s.r.GetDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := s.r.Get(ctx, letter.WebhookId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.r.DeleteDeadLetter(ctx, id); err != nil {
+		return err
+	}
+
+	go s.deliver(context.Background(), webhook, letter.Id, letter.Event, []byte(letter.Payload))
+
+	return nil
+}
+
+// DeleteDeadLetter implements domain.Service.
+func (s *Service) DeleteDeadLetter(ctx context.Context, id string) error {
+	return s.r.DeleteDeadLetter(ctx, id)
+}
+
+func validate(webhook *domain.Webhook) error {
+	u, err := url.Parse(webhook.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("webhook url must be http or https")
+	}
+
+	for _, event := range webhook.Events {
+		if !isWebhookEvent(internal.EventType(event)) {
+			return errors.New("unsupported webhook event " + event)
+		}
+	}
+
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func toData(webhook *domain.Webhook) *data.Webhook {
+	return &data.Webhook{
+		Id:        webhook.Id,
+		URL:       webhook.URL,
+		Secret:    webhook.Secret,
+		Events:    strings.Join(webhook.Events, ","),
+		Enabled:   webhook.Enabled,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+func fromData(webhook *data.Webhook, withSecret bool) *domain.Webhook {
+	entity := &domain.Webhook{
+		Id:        webhook.Id,
+		URL:       webhook.URL,
+		Events:    []string{},
+		Enabled:   webhook.Enabled,
+		CreatedAt: webhook.CreatedAt,
+	}
+	if webhook.Events != "" {
+		entity.Events = strings.Split(webhook.Events, ",")
+	}
+	if withSecret {
+		entity.Secret = webhook.Secret
+	}
+	return entity
+}